@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/model"
+	"github.com/bro-n-bro/spacebox-crawler/v2/types"
+)
+
+func (s *Storage) CreateBackfillJob(ctx context.Context, job *model.BackfillJob) error {
+	_, err := s.backfillCollection.InsertOne(ctx, job)
+	return err
+}
+
+func (s *Storage) GetBackfillJob(ctx context.Context, id string) (*model.BackfillJob, error) {
+	var job model.BackfillJob
+
+	err := s.backfillCollection.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&job)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, types.ErrBackfillJobNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+func (s *Storage) GetBackfillJobs(ctx context.Context) ([]*model.BackfillJob, error) {
+	cursor, err := s.backfillCollection.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "created", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]*model.BackfillJob, 0)
+	if err = cursor.All(ctx, &jobs); err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// SetBackfillJobProgress moves the next height of the job forward.
+// The job is finished when all its heights are enqueued.
+func (s *Storage) SetBackfillJobProgress(ctx context.Context, id string, next int64) error {
+	job, err := s.GetBackfillJob(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if next <= job.Next {
+		return nil
+	}
+
+	set := bson.D{{Key: "next", Value: next}}
+	if next > job.To && job.Status.IsActive() {
+		set = append(set, bson.E{Key: "status", Value: model.BackfillJobFinished}, bson.E{Key: "finished", Value: time.Now()})
+	}
+
+	filter := bson.D{{Key: "_id", Value: id}, {Key: "next", Value: bson.D{{Key: "$lt", Value: next}}}}
+	_, err = s.backfillCollection.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: set}})
+
+	return err
+}
+
+// SetBackfillJobStatus sets the status of the job or returns types.ErrBackfillJobDone if it is finished or cancelled.
+func (s *Storage) SetBackfillJobStatus(ctx context.Context, id string, status model.BackfillJobStatus) error {
+	set := bson.D{{Key: "status", Value: status}}
+	if status.IsDone() {
+		set = append(set, bson.E{Key: "finished", Value: time.Now()})
+	}
+
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{model.BackfillJobActive, model.BackfillJobPaused}}}},
+	}
+
+	res, err := s.backfillCollection.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: set}})
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount > 0 {
+		return nil
+	}
+
+	// the job is missing or done
+	if _, err = s.GetBackfillJob(ctx, id); err != nil {
+		return err
+	}
+
+	return types.ErrBackfillJobDone
+}
@@ -40,29 +40,67 @@ func (s *Storage) CreateBlock(ctx context.Context, block *model.Block) error {
 	return nil
 }
 
-func (s *Storage) SetProcessedStatus(ctx context.Context, height int64) error {
+func (s *Storage) SetProcessedStatus(ctx context.Context, height int64, owner string) error {
 	processed := time.Now()
-	filter := bson.D{{Key: "height", Value: height}}
 	update := bson.D{
 		{Key: "$set", Value: bson.D{
 			{Key: "status", Value: model.StatusProcessed},
 			{Key: "processed", Value: &processed},
 			{Key: "error_message", Value: ""},
+			{Key: "owner", Value: ""},
+			{Key: "lease_expires", Value: nil},
 		}}}
-	if _, err := s.blocksCollection.UpdateOne(ctx, filter, update); err != nil {
+
+	return s.updateOwnedBlock(ctx, height, owner, update)
+}
+
+func (s *Storage) SetErrorStatus(ctx context.Context, height int64, owner, msg string) error {
+	return s.updateOwnedBlock(ctx, height, owner, errorStatusUpdate(msg))
+}
+
+func (s *Storage) RollbackBlock(ctx context.Context, height int64, msg string) error {
+	filter := bson.D{{Key: "height", Value: height}}
+	if _, err := s.blocksCollection.UpdateOne(ctx, filter, errorStatusUpdate(msg)); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (s *Storage) SetErrorStatus(ctx context.Context, height int64, msg string) error {
-	filter := bson.D{{Key: "height", Value: height}}
-	update := bson.D{
+// updateOwnedBlock updates the block processing by the owner or returns types.ErrLeaseLost,
+// so a replica with an expired lease doesn't overwrite the status set by the new owner.
+func (s *Storage) updateOwnedBlock(ctx context.Context, height int64, owner string, update bson.D) error {
+	filter := bson.D{
+		{Key: "height", Value: height},
+		{Key: "owner", Value: owner},
+		{Key: "status", Value: model.StatusProcessing},
+	}
+
+	res, err := s.blocksCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return types.ErrLeaseLost
+	}
+
+	return nil
+}
+
+func errorStatusUpdate(msg string) bson.D {
+	return bson.D{
 		{Key: "$set", Value: bson.D{
 			{Key: "status", Value: model.StatusError},
 			{Key: "error_message", Value: msg},
+			{Key: "owner", Value: ""},
+			{Key: "lease_expires", Value: nil},
 		}}}
+}
+
+func (s *Storage) UpdateStatus(ctx context.Context, height int64, status model.Status) error {
+	filter := bson.D{{Key: "height", Value: height}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "status", Value: status}}}}
 	if _, err := s.blocksCollection.UpdateOne(ctx, filter, update); err != nil {
 		return err
 	}
@@ -70,9 +108,13 @@ func (s *Storage) SetErrorStatus(ctx context.Context, height int64, msg string)
 	return nil
 }
 
-func (s *Storage) UpdateStatus(ctx context.Context, height int64, status model.Status) error {
+func (s *Storage) SetBlockHash(ctx context.Context, height int64, hash, parentHash string) error {
 	filter := bson.D{{Key: "height", Value: height}}
-	update := bson.D{{Key: "$set", Value: bson.D{{Key: "status", Value: status}}}}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "hash", Value: hash},
+			{Key: "parent_hash", Value: parentHash},
+		}}}
 	if _, err := s.blocksCollection.UpdateOne(ctx, filter, update); err != nil {
 		return err
 	}
@@ -80,12 +122,60 @@ func (s *Storage) UpdateStatus(ctx context.Context, height int64, status model.S
 	return nil
 }
 
-func (s *Storage) GetErrorBlockHeights(ctx context.Context) ([]int64, error) {
+func (s *Storage) GetErrorBlocks(ctx context.Context) ([]*model.Block, error) {
 	cursor, err := s.blocksCollection.Find(ctx, bson.D{{Key: "status", Value: model.StatusError}})
 	if err != nil {
 		return nil, err
 	}
 
+	blocks := make([]*model.Block, 0)
+	if err = cursor.All(ctx, &blocks); err != nil {
+		return nil, err
+	}
+
+	return blocks, nil
+}
+
+func (s *Storage) GetCompletedHeights(ctx context.Context, after, limit int64) ([]int64, error) {
+	filter := bson.D{
+		{Key: "height", Value: bson.D{{Key: "$gt", Value: after}}},
+		{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{model.StatusProcessed, model.StatusSkipped}}}},
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "height", Value: 1}}).
+		SetLimit(limit).
+		SetProjection(bson.D{{Key: "height", Value: 1}})
+
+	cursor, err := s.blocksCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make([]model.Block, 0)
+	if err = cursor.All(ctx, &blocks); err != nil {
+		return nil, err
+	}
+
+	res := make([]int64, len(blocks))
+	for i, block := range blocks {
+		res[i] = block.Height
+	}
+
+	return res, nil
+}
+
+func (s *Storage) GetStoredHeights(ctx context.Context, after, limit int64) ([]int64, error) {
+	filter := bson.D{{Key: "height", Value: bson.D{{Key: "$gt", Value: after}}}}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "height", Value: 1}}).
+		SetLimit(limit).
+		SetProjection(bson.D{{Key: "height", Value: 1}})
+
+	cursor, err := s.blocksCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
 	blocks := make([]model.Block, 0)
 	if err = cursor.All(ctx, &blocks); err != nil {
 		return nil, err
@@ -132,11 +222,95 @@ func (s *Storage) GetLatestBlock(ctx context.Context) (*model.Block, error) {
 	return &block, err
 }
 
-func (s *Storage) setErrorStatusForProcessing(ctx context.Context) error {
-	filter := bson.D{{Key: "status", Value: model.StatusProcessing}}
+// ClaimBlock claims the block by a single findOneAndUpdate with upsert.
+// If the existing block doesn't match the filter, the upsert fails on the unique height index.
+func (s *Storage) ClaimBlock(ctx context.Context, height int64, owner string, lease time.Duration,
+	retryError bool) (*model.Block, error) {
+
+	now := time.Now()
+
+	claimable := bson.A{
+		bson.D{
+			{Key: "status", Value: model.StatusProcessing},
+			{Key: "$or", Value: bson.A{
+				bson.D{{Key: "lease_expires", Value: nil}},
+				bson.D{{Key: "lease_expires", Value: bson.D{{Key: "$lt", Value: now}}}},
+			}},
+		},
+	}
+	if retryError {
+		claimable = append(claimable, bson.D{{Key: "status", Value: model.StatusError}})
+	}
+
+	filter := bson.D{{Key: "height", Value: height}, {Key: "$or", Value: claimable}}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "status", Value: model.StatusProcessing},
+			{Key: "owner", Value: owner},
+			{Key: "lease_expires", Value: now.Add(lease)},
+			{Key: "last_attempt", Value: now},
+		}},
+		{Key: "$inc", Value: bson.D{{Key: "attempts", Value: 1}}},
+		{Key: "$setOnInsert", Value: bson.D{
+			{Key: "created", Value: now},
+			{Key: "error_message", Value: ""},
+		}},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var block model.Block
+
+	err := s.blocksCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&block)
+	if err == nil {
+		return &block, nil
+	}
+
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, err
+	}
+
+	// the block exists and is not claimable
+	current, err := s.GetBlockByHeight(ctx, height)
+	if err != nil {
+		return nil, err
+	}
+
+	return current, types.ErrBlockNotClaimed
+}
+
+func (s *Storage) RenewLease(ctx context.Context, height int64, owner string, lease time.Duration) error {
+	filter := bson.D{
+		{Key: "height", Value: height},
+		{Key: "status", Value: model.StatusProcessing},
+		{Key: "owner", Value: owner},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "lease_expires", Value: time.Now().Add(lease)}}}}
+
+	res, err := s.blocksCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return types.ErrLeaseLost
+	}
+
+	return nil
+}
+
+func (s *Storage) ReleaseExpiredBlocks(ctx context.Context) error {
+	filter := bson.D{
+		{Key: "status", Value: model.StatusProcessing},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "lease_expires", Value: nil}},
+			bson.D{{Key: "lease_expires", Value: bson.D{{Key: "$lt", Value: time.Now()}}}},
+		}},
+	}
 	update := bson.D{{Key: "$set", Value: bson.D{
 		{Key: "status", Value: model.StatusError},
 		{Key: "error_message", Value: "dont have time to process"},
+		{Key: "owner", Value: ""},
+		{Key: "lease_expires", Value: nil},
 	}}}
 
 	if _, err := s.blocksCollection.UpdateMany(ctx, filter, update); err != nil {
@@ -145,3 +319,59 @@ func (s *Storage) setErrorStatusForProcessing(ctx context.Context) error {
 
 	return nil
 }
+
+func (s *Storage) GetBlocksByStatus(ctx context.Context, status model.Status, offset, limit int64) ([]*model.Block,
+	error) {
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "height", Value: 1}}).
+		SetSkip(offset).
+		SetLimit(limit)
+
+	cursor, err := s.blocksCollection.Find(ctx, bson.D{{Key: "status", Value: status}}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make([]*model.Block, 0)
+	if err = cursor.All(ctx, &blocks); err != nil {
+		return nil, err
+	}
+
+	return blocks, nil
+}
+
+// DeleteBlocks deletes the blocks of the height range which are not processing right now,
+// so the heights are processed again from scratch.
+func (s *Storage) DeleteBlocks(ctx context.Context, from, to int64) (int64, error) {
+	filter := bson.D{
+		{Key: "height", Value: bson.D{{Key: "$gte", Value: from}, {Key: "$lte", Value: to}}},
+		{Key: "status", Value: bson.D{{Key: "$ne", Value: model.StatusProcessing}}},
+	}
+
+	res, err := s.blocksCollection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.DeletedCount, nil
+}
+
+// SkipBlock sets the skipped status of the block, creating it if needed. A lease of the block is revoked.
+func (s *Storage) SkipBlock(ctx context.Context, height int64) error {
+	filter := bson.D{{Key: "height", Value: height}}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "status", Value: model.StatusSkipped},
+			{Key: "owner", Value: ""},
+			{Key: "lease_expires", Value: nil},
+		}},
+		{Key: "$setOnInsert", Value: bson.D{
+			{Key: "created", Value: time.Now()},
+			{Key: "error_message", Value: ""},
+		}},
+	}
+
+	_, err := s.blocksCollection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
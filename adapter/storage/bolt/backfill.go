@@ -0,0 +1,129 @@
+package bolt
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"go.etcd.io/bbolt"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/model"
+	"github.com/bro-n-bro/spacebox-crawler/v2/types"
+)
+
+func (s *Storage) CreateBackfillJob(_ context.Context, job *model.BackfillJob) error {
+	data, err := jsoniter.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(backfillJobsBucket).Put([]byte(job.ID), data)
+	})
+}
+
+func (s *Storage) GetBackfillJob(_ context.Context, id string) (*model.BackfillJob, error) {
+	var job *model.BackfillJob
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(backfillJobsBucket).Get([]byte(id))
+		if data == nil {
+			return types.ErrBackfillJobNotFound
+		}
+
+		job = new(model.BackfillJob)
+		return jsoniter.Unmarshal(data, job)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func (s *Storage) GetBackfillJobs(_ context.Context) ([]*model.BackfillJob, error) {
+	jobs := make([]*model.BackfillJob, 0)
+
+	err := s.forEach(backfillJobsBucket, func(data []byte) error {
+		job := new(model.BackfillJob)
+		if err := jsoniter.Unmarshal(data, job); err != nil {
+			return err
+		}
+
+		jobs = append(jobs, job)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Created.Before(jobs[j].Created) })
+
+	return jobs, nil
+}
+
+// SetBackfillJobProgress moves the next height of the job forward.
+// The job is finished when all its heights are enqueued.
+func (s *Storage) SetBackfillJobProgress(_ context.Context, id string, next int64) error {
+	return s.updateBackfillJob(id, func(job *model.BackfillJob) error {
+		if next <= job.Next {
+			return nil
+		}
+
+		job.Next = next
+		if next > job.To && job.Status.IsActive() {
+			finished := time.Now()
+			job.Status = model.BackfillJobFinished
+			job.Finished = &finished
+		}
+
+		return nil
+	})
+}
+
+// SetBackfillJobStatus sets the status of the job or returns types.ErrBackfillJobDone if it is finished or cancelled.
+func (s *Storage) SetBackfillJobStatus(_ context.Context, id string, status model.BackfillJobStatus) error {
+	return s.updateBackfillJob(id, func(job *model.BackfillJob) error {
+		if job.Status.IsDone() {
+			return types.ErrBackfillJobDone
+		}
+
+		job.Status = status
+		if status.IsDone() {
+			finished := time.Now()
+			job.Finished = &finished
+		}
+
+		return nil
+	})
+}
+
+func (s *Storage) updateBackfillJob(id string, fn func(job *model.BackfillJob) error) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		var (
+			b    = tx.Bucket(backfillJobsBucket)
+			data = b.Get([]byte(id))
+			job  model.BackfillJob
+		)
+
+		if data == nil {
+			return types.ErrBackfillJobNotFound
+		}
+
+		if err := jsoniter.Unmarshal(data, &job); err != nil {
+			return err
+		}
+
+		if err := fn(&job); err != nil {
+			return err
+		}
+
+		data, err := jsoniter.Marshal(job)
+		if err != nil {
+			return err
+		}
+
+		return b.Put([]byte(id), data)
+	})
+}
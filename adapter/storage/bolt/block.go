@@ -0,0 +1,452 @@
+package bolt
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"go.etcd.io/bbolt"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/model"
+	"github.com/bro-n-bro/spacebox-crawler/v2/types"
+)
+
+func (s *Storage) GetBlockByHeight(_ context.Context, height int64) (*model.Block, error) {
+	var block *model.Block
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(blocksBucket).Get(itob(uint64(height)))
+		if data == nil {
+			return types.ErrBlockNotFound
+		}
+
+		block = new(model.Block)
+		return jsoniter.Unmarshal(data, block)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return block, nil
+}
+
+func (s *Storage) CreateBlock(_ context.Context, block *model.Block) error {
+	data, err := jsoniter.Marshal(block)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(blocksBucket).Put(itob(uint64(block.Height)), data)
+	})
+}
+
+func (s *Storage) SetProcessedStatus(_ context.Context, height int64, owner string) error {
+	processed := time.Now()
+
+	return s.updateOwnedBlock(height, owner, func(block *model.Block) {
+		block.Status = model.StatusProcessed
+		block.Processed = &processed
+		block.ErrorMessage = ""
+		block.Owner = ""
+		block.LeaseExpires = nil
+	})
+}
+
+func (s *Storage) SetErrorStatus(_ context.Context, height int64, owner, msg string) error {
+	return s.updateOwnedBlock(height, owner, func(block *model.Block) { setErrorStatus(block, msg) })
+}
+
+func (s *Storage) RollbackBlock(_ context.Context, height int64, msg string) error {
+	return s.updateBlock(height, func(block *model.Block) { setErrorStatus(block, msg) })
+}
+
+func setErrorStatus(block *model.Block, msg string) {
+	block.Status = model.StatusError
+	block.ErrorMessage = msg
+	block.Owner = ""
+	block.LeaseExpires = nil
+}
+
+func (s *Storage) UpdateStatus(_ context.Context, height int64, status model.Status) error {
+	return s.updateBlock(height, func(block *model.Block) {
+		block.Status = status
+	})
+}
+
+func (s *Storage) SetBlockHash(_ context.Context, height int64, hash, parentHash string) error {
+	return s.updateBlock(height, func(block *model.Block) {
+		block.Hash = hash
+		block.ParentHash = parentHash
+	})
+}
+
+func (s *Storage) GetErrorBlocks(_ context.Context) ([]*model.Block, error) {
+	res := make([]*model.Block, 0)
+
+	err := s.forEachBlock(func(block *model.Block) {
+		if block.Status.IsError() {
+			res = append(res, block)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (s *Storage) GetCompletedHeights(_ context.Context, after, limit int64) ([]int64, error) {
+	res := make([]int64, 0)
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(blocksBucket).Cursor()
+
+		// keys are sorted by height
+		for k, v := c.Seek(itob(uint64(after + 1))); k != nil && int64(len(res)) < limit; k, v = c.Next() {
+			var block model.Block
+			if err := jsoniter.Unmarshal(v, &block); err != nil {
+				return err
+			}
+
+			if block.Status.IsCompleted() {
+				res = append(res, block.Height)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (s *Storage) GetStoredHeights(_ context.Context, after, limit int64) ([]int64, error) {
+	res := make([]int64, 0)
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(blocksBucket).Cursor()
+
+		// keys are sorted by height
+		for k, _ := c.Seek(itob(uint64(after + 1))); k != nil && int64(len(res)) < limit; k, _ = c.Next() {
+			res = append(res, int64(binary.BigEndian.Uint64(k)))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (s *Storage) GetAllBlocks(_ context.Context) ([]*model.Block, error) {
+	blocks := make([]*model.Block, 0)
+
+	if err := s.forEachBlock(func(block *model.Block) { blocks = append(blocks, block) }); err != nil {
+		return nil, err
+	}
+
+	return blocks, nil
+}
+
+func (s *Storage) GetLatestBlock(_ context.Context) (*model.Block, error) {
+	var latest *model.Block
+
+	err := s.forEachBlock(func(block *model.Block) {
+		if latest == nil || block.Created.After(latest.Created) {
+			latest = block
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if latest == nil {
+		return nil, types.ErrBlockNotFound
+	}
+
+	return latest, nil
+}
+
+func (s *Storage) GetBlocksByStatus(_ context.Context, status model.Status, offset, limit int64) ([]*model.Block,
+	error) {
+
+	blocks := make([]*model.Block, 0)
+
+	// keys are sorted by height
+	err := s.forEachBlock(func(block *model.Block) {
+		if block.Status != status || int64(len(blocks)) >= limit {
+			return
+		}
+
+		if offset > 0 {
+			offset--
+			return
+		}
+
+		blocks = append(blocks, block)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return blocks, nil
+}
+
+// DeleteBlocks deletes the blocks of the height range which are not processing right now,
+// so the heights are processed again from scratch.
+func (s *Storage) DeleteBlocks(_ context.Context, from, to int64) (deleted int64, err error) {
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		var (
+			b    = tx.Bucket(blocksBucket)
+			keys = make([][]byte, 0)
+			c    = b.Cursor()
+		)
+
+		// the bucket must not be modified while iterating, so collect keys first
+		for k, v := c.Seek(itob(uint64(from))); k != nil && int64(binary.BigEndian.Uint64(k)) <= to; k, v = c.Next() {
+			var block model.Block
+			if err := jsoniter.Unmarshal(v, &block); err != nil {
+				return err
+			}
+
+			if !block.Status.IsProcessing() {
+				keys = append(keys, k)
+			}
+		}
+
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		deleted = int64(len(keys))
+		return nil
+	})
+
+	return deleted, err
+}
+
+// SkipBlock sets the skipped status of the block, creating it if needed. A lease of the block is revoked.
+func (s *Storage) SkipBlock(_ context.Context, height int64) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		var (
+			b     = tx.Bucket(blocksBucket)
+			key   = itob(uint64(height))
+			data  = b.Get(key)
+			block = model.Block{Height: height, Created: time.Now()}
+		)
+
+		if data != nil {
+			if err := jsoniter.Unmarshal(data, &block); err != nil {
+				return err
+			}
+		}
+
+		block.Status = model.StatusSkipped
+		block.Owner = ""
+		block.LeaseExpires = nil
+
+		data, err := jsoniter.Marshal(block)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(key, data)
+	})
+}
+
+func (s *Storage) ClaimBlock(_ context.Context, height int64, owner string, lease time.Duration,
+	retryError bool) (*model.Block, error) {
+
+	var (
+		now          = time.Now()
+		leaseExpires = now.Add(lease)
+		block        = &model.Block{Height: height, Created: now}
+	)
+
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		var (
+			b    = tx.Bucket(blocksBucket)
+			key  = itob(uint64(height))
+			data = b.Get(key)
+		)
+
+		if data != nil {
+			if err := jsoniter.Unmarshal(data, block); err != nil {
+				return err
+			}
+
+			if !block.IsClaimable(now, retryError) {
+				return types.ErrBlockNotClaimed
+			}
+		}
+
+		block.Status = model.StatusProcessing
+		block.Owner = owner
+		block.LeaseExpires = &leaseExpires
+		block.LastAttempt = &now
+		block.Attempts++
+
+		data, err := jsoniter.Marshal(block)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(key, data)
+	})
+	if err != nil && !errors.Is(err, types.ErrBlockNotClaimed) {
+		return nil, err
+	}
+
+	return block, err
+}
+
+func (s *Storage) RenewLease(_ context.Context, height int64, owner string, lease time.Duration) error {
+	leaseExpires := time.Now().Add(lease)
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		var (
+			b     = tx.Bucket(blocksBucket)
+			key   = itob(uint64(height))
+			data  = b.Get(key)
+			block model.Block
+		)
+
+		if data == nil {
+			return types.ErrLeaseLost
+		}
+
+		if err := jsoniter.Unmarshal(data, &block); err != nil {
+			return err
+		}
+
+		if !block.Status.IsProcessing() || block.Owner != owner {
+			return types.ErrLeaseLost
+		}
+
+		block.LeaseExpires = &leaseExpires
+
+		data, err := jsoniter.Marshal(block)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(key, data)
+	})
+}
+
+func (s *Storage) ReleaseExpiredBlocks(_ context.Context) error {
+	now := time.Now()
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		var (
+			b       = tx.Bucket(blocksBucket)
+			updates = make(map[string][]byte)
+		)
+
+		// the bucket must not be modified inside ForEach, so collect updates first
+		if err := b.ForEach(func(k, v []byte) error {
+			var block model.Block
+			if err := jsoniter.Unmarshal(v, &block); err != nil {
+				return err
+			}
+
+			if !block.IsLeaseExpired(now) {
+				return nil
+			}
+
+			block.Status = model.StatusError
+			block.ErrorMessage = "dont have time to process"
+			block.Owner = ""
+			block.LeaseExpires = nil
+
+			data, err := jsoniter.Marshal(block)
+			if err != nil {
+				return err
+			}
+
+			updates[string(k)] = data
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for k, data := range updates {
+			if err := b.Put([]byte(k), data); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// updateBlock applies fn to the stored block. Missing blocks are ignored, the same as an update without matches.
+func (s *Storage) updateBlock(height int64, fn func(block *model.Block)) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		var (
+			b     = tx.Bucket(blocksBucket)
+			key   = itob(uint64(height))
+			data  = b.Get(key)
+			block model.Block
+		)
+
+		if data == nil {
+			return nil
+		}
+
+		if err := jsoniter.Unmarshal(data, &block); err != nil {
+			return err
+		}
+
+		fn(&block)
+
+		data, err := jsoniter.Marshal(block)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(key, data)
+	})
+}
+
+// updateOwnedBlock applies fn to the block processing by the owner or returns types.ErrLeaseLost.
+func (s *Storage) updateOwnedBlock(height int64, owner string, fn func(block *model.Block)) error {
+	var owned bool
+
+	if err := s.updateBlock(height, func(block *model.Block) {
+		if owned = block.Status.IsProcessing() && block.Owner == owner; owned {
+			fn(block)
+		}
+	}); err != nil {
+		return err
+	}
+
+	if !owned {
+		return types.ErrLeaseLost
+	}
+
+	return nil
+}
+
+func (s *Storage) forEachBlock(fn func(block *model.Block)) error {
+	return s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(blocksBucket).ForEach(func(_, v []byte) error {
+			block := new(model.Block)
+			if err := jsoniter.Unmarshal(v, block); err != nil {
+				return err
+			}
+
+			fn(block)
+			return nil
+		})
+	})
+}
@@ -0,0 +1,8 @@
+package bolt
+
+import "time"
+
+type Config struct {
+	Path    string        `env:"BOLT_PATH" envDefault:"spacebox.db"`
+	Timeout time.Duration `env:"BOLT_OPEN_TIMEOUT" envDefault:"5s"`
+}
@@ -0,0 +1,39 @@
+package bolt
+
+import (
+	"context"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/model"
+)
+
+func (s *Storage) InsertErrorMessage(_ context.Context, message model.Message) error {
+	return s.insertSequential(messagesBucket, message)
+}
+
+func (s *Storage) CountErrorMessages(_ context.Context) (int64, error) {
+	return s.count(messagesBucket)
+}
+
+func (s *Storage) GetErrorMessages(_ context.Context, height int64) ([]model.Message, error) {
+	res := make([]model.Message, 0)
+
+	err := s.forEach(messagesBucket, func(data []byte) error {
+		var v model.Message
+		if err := jsoniter.Unmarshal(data, &v); err != nil {
+			return err
+		}
+
+		if v.Height == height {
+			res = append(res, v)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
@@ -0,0 +1,32 @@
+package bolt
+
+import (
+	"context"
+	"encoding/binary"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/types"
+)
+
+var keyFinalizedHeight = []byte("finalized_height")
+
+func (s *Storage) GetFinalizedHeight(_ context.Context) (height int64, err error) {
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(stateBucket).Get(keyFinalizedHeight)
+		if data == nil {
+			return types.ErrFinalizedHeightNotFound
+		}
+
+		height = int64(binary.BigEndian.Uint64(data))
+		return nil
+	})
+
+	return height, err
+}
+
+func (s *Storage) SetFinalizedHeight(_ context.Context, height int64) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(stateBucket).Put(keyFinalizedHeight, itob(uint64(height)))
+	})
+}
@@ -0,0 +1,118 @@
+package bolt
+
+import (
+	"context"
+	"encoding/binary"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/rs/zerolog"
+	"go.etcd.io/bbolt"
+)
+
+var (
+	blocksBucket   = []byte("blocks")
+	messagesBucket = []byte("error_messages")
+	txsBucket      = []byte("error_txs")
+	stateBucket    = []byte("state")
+
+	backfillJobsBucket = []byte("backfill_jobs")
+)
+
+// Storage is an embedded implementation of the block storage backed by a single bbolt file.
+type Storage struct {
+	log *zerolog.Logger
+	db  *bbolt.DB
+
+	cfg Config
+}
+
+func New(cfg Config, l zerolog.Logger) *Storage {
+	l = l.With().Str("cmp", "bolt").Logger()
+
+	return &Storage{
+		cfg: cfg,
+		log: &l,
+	}
+}
+
+func (s *Storage) Start(ctx context.Context) error {
+	db, err := bbolt.Open(s.cfg.Path, 0o600, &bbolt.Options{Timeout: s.cfg.Timeout})
+	if err != nil {
+		return err
+	}
+
+	s.db = db
+
+	if err = s.db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{blocksBucket, messagesBucket, txsBucket, stateBucket, backfillJobsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// set error status for unprocessed blocks
+	return s.ReleaseExpiredBlocks(ctx)
+}
+
+func (s *Storage) Stop(ctx context.Context) error {
+	s.log.Info().Msg("start ReleaseExpiredBlocks")
+
+	// set error status for unprocessed blocks
+	if err := s.ReleaseExpiredBlocks(ctx); err != nil {
+		s.log.Error().Err(err).Msg("ReleaseExpiredBlocks error")
+		return err
+	}
+
+	return s.db.Close()
+}
+
+// Ping checks that the database file is still opened.
+func (s *Storage) Ping(_ context.Context) error {
+	return s.db.View(func(*bbolt.Tx) error { return nil })
+}
+
+// insertSequential stores the value under the next sequence key of the bucket.
+func (s *Storage) insertSequential(bucket []byte, v interface{}) error {
+	data, err := jsoniter.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucket)
+
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		return b.Put(itob(id), data)
+	})
+}
+
+func (s *Storage) count(bucket []byte) (count int64, err error) {
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		count = int64(tx.Bucket(bucket).Stats().KeyN)
+		return nil
+	})
+
+	return count, err
+}
+
+// forEach calls fn for every value of the bucket in the key order.
+func (s *Storage) forEach(bucket []byte, fn func(data []byte) error) error {
+	return s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucket).ForEach(func(_, v []byte) error { return fn(v) })
+	})
+}
+
+// itob encodes an integer as a big endian key to keep keys sorted in numeric order.
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
@@ -0,0 +1,26 @@
+package bolt
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/storagetest"
+	"github.com/bro-n-bro/spacebox-crawler/v2/internal/rep"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) rep.Storage {
+		s := New(Config{Path: filepath.Join(t.TempDir(), "spacebox.db"), Timeout: time.Second}, zerolog.Nop())
+		if err := s.Start(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		t.Cleanup(func() { _ = s.Stop(context.Background()) })
+
+		return s
+	})
+}
@@ -0,0 +1,39 @@
+package bolt
+
+import (
+	"context"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/model"
+)
+
+func (s *Storage) InsertErrorTx(_ context.Context, tx model.Tx) error {
+	return s.insertSequential(txsBucket, tx)
+}
+
+func (s *Storage) CountErrorTxs(_ context.Context) (int64, error) {
+	return s.count(txsBucket)
+}
+
+func (s *Storage) GetErrorTxs(_ context.Context, height int64) ([]model.Tx, error) {
+	res := make([]model.Tx, 0)
+
+	err := s.forEach(txsBucket, func(data []byte) error {
+		var v model.Tx
+		if err := jsoniter.Unmarshal(data, &v); err != nil {
+			return err
+		}
+
+		if v.Height == height {
+			res = append(res, v)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
@@ -0,0 +1,92 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/model"
+	"github.com/bro-n-bro/spacebox-crawler/v2/types"
+)
+
+func (s *Storage) CreateBackfillJob(_ context.Context, job *model.BackfillJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j := *job
+	s.backfillJobs[job.ID] = &j
+
+	return nil
+}
+
+func (s *Storage) GetBackfillJob(_ context.Context, id string) (*model.BackfillJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	job, ok := s.backfillJobs[id]
+	if !ok {
+		return nil, types.ErrBackfillJobNotFound
+	}
+
+	res := *job
+	return &res, nil
+}
+
+func (s *Storage) GetBackfillJobs(_ context.Context) ([]*model.BackfillJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	jobs := make([]*model.BackfillJob, 0, len(s.backfillJobs))
+	for _, job := range s.backfillJobs {
+		j := *job
+		jobs = append(jobs, &j)
+	}
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Created.Before(jobs[j].Created) })
+
+	return jobs, nil
+}
+
+func (s *Storage) SetBackfillJobProgress(_ context.Context, id string, next int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.backfillJobs[id]
+	if !ok {
+		return types.ErrBackfillJobNotFound
+	}
+
+	if next <= job.Next {
+		return nil
+	}
+
+	job.Next = next
+	if next > job.To && job.Status.IsActive() {
+		finished := time.Now()
+		job.Status = model.BackfillJobFinished
+		job.Finished = &finished
+	}
+
+	return nil
+}
+
+func (s *Storage) SetBackfillJobStatus(_ context.Context, id string, status model.BackfillJobStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.backfillJobs[id]
+	switch {
+	case !ok:
+		return types.ErrBackfillJobNotFound
+	case job.Status.IsDone():
+		return types.ErrBackfillJobDone
+	}
+
+	job.Status = status
+	if status.IsDone() {
+		finished := time.Now()
+		job.Finished = &finished
+	}
+
+	return nil
+}
@@ -0,0 +1,419 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/model"
+	"github.com/bro-n-bro/spacebox-crawler/v2/types"
+)
+
+// Storage is an in-memory implementation of the block storage.
+// It keeps nothing between restarts and is intended for tests and dry runs.
+type Storage struct {
+	mu       sync.RWMutex
+	blocks   map[int64]*model.Block
+	messages []model.Message
+	txs      []model.Tx
+
+	backfillJobs map[string]*model.BackfillJob
+
+	finalizedHeight *int64
+}
+
+func New() *Storage {
+	return &Storage{
+		blocks:       make(map[int64]*model.Block),
+		backfillJobs: make(map[string]*model.BackfillJob),
+	}
+}
+
+func (s *Storage) Start(ctx context.Context) error { return s.ReleaseExpiredBlocks(ctx) }
+func (s *Storage) Stop(ctx context.Context) error  { return s.ReleaseExpiredBlocks(ctx) }
+func (s *Storage) Ping(_ context.Context) error    { return nil }
+
+func (s *Storage) GetBlockByHeight(_ context.Context, height int64) (*model.Block, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	block, ok := s.blocks[height]
+	if !ok {
+		return nil, types.ErrBlockNotFound
+	}
+
+	res := *block
+	return &res, nil
+}
+
+func (s *Storage) CreateBlock(_ context.Context, block *model.Block) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := *block
+	s.blocks[block.Height] = &b
+
+	return nil
+}
+
+func (s *Storage) SetProcessedStatus(_ context.Context, height int64, owner string) error {
+	processed := time.Now()
+
+	return s.updateOwnedBlock(height, owner, func(block *model.Block) {
+		block.Status = model.StatusProcessed
+		block.Processed = &processed
+		block.ErrorMessage = ""
+		block.Owner = ""
+		block.LeaseExpires = nil
+	})
+}
+
+func (s *Storage) SetErrorStatus(_ context.Context, height int64, owner, msg string) error {
+	return s.updateOwnedBlock(height, owner, func(block *model.Block) { setErrorStatus(block, msg) })
+}
+
+func (s *Storage) RollbackBlock(_ context.Context, height int64, msg string) error {
+	s.updateBlock(height, func(block *model.Block) { setErrorStatus(block, msg) })
+
+	return nil
+}
+
+func setErrorStatus(block *model.Block, msg string) {
+	block.Status = model.StatusError
+	block.ErrorMessage = msg
+	block.Owner = ""
+	block.LeaseExpires = nil
+}
+
+func (s *Storage) UpdateStatus(_ context.Context, height int64, status model.Status) error {
+	s.updateBlock(height, func(block *model.Block) {
+		block.Status = status
+	})
+
+	return nil
+}
+
+func (s *Storage) SetBlockHash(_ context.Context, height int64, hash, parentHash string) error {
+	s.updateBlock(height, func(block *model.Block) {
+		block.Hash = hash
+		block.ParentHash = parentHash
+	})
+
+	return nil
+}
+
+func (s *Storage) GetErrorBlocks(_ context.Context) ([]*model.Block, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]*model.Block, 0)
+	for _, block := range s.blocks {
+		if block.Status.IsError() {
+			b := *block
+			res = append(res, &b)
+		}
+	}
+
+	return res, nil
+}
+
+func (s *Storage) GetCompletedHeights(_ context.Context, after, limit int64) ([]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]int64, 0)
+	for height, block := range s.blocks {
+		if height > after && block.Status.IsCompleted() {
+			res = append(res, height)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+
+	if int64(len(res)) > limit {
+		res = res[:limit]
+	}
+
+	return res, nil
+}
+
+func (s *Storage) GetStoredHeights(_ context.Context, after, limit int64) ([]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]int64, 0)
+	for height := range s.blocks {
+		if height > after {
+			res = append(res, height)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+
+	if int64(len(res)) > limit {
+		res = res[:limit]
+	}
+
+	return res, nil
+}
+
+func (s *Storage) GetFinalizedHeight(_ context.Context) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.finalizedHeight == nil {
+		return 0, types.ErrFinalizedHeightNotFound
+	}
+
+	return *s.finalizedHeight, nil
+}
+
+func (s *Storage) SetFinalizedHeight(_ context.Context, height int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.finalizedHeight = &height
+
+	return nil
+}
+
+func (s *Storage) GetAllBlocks(_ context.Context) ([]*model.Block, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	blocks := make([]*model.Block, 0, len(s.blocks))
+	for _, block := range s.blocks {
+		b := *block
+		blocks = append(blocks, &b)
+	}
+
+	return blocks, nil
+}
+
+func (s *Storage) GetLatestBlock(_ context.Context) (*model.Block, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var latest *model.Block
+	for _, block := range s.blocks {
+		if latest == nil || block.Created.After(latest.Created) {
+			latest = block
+		}
+	}
+
+	if latest == nil {
+		return nil, types.ErrBlockNotFound
+	}
+
+	res := *latest
+	return &res, nil
+}
+
+func (s *Storage) InsertErrorMessage(_ context.Context, message model.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages = append(s.messages, message)
+
+	return nil
+}
+
+func (s *Storage) CountErrorMessages(_ context.Context) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return int64(len(s.messages)), nil
+}
+
+func (s *Storage) InsertErrorTx(_ context.Context, tx model.Tx) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.txs = append(s.txs, tx)
+
+	return nil
+}
+
+func (s *Storage) CountErrorTxs(_ context.Context) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return int64(len(s.txs)), nil
+}
+
+func (s *Storage) GetBlocksByStatus(_ context.Context, status model.Status, offset, limit int64) ([]*model.Block,
+	error) {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	blocks := make([]*model.Block, 0)
+	for _, block := range s.blocks {
+		if block.Status == status {
+			b := *block
+			blocks = append(blocks, &b)
+		}
+	}
+
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].Height < blocks[j].Height })
+
+	if offset >= int64(len(blocks)) {
+		return []*model.Block{}, nil
+	}
+
+	blocks = blocks[offset:]
+	if int64(len(blocks)) > limit {
+		blocks = blocks[:limit]
+	}
+
+	return blocks, nil
+}
+
+func (s *Storage) DeleteBlocks(_ context.Context, from, to int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for height, block := range s.blocks {
+		if height >= from && height <= to && !block.Status.IsProcessing() {
+			delete(s.blocks, height)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+func (s *Storage) SkipBlock(_ context.Context, height int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	block, ok := s.blocks[height]
+	if !ok {
+		block = &model.Block{Height: height, Created: time.Now()}
+		s.blocks[height] = block
+	}
+
+	block.Status = model.StatusSkipped
+	block.Owner = ""
+	block.LeaseExpires = nil
+
+	return nil
+}
+
+func (s *Storage) GetErrorMessages(_ context.Context, height int64) ([]model.Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]model.Message, 0)
+	for _, message := range s.messages {
+		if message.Height == height {
+			res = append(res, message)
+		}
+	}
+
+	return res, nil
+}
+
+func (s *Storage) GetErrorTxs(_ context.Context, height int64) ([]model.Tx, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]model.Tx, 0)
+	for _, tx := range s.txs {
+		if tx.Height == height {
+			res = append(res, tx)
+		}
+	}
+
+	return res, nil
+}
+
+func (s *Storage) ClaimBlock(_ context.Context, height int64, owner string, lease time.Duration,
+	retryError bool) (*model.Block, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var (
+		now          = time.Now()
+		leaseExpires = now.Add(lease)
+	)
+
+	block, ok := s.blocks[height]
+	switch {
+	case !ok:
+		block = &model.Block{Height: height, Created: now}
+		s.blocks[height] = block
+	case !block.IsClaimable(now, retryError):
+		res := *block
+		return &res, types.ErrBlockNotClaimed
+	}
+
+	block.Status = model.StatusProcessing
+	block.Owner = owner
+	block.LeaseExpires = &leaseExpires
+	block.LastAttempt = &now
+	block.Attempts++
+
+	res := *block
+	return &res, nil
+}
+
+func (s *Storage) RenewLease(_ context.Context, height int64, owner string, lease time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	block, ok := s.blocks[height]
+	if !ok || !block.Status.IsProcessing() || block.Owner != owner {
+		return types.ErrLeaseLost
+	}
+
+	leaseExpires := time.Now().Add(lease)
+	block.LeaseExpires = &leaseExpires
+
+	return nil
+}
+
+func (s *Storage) ReleaseExpiredBlocks(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, block := range s.blocks {
+		if block.IsLeaseExpired(now) {
+			block.Status = model.StatusError
+			block.ErrorMessage = "dont have time to process"
+			block.Owner = ""
+			block.LeaseExpires = nil
+		}
+	}
+
+	return nil
+}
+
+// updateOwnedBlock applies fn to the block processing by the owner or returns types.ErrLeaseLost.
+func (s *Storage) updateOwnedBlock(height int64, owner string, fn func(block *model.Block)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	block, ok := s.blocks[height]
+	if !ok || !block.Status.IsProcessing() || block.Owner != owner {
+		return types.ErrLeaseLost
+	}
+
+	fn(block)
+
+	return nil
+}
+
+// updateBlock applies fn to the stored block. Missing blocks are ignored, the same as an update without matches.
+func (s *Storage) updateBlock(height int64, fn func(block *model.Block)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if block, ok := s.blocks[height]; ok {
+		fn(block)
+	}
+}
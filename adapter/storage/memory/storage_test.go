@@ -0,0 +1,12 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/storagetest"
+	"github.com/bro-n-bro/spacebox-crawler/v2/internal/rep"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(*testing.T) rep.Storage { return New() })
+}
@@ -19,3 +19,17 @@ func (s *Storage) InsertErrorMessage(ctx context.Context, message model.Message)
 func (s *Storage) CountErrorMessages(ctx context.Context) (int64, error) {
 	return s.messagesCollection.CountDocuments(ctx, bson.D{})
 }
+
+func (s *Storage) GetErrorMessages(ctx context.Context, height int64) ([]model.Message, error) {
+	cursor, err := s.messagesCollection.Find(ctx, bson.D{{Key: "height", Value: height}})
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]model.Message, 0)
+	if err = cursor.All(ctx, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
@@ -0,0 +1,62 @@
+package model
+
+import (
+	"log"
+	"time"
+)
+
+const (
+	BackfillJobActive BackfillJobStatus = 1 + iota
+	BackfillJobPaused
+	BackfillJobFinished
+	BackfillJobCancelled
+)
+
+type (
+	BackfillJobStatus uint8
+
+	// BackfillJob is the height range processed by the worker alongside the live blocks.
+	BackfillJob struct {
+		Created  time.Time  `bson:"created"`
+		Finished *time.Time `bson:"finished"`
+		ID       string     `bson:"_id"`
+		From     int64      `bson:"from"`
+		To       int64      `bson:"to"`
+		// Next is the lowest height of the job which is not completed yet, the heights below are processed or skipped
+		Next     int64             `bson:"next"`
+		Priority int               `bson:"priority"` // jobs with a higher priority are processed first
+		Status   BackfillJobStatus `bson:"status"`
+	}
+)
+
+func (s BackfillJobStatus) ToString() string {
+	switch s {
+	case BackfillJobActive:
+		return "active"
+	case BackfillJobPaused:
+		return "paused"
+	case BackfillJobFinished:
+		return "finished"
+	case BackfillJobCancelled:
+		return "cancelled"
+	}
+
+	log.Fatalf("unknown backfill job status:%v", s)
+	return ""
+}
+
+func (s BackfillJobStatus) IsActive() bool { return s == BackfillJobActive }
+
+// IsDone checks whether the job is finished or cancelled, so it can't be resumed.
+func (s BackfillJobStatus) IsDone() bool {
+	return s == BackfillJobFinished || s == BackfillJobCancelled
+}
+
+// Progress returns the share of the completed heights of the job.
+func (j BackfillJob) Progress() float64 {
+	if j.To < j.From {
+		return 1
+	}
+
+	return float64(j.Next-j.From) / float64(j.To-j.From+1)
+}
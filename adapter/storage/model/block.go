@@ -9,6 +9,10 @@ const (
 	StatusProcessing Status = 1 + iota
 	StatusProcessed
 	StatusError
+	// StatusFailed is the terminal status of the block which exceeded the max processing attempts
+	StatusFailed
+	// StatusSkipped is the terminal status of the block which is skipped by the operator
+	StatusSkipped
 )
 
 type (
@@ -16,9 +20,15 @@ type (
 
 	Block struct {
 		Processed    *time.Time `bson:"processed"`
+		LeaseExpires *time.Time `bson:"lease_expires"`
+		LastAttempt  *time.Time `bson:"last_attempt"`
 		Created      time.Time
 		ErrorMessage string `bson:"error_message"`
+		Owner        string `bson:"owner"`
+		Hash         string `bson:"hash"`
+		ParentHash   string `bson:"parent_hash"`
 		Height       int64  `bson:"height"`
+		Attempts     int    `bson:"attempts"`
 		Status       Status
 	}
 )
@@ -31,6 +41,10 @@ func (s Status) ToString() string {
 		return "processed"
 	case StatusError:
 		return "error"
+	case StatusFailed:
+		return "failed"
+	case StatusSkipped:
+		return "skipped"
 	}
 
 	log.Fatalf("uncnown status:%v", s)
@@ -40,3 +54,49 @@ func (s Status) ToString() string {
 func (s Status) IsProcessing() bool { return s == StatusProcessing }
 func (s Status) IsProcessed() bool  { return s == StatusProcessed }
 func (s Status) IsError() bool      { return s == StatusError }
+func (s Status) IsFailed() bool     { return s == StatusFailed }
+func (s Status) IsSkipped() bool    { return s == StatusSkipped }
+
+// IsCompleted checks whether the height is done: processed or skipped by the operator.
+func (s Status) IsCompleted() bool { return s.IsProcessed() || s.IsSkipped() }
+
+// ParseStatus returns the status by its name.
+func ParseStatus(name string) (Status, bool) {
+	for s := StatusProcessing; s <= StatusSkipped; s++ {
+		if s.ToString() == name {
+			return s, true
+		}
+	}
+
+	return 0, false
+}
+
+// IsLeaseExpired checks whether the block is processing without a valid lease,
+// i.e. the replica which claimed it is gone.
+func (b Block) IsLeaseExpired(now time.Time) bool {
+	return b.Status.IsProcessing() && (b.LeaseExpires == nil || b.LeaseExpires.Before(now))
+}
+
+// IsClaimable checks whether the block can be claimed for processing.
+func (b Block) IsClaimable(now time.Time, retryError bool) bool {
+	return b.IsLeaseExpired(now) || (retryError && b.Status.IsError())
+}
+
+// NextAttempt returns the time of the next processing attempt of the error block.
+// The delay after the last attempt doubles with every attempt starting from base and is limited by maxDelay.
+func (b Block) NextAttempt(base, maxDelay time.Duration) time.Time {
+	if b.LastAttempt == nil || b.Attempts == 0 {
+		return time.Time{}
+	}
+
+	delay := base
+	for i := 1; i < b.Attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+
+	return b.LastAttempt.Add(delay)
+}
@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/model"
+	"github.com/bro-n-bro/spacebox-crawler/v2/types"
+)
+
+const backfillJobColumns = `id, from_height, to_height, next_height, priority, status, created, finished`
+
+func (s *Storage) CreateBackfillJob(ctx context.Context, job *model.BackfillJob) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO backfill_jobs (`+backfillJobColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		job.ID, job.From, job.To, job.Next, job.Priority, job.Status, job.Created, job.Finished,
+	)
+
+	return err
+}
+
+func (s *Storage) GetBackfillJob(ctx context.Context, id string) (*model.BackfillJob, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+backfillJobColumns+` FROM backfill_jobs WHERE id = $1`, id)
+
+	job, err := scanBackfillJob(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, types.ErrBackfillJobNotFound
+	}
+
+	return job, err
+}
+
+func (s *Storage) GetBackfillJobs(ctx context.Context) ([]*model.BackfillJob, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+backfillJobColumns+` FROM backfill_jobs ORDER BY created`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := make([]*model.BackfillJob, 0)
+	for rows.Next() {
+		job, err := scanBackfillJob(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// SetBackfillJobProgress moves the next height of the job forward.
+// The job is finished when all its heights are enqueued.
+func (s *Storage) SetBackfillJobProgress(ctx context.Context, id string, next int64) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE backfill_jobs SET next_height = $1,
+status = CASE WHEN $1 > to_height AND status = $2 THEN $3 ELSE status END,
+finished = CASE WHEN $1 > to_height AND status = $2 THEN $4 ELSE finished END
+WHERE id = $5 AND next_height < $1`,
+		next, model.BackfillJobActive, model.BackfillJobFinished, time.Now(), id,
+	)
+
+	return err
+}
+
+// SetBackfillJobStatus sets the status of the job or returns types.ErrBackfillJobDone if it is finished or cancelled.
+func (s *Storage) SetBackfillJobStatus(ctx context.Context, id string, status model.BackfillJobStatus) error {
+	var finished *time.Time
+	if status.IsDone() {
+		now := time.Now()
+		finished = &now
+	}
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE backfill_jobs SET status = $1, finished = $2 WHERE id = $3 AND status IN ($4, $5)`,
+		status, finished, id, model.BackfillJobActive, model.BackfillJobPaused,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil || affected > 0 {
+		return err
+	}
+
+	// the job is missing or done
+	if _, err = s.GetBackfillJob(ctx, id); err != nil {
+		return err
+	}
+
+	return types.ErrBackfillJobDone
+}
+
+// scanBackfillJob scans a single row of backfill_jobs table selected by backfillJobColumns.
+func scanBackfillJob(row interface{ Scan(dest ...any) error }) (*model.BackfillJob, error) {
+	var (
+		job      model.BackfillJob
+		finished sql.NullTime
+	)
+
+	if err := row.Scan(&job.ID, &job.From, &job.To, &job.Next, &job.Priority, &job.Status, &job.Created,
+		&finished); err != nil {
+		return nil, err
+	}
+
+	if finished.Valid {
+		job.Finished = &finished.Time
+	}
+
+	return &job, nil
+}
@@ -0,0 +1,318 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/model"
+	"github.com/bro-n-bro/spacebox-crawler/v2/types"
+)
+
+const blockColumns = `height, status, error_message, created, processed, owner, lease_expires, hash, parent_hash,
+attempts, last_attempt`
+
+func (s *Storage) GetBlockByHeight(ctx context.Context, height int64) (*model.Block, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+blockColumns+` FROM blocks WHERE height = $1`, height)
+
+	block, err := scanBlock(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, types.ErrBlockNotFound
+	}
+
+	return block, err
+}
+
+func (s *Storage) CreateBlock(ctx context.Context, block *model.Block) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO blocks (`+blockColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		block.Height, block.Status, block.ErrorMessage, block.Created, block.Processed, block.Owner, block.LeaseExpires,
+		block.Hash, block.ParentHash, block.Attempts, block.LastAttempt,
+	)
+
+	return err
+}
+
+func (s *Storage) SetProcessedStatus(ctx context.Context, height int64, owner string) error {
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE blocks SET status = $1, processed = $2, error_message = '', owner = '', lease_expires = NULL
+WHERE height = $3 AND owner = $4 AND status = $5`,
+		model.StatusProcessed, time.Now(), height, owner, model.StatusProcessing,
+	)
+
+	return leaseResult(res, err)
+}
+
+func (s *Storage) SetErrorStatus(ctx context.Context, height int64, owner, msg string) error {
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE blocks SET status = $1, error_message = $2, owner = '', lease_expires = NULL
+WHERE height = $3 AND owner = $4 AND status = $5`,
+		model.StatusError, msg, height, owner, model.StatusProcessing,
+	)
+
+	return leaseResult(res, err)
+}
+
+func (s *Storage) RollbackBlock(ctx context.Context, height int64, msg string) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE blocks SET status = $1, error_message = $2, owner = '', lease_expires = NULL WHERE height = $3`,
+		model.StatusError, msg, height,
+	)
+
+	return err
+}
+
+func (s *Storage) UpdateStatus(ctx context.Context, height int64, status model.Status) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE blocks SET status = $1 WHERE height = $2`, status, height)
+
+	return err
+}
+
+func (s *Storage) SetBlockHash(ctx context.Context, height int64, hash, parentHash string) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE blocks SET hash = $1, parent_hash = $2 WHERE height = $3`, hash, parentHash, height,
+	)
+
+	return err
+}
+
+func (s *Storage) GetErrorBlocks(ctx context.Context) ([]*model.Block, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+blockColumns+` FROM blocks WHERE status = $1`, model.StatusError)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blocks := make([]*model.Block, 0)
+	for rows.Next() {
+		block, err := scanBlock(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		blocks = append(blocks, block)
+	}
+
+	return blocks, rows.Err()
+}
+
+func (s *Storage) GetCompletedHeights(ctx context.Context, after, limit int64) ([]int64, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT height FROM blocks WHERE status IN ($1, $2) AND height > $3 ORDER BY height LIMIT $4`,
+		model.StatusProcessed, model.StatusSkipped, after, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]int64, 0)
+	for rows.Next() {
+		var height int64
+		if err = rows.Scan(&height); err != nil {
+			return nil, err
+		}
+
+		res = append(res, height)
+	}
+
+	return res, rows.Err()
+}
+
+func (s *Storage) GetStoredHeights(ctx context.Context, after, limit int64) ([]int64, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT height FROM blocks WHERE height > $1 ORDER BY height LIMIT $2`,
+		after, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]int64, 0)
+	for rows.Next() {
+		var height int64
+		if err = rows.Scan(&height); err != nil {
+			return nil, err
+		}
+
+		res = append(res, height)
+	}
+
+	return res, rows.Err()
+}
+
+func (s *Storage) GetAllBlocks(ctx context.Context) ([]*model.Block, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+blockColumns+` FROM blocks`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blocks := make([]*model.Block, 0)
+	for rows.Next() {
+		block, err := scanBlock(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		blocks = append(blocks, block)
+	}
+
+	return blocks, rows.Err()
+}
+
+func (s *Storage) GetLatestBlock(ctx context.Context) (*model.Block, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+blockColumns+` FROM blocks ORDER BY created DESC LIMIT 1`)
+
+	block, err := scanBlock(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, types.ErrBlockNotFound
+	}
+
+	return block, err
+}
+
+func (s *Storage) GetBlocksByStatus(ctx context.Context, status model.Status, offset, limit int64) ([]*model.Block,
+	error) {
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+blockColumns+` FROM blocks WHERE status = $1 ORDER BY height OFFSET $2 LIMIT $3`,
+		status, offset, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blocks := make([]*model.Block, 0)
+	for rows.Next() {
+		block, err := scanBlock(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		blocks = append(blocks, block)
+	}
+
+	return blocks, rows.Err()
+}
+
+// DeleteBlocks deletes the blocks of the height range which are not processing right now,
+// so the heights are processed again from scratch.
+func (s *Storage) DeleteBlocks(ctx context.Context, from, to int64) (int64, error) {
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM blocks WHERE height >= $1 AND height <= $2 AND status <> $3`, from, to, model.StatusProcessing,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// SkipBlock sets the skipped status of the block, creating it if needed. A lease of the block is revoked.
+func (s *Storage) SkipBlock(ctx context.Context, height int64) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO blocks (height, status, error_message, created) VALUES ($1, $2, '', $3)
+ON CONFLICT (height) DO UPDATE SET status = EXCLUDED.status, owner = '', lease_expires = NULL`,
+		height, model.StatusSkipped, time.Now(),
+	)
+
+	return err
+}
+
+// ClaimBlock inserts the block or takes over the existing one in a single upsert statement.
+// The unique height key guarantees that only one replica wins the claim.
+func (s *Storage) ClaimBlock(ctx context.Context, height int64, owner string, lease time.Duration,
+	retryError bool) (*model.Block, error) {
+
+	now := time.Now()
+	row := s.db.QueryRowContext(ctx, `INSERT INTO blocks (height, status, error_message, created, owner, lease_expires,
+attempts, last_attempt)
+VALUES ($1, $2, '', $3, $4, $5, 1, $3)
+ON CONFLICT (height) DO UPDATE SET status = EXCLUDED.status, owner = EXCLUDED.owner, lease_expires = EXCLUDED.lease_expires,
+attempts = blocks.attempts + 1, last_attempt = EXCLUDED.last_attempt
+WHERE (blocks.status = $2 AND (blocks.lease_expires IS NULL OR blocks.lease_expires < $3))
+   OR (blocks.status = $6 AND $7)
+RETURNING `+blockColumns,
+		height, model.StatusProcessing, now, owner, now.Add(lease), model.StatusError, retryError,
+	)
+
+	block, err := scanBlock(row)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return block, err
+	}
+
+	// the block exists and is not claimable
+	if block, err = s.GetBlockByHeight(ctx, height); err != nil {
+		return nil, err
+	}
+
+	return block, types.ErrBlockNotClaimed
+}
+
+func (s *Storage) RenewLease(ctx context.Context, height int64, owner string, lease time.Duration) error {
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE blocks SET lease_expires = $1 WHERE height = $2 AND status = $3 AND owner = $4`,
+		time.Now().Add(lease), height, model.StatusProcessing, owner,
+	)
+
+	return leaseResult(res, err)
+}
+
+// leaseResult returns types.ErrLeaseLost if the update of the owned block matched no rows.
+func leaseResult(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return types.ErrLeaseLost
+	}
+
+	return nil
+}
+
+func (s *Storage) ReleaseExpiredBlocks(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE blocks SET status = $1, error_message = $2, owner = '', lease_expires = NULL
+WHERE status = $3 AND (lease_expires IS NULL OR lease_expires < $4)`,
+		model.StatusError, "dont have time to process", model.StatusProcessing, time.Now(),
+	)
+
+	return err
+}
+
+// scanBlock scans a single row of blocks table selected by blockColumns.
+func scanBlock(row interface{ Scan(dest ...any) error }) (*model.Block, error) {
+	var (
+		block                   model.Block
+		processed, leaseExpires sql.NullTime
+		lastAttempt             sql.NullTime
+	)
+
+	if err := row.Scan(&block.Height, &block.Status, &block.ErrorMessage, &block.Created, &processed,
+		&block.Owner, &leaseExpires, &block.Hash, &block.ParentHash, &block.Attempts, &lastAttempt); err != nil {
+		return nil, err
+	}
+
+	if processed.Valid {
+		block.Processed = &processed.Time
+	}
+
+	if leaseExpires.Valid {
+		block.LeaseExpires = &leaseExpires.Time
+	}
+
+	if lastAttempt.Valid {
+		block.LastAttempt = &lastAttempt.Time
+	}
+
+	return &block, nil
+}
@@ -0,0 +1,10 @@
+package postgres
+
+type Config struct {
+	URI            string `env:"POSTGRES_CRAWLER_URI"`
+	User           string `env:"POSTGRES_USER"`
+	Password       string `env:"POSTGRES_PASSWORD"`
+	MaxOpenConns   int    `env:"POSTGRES_MAX_OPEN_CONNS" envDefault:"8"`
+	MaxIdleConns   int    `env:"POSTGRES_MAX_IDLE_CONNS" envDefault:"8"`
+	MetricsEnabled bool   `env:"METRICS_ENABLED" envDefault:"false"`
+}
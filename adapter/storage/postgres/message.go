@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/model"
+)
+
+func (s *Storage) InsertErrorMessage(ctx context.Context, message model.Message) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO error_messages (height, error_message, created) VALUES ($1, $2, $3)`,
+		message.Height, message.ErrorMessage, message.Created,
+	)
+
+	return err
+}
+
+func (s *Storage) CountErrorMessages(ctx context.Context) (count int64, err error) {
+	err = s.db.QueryRowContext(ctx, `SELECT count(*) FROM error_messages`).Scan(&count)
+	return count, err
+}
+
+func (s *Storage) GetErrorMessages(ctx context.Context, height int64) ([]model.Message, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT height, error_message, created FROM error_messages WHERE height = $1 ORDER BY id`, height,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]model.Message, 0)
+	for rows.Next() {
+		var message model.Message
+		if err = rows.Scan(&message.Height, &message.ErrorMessage, &message.Created); err != nil {
+			return nil, err
+		}
+
+		res = append(res, message)
+	}
+
+	return res, rows.Err()
+}
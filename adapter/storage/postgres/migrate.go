@@ -0,0 +1,85 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+)
+
+// migrationsLockID is the key of the advisory lock which serializes the migrations of the replicas.
+const migrationsLockID = 0x73706163656278 // "spacebx"
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrate applies all embedded migrations which are not applied yet in lexical order of the file names.
+// The migrations are applied in one transaction under the advisory lock, so the replicas starting at once
+// wait for each other and the migrations are applied only once.
+func (s *Storage) migrate(ctx context.Context) error {
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(names)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	// the lock is released with the end of the transaction
+	if _, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationsLockID); err != nil {
+		return fmt.Errorf("failed to lock migrations: %w", err)
+	}
+
+	if _, err = tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations
+(
+    version TEXT PRIMARY KEY,
+    applied TIMESTAMPTZ NOT NULL DEFAULT now()
+)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	for _, name := range names {
+		if err = s.applyMigration(ctx, tx, name); err != nil {
+			return fmt.Errorf("failed to apply migration %q: %w", name, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *Storage) applyMigration(ctx context.Context, tx *sql.Tx, name string) error {
+	query, err := migrations.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	var applied bool
+	if err = tx.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = $1)`, name,
+	).Scan(&applied); err != nil {
+		return err
+	}
+
+	if applied {
+		return nil
+	}
+
+	if _, err = tx.ExecContext(ctx, string(query)); err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, name); err != nil {
+		return err
+	}
+
+	s.log.Info().Str("version", name).Msg("migration applied")
+
+	return nil
+}
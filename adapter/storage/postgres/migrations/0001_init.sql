@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS blocks
+(
+    height        BIGINT PRIMARY KEY,
+    status        SMALLINT    NOT NULL,
+    error_message TEXT        NOT NULL DEFAULT '',
+    created       TIMESTAMPTZ NOT NULL,
+    processed     TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS blocks_status_idx ON blocks (status);
+CREATE INDEX IF NOT EXISTS blocks_created_idx ON blocks (created);
+
+CREATE TABLE IF NOT EXISTS error_messages
+(
+    id            BIGSERIAL PRIMARY KEY,
+    height        BIGINT      NOT NULL,
+    error_message TEXT        NOT NULL,
+    created       TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS error_txs
+(
+    id            BIGSERIAL PRIMARY KEY,
+    hash          TEXT        NOT NULL,
+    height        BIGINT      NOT NULL,
+    error_message TEXT        NOT NULL,
+    created       TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,3 @@
+ALTER TABLE blocks
+    ADD COLUMN IF NOT EXISTS owner         TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS lease_expires TIMESTAMPTZ;
@@ -0,0 +1,3 @@
+ALTER TABLE blocks
+    ADD COLUMN IF NOT EXISTS hash        TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS parent_hash TEXT NOT NULL DEFAULT '';
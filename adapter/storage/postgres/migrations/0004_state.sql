@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS state
+(
+    key    TEXT PRIMARY KEY,
+    height BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS blocks_status_height_idx ON blocks (status, height);
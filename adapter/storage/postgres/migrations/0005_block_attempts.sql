@@ -0,0 +1,3 @@
+ALTER TABLE blocks
+    ADD COLUMN IF NOT EXISTS attempts     INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_attempt TIMESTAMPTZ;
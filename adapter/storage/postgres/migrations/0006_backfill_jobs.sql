@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS backfill_jobs
+(
+    id          TEXT PRIMARY KEY,
+    from_height BIGINT      NOT NULL,
+    to_height   BIGINT      NOT NULL,
+    next_height BIGINT      NOT NULL,
+    priority    INTEGER     NOT NULL DEFAULT 0,
+    status      SMALLINT    NOT NULL,
+    created     TIMESTAMPTZ NOT NULL,
+    finished    TIMESTAMPTZ
+);
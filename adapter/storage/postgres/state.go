@@ -0,0 +1,29 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/types"
+)
+
+const keyFinalizedHeight = "finalized_height"
+
+func (s *Storage) GetFinalizedHeight(ctx context.Context) (height int64, err error) {
+	err = s.db.QueryRowContext(ctx, `SELECT height FROM state WHERE key = $1`, keyFinalizedHeight).Scan(&height)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, types.ErrFinalizedHeightNotFound
+	}
+
+	return height, err
+}
+
+func (s *Storage) SetFinalizedHeight(ctx context.Context, height int64) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO state (key, height) VALUES ($1, $2) ON CONFLICT (key) DO UPDATE SET height = EXCLUDED.height`,
+		keyFinalizedHeight, height,
+	)
+
+	return err
+}
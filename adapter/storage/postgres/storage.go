@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+
+	_ "github.com/lib/pq" // postgres driver
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/rs/zerolog"
+)
+
+const driverName = "postgres"
+
+type Storage struct {
+	log *zerolog.Logger
+	db  *sql.DB
+
+	cfg Config
+}
+
+func New(cfg Config, l zerolog.Logger) *Storage {
+	l = l.With().Str("cmp", "postgres").Logger()
+
+	return &Storage{
+		cfg: cfg,
+		log: &l,
+	}
+}
+
+func (s *Storage) Start(ctx context.Context) error {
+	dsn, err := s.dsn()
+	if err != nil {
+		return err
+	}
+
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return err
+	}
+
+	db.SetMaxOpenConns(s.cfg.MaxOpenConns)
+	db.SetMaxIdleConns(s.cfg.MaxIdleConns)
+
+	s.db = db
+
+	if err = s.Ping(ctx); err != nil {
+		return err
+	}
+
+	if s.cfg.MetricsEnabled {
+		prometheus.MustRegister(collectors.NewDBStatsCollector(db, "blocks"))
+	}
+
+	if err = s.migrate(ctx); err != nil {
+		return err
+	}
+
+	// set error status for unprocessed blocks
+	return s.ReleaseExpiredBlocks(ctx)
+}
+
+func (s *Storage) Stop(ctx context.Context) error {
+	s.log.Info().Msg("start ReleaseExpiredBlocks")
+
+	// set error status for unprocessed blocks
+	if err := s.ReleaseExpiredBlocks(ctx); err != nil {
+		s.log.Error().Err(err).Msg("ReleaseExpiredBlocks error")
+		return err
+	}
+
+	return s.db.Close()
+}
+
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// dsn applies credentials from the config to the connection url.
+func (s *Storage) dsn() (string, error) {
+	if s.cfg.User == "" {
+		return s.cfg.URI, nil
+	}
+
+	u, err := url.Parse(s.cfg.URI)
+	if err != nil {
+		return "", err
+	}
+
+	u.User = url.UserPassword(s.cfg.User, s.cfg.Password)
+
+	return u.String(), nil
+}
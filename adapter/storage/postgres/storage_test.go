@@ -0,0 +1,41 @@
+package postgres
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/storagetest"
+	"github.com/bro-n-bro/spacebox-crawler/v2/internal/rep"
+)
+
+// testURIEnv is the connection url of the database used by the tests, the tests are skipped without it.
+// All the crawler tables of the database are truncated.
+const testURIEnv = "POSTGRES_TEST_URI"
+
+func TestStorage(t *testing.T) {
+	uri := os.Getenv(testURIEnv)
+	if uri == "" {
+		t.Skipf("%s is not set", testURIEnv)
+	}
+
+	storagetest.Run(t, func(t *testing.T) rep.Storage {
+		ctx := context.Background()
+
+		s := New(Config{URI: uri, MaxOpenConns: 8, MaxIdleConns: 8}, zerolog.Nop())
+		if err := s.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		t.Cleanup(func() { _ = s.Stop(context.Background()) })
+
+		if _, err := s.db.ExecContext(ctx,
+			`TRUNCATE blocks, error_messages, error_txs, state, backfill_jobs`); err != nil {
+			t.Fatal(err)
+		}
+
+		return s
+	})
+}
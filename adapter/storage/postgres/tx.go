@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/model"
+)
+
+func (s *Storage) InsertErrorTx(ctx context.Context, tx model.Tx) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO error_txs (hash, height, error_message, created) VALUES ($1, $2, $3, $4)`,
+		tx.Hash, tx.Height, tx.ErrorMessage, tx.Created,
+	)
+
+	return err
+}
+
+func (s *Storage) CountErrorTxs(ctx context.Context) (count int64, err error) {
+	err = s.db.QueryRowContext(ctx, `SELECT count(*) FROM error_txs`).Scan(&count)
+	return count, err
+}
+
+func (s *Storage) GetErrorTxs(ctx context.Context, height int64) ([]model.Tx, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT hash, height, error_message, created FROM error_txs WHERE height = $1 ORDER BY id`, height,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]model.Tx, 0)
+	for rows.Next() {
+		var tx model.Tx
+		if err = rows.Scan(&tx.Hash, &tx.Height, &tx.ErrorMessage, &tx.Created); err != nil {
+			return nil, err
+		}
+
+		res = append(res, tx)
+	}
+
+	return res, rows.Err()
+}
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/types"
+)
+
+const keyFinalizedHeight = "finalized_height"
+
+func (s *Storage) GetFinalizedHeight(ctx context.Context) (int64, error) {
+	var state struct {
+		Height int64 `bson:"height"`
+	}
+
+	err := s.stateCollection.FindOne(ctx, bson.D{{Key: "_id", Value: keyFinalizedHeight}}).Decode(&state)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, types.ErrFinalizedHeightNotFound
+	}
+
+	return state.Height, err
+}
+
+func (s *Storage) SetFinalizedHeight(ctx context.Context, height int64) error {
+	filter := bson.D{{Key: "_id", Value: keyFinalizedHeight}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "height", Value: height}}}}
+
+	if _, err := s.stateCollection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true)); err != nil {
+		return err
+	}
+
+	return nil
+}
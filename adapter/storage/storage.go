@@ -2,12 +2,21 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	mongoprom "github.com/globocom/mongo-go-prometheus"
 	"github.com/rs/zerolog"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/model"
+)
+
+const (
+	codeIndexOptionsConflict  = 85
+	codeIndexKeySpecsConflict = 86
 )
 
 type Storage struct {
@@ -16,6 +25,8 @@ type Storage struct {
 	blocksCollection   *mongo.Collection
 	messagesCollection *mongo.Collection
 	txCollection       *mongo.Collection
+	stateCollection    *mongo.Collection
+	backfillCollection *mongo.Collection
 
 	cfg Config
 }
@@ -59,30 +70,26 @@ func (s *Storage) Start(ctx context.Context) error {
 		return err
 	}
 
-	blocksCollection := s.cli.Database("spacebox").Collection("blocks")
-	s.blocksCollection = blocksCollection
+	s.blocksCollection = s.cli.Database("spacebox").Collection("blocks")
 	s.messagesCollection = s.cli.Database("spacebox").Collection("error_messages")
 	s.txCollection = s.cli.Database("spacebox").Collection("error_txs")
+	s.stateCollection = s.cli.Database("spacebox").Collection("state")
+	s.backfillCollection = s.cli.Database("spacebox").Collection("backfill_jobs")
 
-	mod := mongo.IndexModel{
-		Keys: bson.M{"height": 1}, // index in ascending order or -1 for descending order
-		// Options: options.Index().SetUnique(true),
-	}
-
-	if _, err := blocksCollection.Indexes().CreateOne(ctx, mod); err != nil {
+	if err := s.createHeightIndex(ctx); err != nil {
 		return err
 	}
 
 	// set error status for unprocessed blocks
-	return s.setErrorStatusForProcessing(ctx)
+	return s.ReleaseExpiredBlocks(ctx)
 }
 
 func (s *Storage) Stop(ctx context.Context) error {
-	s.log.Info().Msg("start setErrorStatusForProcessing")
+	s.log.Info().Msg("start ReleaseExpiredBlocks")
 
 	// set error status for unprocessed blocks
-	if err := s.setErrorStatusForProcessing(ctx); err != nil {
-		s.log.Error().Err(err).Msg("setErrorStatusForProcessing error")
+	if err := s.ReleaseExpiredBlocks(ctx); err != nil {
+		s.log.Error().Err(err).Msg("ReleaseExpiredBlocks error")
 		return err
 	}
 
@@ -92,3 +99,123 @@ func (s *Storage) Stop(ctx context.Context) error {
 func (s *Storage) Ping(ctx context.Context) error {
 	return s.cli.Ping(ctx, nil)
 }
+
+// createHeightIndex creates the unique height index which is required for atomic block claims.
+// A non-unique index created by the previous versions is replaced. The duplicate blocks of a height, which
+// the previous versions could create, are removed before.
+func (s *Storage) createHeightIndex(ctx context.Context) error {
+	mod := mongo.IndexModel{
+		Keys:    bson.M{"height": 1}, // index in ascending order or -1 for descending order
+		Options: options.Index().SetUnique(true),
+	}
+
+	_, err := s.blocksCollection.Indexes().CreateOne(ctx, mod)
+
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && (cmdErr.Code == codeIndexOptionsConflict || cmdErr.Code == codeIndexKeySpecsConflict) {
+		s.log.Info().Msg("replace non-unique height index")
+
+		if _, err = s.blocksCollection.Indexes().DropOne(ctx, "height_1"); err != nil {
+			return err
+		}
+
+		_, err = s.blocksCollection.Indexes().CreateOne(ctx, mod)
+	}
+
+	if mongo.IsDuplicateKeyError(err) {
+		if err = s.removeDuplicateBlocks(ctx); err != nil {
+			return fmt.Errorf("failed to remove duplicate blocks: %w. "+
+				"keep one document per height in spacebox.blocks and restart", err)
+		}
+
+		_, err = s.blocksCollection.Indexes().CreateOne(ctx, mod)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to create unique height index: %w. "+
+			"spacebox.blocks must have one document per height", err)
+	}
+
+	return nil
+}
+
+// removeDuplicateBlocks keeps one block per height: the processed one if any, otherwise the block in the
+// terminal status or the first one. The heights of the removed blocks are processed again if needed.
+func (s *Storage) removeDuplicateBlocks(ctx context.Context) error {
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id":    "$height",
+			"blocks": bson.M{"$push": bson.M{"id": "$_id", "status": "$status"}},
+			"count":  bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	}
+
+	cursor, err := s.blocksCollection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var removed int64
+
+	for cursor.Next(ctx) {
+		var dup struct {
+			Blocks []struct {
+				ID     interface{}  `bson:"id"`
+				Status model.Status `bson:"status"`
+			} `bson:"blocks"`
+			Height int64 `bson:"_id"`
+		}
+
+		if err = cursor.Decode(&dup); err != nil {
+			return err
+		}
+
+		keep := 0
+		for i, b := range dup.Blocks {
+			if duplicateRank(b.Status) > duplicateRank(dup.Blocks[keep].Status) {
+				keep = i
+			}
+		}
+
+		ids := make([]interface{}, 0, len(dup.Blocks)-1)
+		for i, b := range dup.Blocks {
+			if i != keep {
+				ids = append(ids, b.ID)
+			}
+		}
+
+		res, err := s.blocksCollection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+		if err != nil {
+			return err
+		}
+
+		s.log.Warn().Int64("height", dup.Height).Int64("count", res.DeletedCount).
+			Uint8("kept_status", uint8(dup.Blocks[keep].Status)).Msg("removed duplicate blocks")
+
+		removed += res.DeletedCount
+	}
+
+	if err = cursor.Err(); err != nil {
+		return err
+	}
+
+	s.log.Warn().Int64("count", removed).Msg("removed duplicate blocks before creating unique height index")
+
+	return nil
+}
+
+// duplicateRank is the preference of the block status to keep among the duplicates of a height.
+func duplicateRank(status model.Status) int {
+	switch status {
+	case model.StatusProcessed:
+		return 3
+	case model.StatusSkipped, model.StatusFailed:
+		return 2
+	case model.StatusError:
+		return 1
+	default:
+		return 0
+	}
+}
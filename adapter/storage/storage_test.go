@@ -3,13 +3,60 @@ package storage
 import (
 	"context"
 	"math/rand"
+	"os"
 	"testing"
 
+	"github.com/rs/zerolog"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/storagetest"
+	"github.com/bro-n-bro/spacebox-crawler/v2/internal/rep"
+)
+
+// testURIEnv is the connection url of the mongo used by the tests, the tests are skipped without it.
+// The credentials are optional. All the crawler collections of the spacebox database are cleared.
+const (
+	testURIEnv      = "MONGO_TEST_URI"
+	testUserEnv     = "MONGO_TEST_USER"
+	testPasswordEnv = "MONGO_TEST_PASSWORD"
 )
 
+func TestStorage(t *testing.T) {
+	uri := os.Getenv(testURIEnv)
+	if uri == "" {
+		t.Skipf("%s is not set", testURIEnv)
+	}
+
+	storagetest.Run(t, func(t *testing.T) rep.Storage {
+		ctx := context.Background()
+
+		s := New(Config{
+			URI:           uri,
+			User:          os.Getenv(testUserEnv),
+			Password:      os.Getenv(testPasswordEnv),
+			MaxPoolSize:   8,
+			MaxConnecting: 8,
+		}, zerolog.Nop())
+		if err := s.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		t.Cleanup(func() { _ = s.Stop(context.Background()) })
+
+		for _, c := range []*mongo.Collection{
+			s.blocksCollection, s.messagesCollection, s.txCollection, s.stateCollection, s.backfillCollection,
+		} {
+			if _, err := c.DeleteMany(ctx, bson.D{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return s
+	})
+}
+
 type block struct {
 	Height int64 `bson:"height"`
 }
@@ -0,0 +1,468 @@
+// Package storagetest contains the contract tests shared by all rep.Storage implementations.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/model"
+	"github.com/bro-n-bro/spacebox-crawler/v2/internal/rep"
+	"github.com/bro-n-bro/spacebox-crawler/v2/types"
+)
+
+const lease = time.Minute
+
+// Run runs the contract tests against the storage. newStorage must return an empty started storage,
+// it is called once per test case.
+func Run(t *testing.T, newStorage func(t *testing.T) rep.Storage) {
+	t.Helper()
+
+	cases := []struct {
+		name string
+		fn   func(t *testing.T, s rep.Storage)
+	}{
+		{"claim", testClaim},
+		{"renew lease", testRenewLease},
+		{"expired lease", testExpiredLease},
+		{"release expired blocks", testReleaseExpiredBlocks},
+		{"status transitions", testStatusTransitions},
+		{"status of lost lease", testLostLeaseStatus},
+		{"heights", testHeights},
+		{"finalized height", testFinalizedHeight},
+		{"backfill jobs", testBackfillJobs},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) { c.fn(t, newStorage(t)) })
+	}
+}
+
+func testClaim(t *testing.T, s rep.Storage) {
+	ctx := context.Background()
+
+	if _, err := s.GetBlockByHeight(ctx, 1); !errors.Is(err, types.ErrBlockNotFound) {
+		t.Fatalf("expected ErrBlockNotFound for unknown height, got %v", err)
+	}
+
+	block, err := s.ClaimBlock(ctx, 1, "a", lease, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !block.Status.IsProcessing() || block.Owner != "a" || block.Attempts != 1 || block.LeaseExpires == nil {
+		t.Fatalf("unexpected claimed block: %+v", block)
+	}
+
+	// the lease is valid, so neither another owner nor the retry of error blocks can take the block over
+	for _, retryError := range []bool{false, true} {
+		block, err = s.ClaimBlock(ctx, 1, "b", lease, retryError)
+		if !errors.Is(err, types.ErrBlockNotClaimed) {
+			t.Fatalf("expected ErrBlockNotClaimed, got %v", err)
+		}
+
+		if block == nil || block.Owner != "a" {
+			t.Fatalf("expected the current block owned by a, got %+v", block)
+		}
+	}
+
+	block, err = s.GetBlockByHeight(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !block.Status.IsProcessing() || block.Owner != "a" || block.Attempts != 1 {
+		t.Fatalf("failed claim changed the block: %+v", block)
+	}
+}
+
+func testRenewLease(t *testing.T, s rep.Storage) {
+	ctx := context.Background()
+
+	if err := s.RenewLease(ctx, 1, "a", lease); !errors.Is(err, types.ErrLeaseLost) {
+		t.Fatalf("expected ErrLeaseLost for unknown height, got %v", err)
+	}
+
+	if _, err := s.ClaimBlock(ctx, 1, "a", lease, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RenewLease(ctx, 1, "a", lease); err != nil {
+		t.Fatalf("owner can't renew the lease: %v", err)
+	}
+
+	if err := s.RenewLease(ctx, 1, "b", lease); !errors.Is(err, types.ErrLeaseLost) {
+		t.Fatalf("expected ErrLeaseLost for another owner, got %v", err)
+	}
+
+	if err := s.SetProcessedStatus(ctx, 1, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RenewLease(ctx, 1, "a", lease); !errors.Is(err, types.ErrLeaseLost) {
+		t.Fatalf("expected ErrLeaseLost for processed block, got %v", err)
+	}
+}
+
+func testExpiredLease(t *testing.T, s rep.Storage) {
+	ctx := context.Background()
+
+	if _, err := s.ClaimBlock(ctx, 1, "a", -time.Second, false); err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := s.ClaimBlock(ctx, 1, "b", lease, false)
+	if err != nil {
+		t.Fatalf("block with expired lease is not claimable: %v", err)
+	}
+
+	if !block.Status.IsProcessing() || block.Owner != "b" || block.Attempts != 2 {
+		t.Fatalf("unexpected claimed block: %+v", block)
+	}
+
+	if err = s.RenewLease(ctx, 1, "a", lease); !errors.Is(err, types.ErrLeaseLost) {
+		t.Fatalf("expected ErrLeaseLost for the previous owner, got %v", err)
+	}
+}
+
+func testReleaseExpiredBlocks(t *testing.T, s rep.Storage) {
+	ctx := context.Background()
+
+	if _, err := s.ClaimBlock(ctx, 1, "a", -time.Second, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.ClaimBlock(ctx, 2, "a", lease, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.ReleaseExpiredBlocks(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	expired, err := s.GetBlockByHeight(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !expired.Status.IsError() || expired.Owner != "" || expired.LeaseExpires != nil || expired.ErrorMessage == "" {
+		t.Fatalf("expired block is not released: %+v", expired)
+	}
+
+	active, err := s.GetBlockByHeight(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !active.Status.IsProcessing() || active.Owner != "a" {
+		t.Fatalf("block with valid lease is released: %+v", active)
+	}
+
+	blocks, err := s.GetErrorBlocks(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(blocks) != 1 || blocks[0].Height != 1 {
+		t.Fatalf("unexpected error blocks: %+v", blocks)
+	}
+}
+
+func testStatusTransitions(t *testing.T, s rep.Storage) {
+	ctx := context.Background()
+
+	if _, err := s.ClaimBlock(ctx, 1, "a", lease, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SetErrorStatus(ctx, 1, "a", "boom"); err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := s.GetBlockByHeight(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !block.Status.IsError() || block.ErrorMessage != "boom" || block.Owner != "" || block.LeaseExpires != nil {
+		t.Fatalf("unexpected error block: %+v", block)
+	}
+
+	// error blocks are claimed only by the retry
+	if _, err = s.ClaimBlock(ctx, 1, "b", lease, false); !errors.Is(err, types.ErrBlockNotClaimed) {
+		t.Fatalf("expected ErrBlockNotClaimed for error block, got %v", err)
+	}
+
+	block, err = s.ClaimBlock(ctx, 1, "b", lease, true)
+	if err != nil {
+		t.Fatalf("error block is not claimable by the retry: %v", err)
+	}
+
+	if !block.Status.IsProcessing() || block.Owner != "b" || block.Attempts != 2 {
+		t.Fatalf("unexpected retried block: %+v", block)
+	}
+
+	if err = s.SetBlockHash(ctx, 1, "hash", "parent"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = s.SetProcessedStatus(ctx, 1, "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	block, err = s.GetBlockByHeight(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !block.Status.IsProcessed() || block.Processed == nil || block.Owner != "" || block.LeaseExpires != nil ||
+		block.ErrorMessage != "" {
+		t.Fatalf("unexpected processed block: %+v", block)
+	}
+
+	if block.Hash != "hash" || block.ParentHash != "parent" {
+		t.Fatalf("unexpected block hashes: %q %q", block.Hash, block.ParentHash)
+	}
+
+	if _, err = s.ClaimBlock(ctx, 1, "b", lease, true); !errors.Is(err, types.ErrBlockNotClaimed) {
+		t.Fatalf("expected ErrBlockNotClaimed for processed block, got %v", err)
+	}
+
+	if err = s.UpdateStatus(ctx, 1, model.StatusSkipped); err != nil {
+		t.Fatal(err)
+	}
+
+	if block, err = s.GetBlockByHeight(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if !block.Status.IsSkipped() {
+		t.Fatalf("unexpected status: %v", block.Status)
+	}
+}
+
+func testLostLeaseStatus(t *testing.T, s rep.Storage) {
+	ctx := context.Background()
+
+	if err := s.SetProcessedStatus(ctx, 1, "a"); !errors.Is(err, types.ErrLeaseLost) {
+		t.Fatalf("expected ErrLeaseLost for unknown height, got %v", err)
+	}
+
+	// the lease of a expires and b takes the block over
+	if _, err := s.ClaimBlock(ctx, 1, "a", -time.Second, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.ClaimBlock(ctx, 1, "b", lease, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SetProcessedStatus(ctx, 1, "a"); !errors.Is(err, types.ErrLeaseLost) {
+		t.Fatalf("expected ErrLeaseLost for processed status of the previous owner, got %v", err)
+	}
+
+	if err := s.SetErrorStatus(ctx, 1, "a", "boom"); !errors.Is(err, types.ErrLeaseLost) {
+		t.Fatalf("expected ErrLeaseLost for error status of the previous owner, got %v", err)
+	}
+
+	block, err := s.GetBlockByHeight(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !block.Status.IsProcessing() || block.Owner != "b" {
+		t.Fatalf("previous owner changed the block: %+v", block)
+	}
+
+	if err = s.SetErrorStatus(ctx, 1, "b", "boom"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the status is set once, the block is not processing anymore
+	if err = s.SetProcessedStatus(ctx, 1, "b"); !errors.Is(err, types.ErrLeaseLost) {
+		t.Fatalf("expected ErrLeaseLost for error block, got %v", err)
+	}
+
+	if block, err = s.GetBlockByHeight(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if !block.Status.IsError() || block.ErrorMessage != "boom" {
+		t.Fatalf("unexpected error block: %+v", block)
+	}
+
+	// rollback doesn't depend on the owner
+	if _, err = s.ClaimBlock(ctx, 2, "a", lease, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = s.SetProcessedStatus(ctx, 2, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = s.RollbackBlock(ctx, 2, "reorg"); err != nil {
+		t.Fatal(err)
+	}
+
+	if block, err = s.GetBlockByHeight(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if !block.Status.IsError() || block.ErrorMessage != "reorg" || block.Owner != "" {
+		t.Fatalf("unexpected rolled back block: %+v", block)
+	}
+}
+
+func testHeights(t *testing.T, s rep.Storage) {
+	ctx := context.Background()
+
+	for _, height := range []int64{4, 1, 3, 2} {
+		if _, err := s.ClaimBlock(ctx, height, "a", lease, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, height := range []int64{1, 3} {
+		if err := s.SetProcessedStatus(ctx, height, "a"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// skipped heights are completed, failed ones are not
+	if err := s.UpdateStatus(ctx, 4, model.StatusSkipped); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.UpdateStatus(ctx, 2, model.StatusFailed); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name     string
+		get      func(ctx context.Context, after, limit int64) ([]int64, error)
+		after    int64
+		limit    int64
+		expected []int64
+	}{
+		{"completed", s.GetCompletedHeights, 0, 10, []int64{1, 3, 4}},
+		{"completed after", s.GetCompletedHeights, 1, 10, []int64{3, 4}},
+		{"stored", s.GetStoredHeights, 0, 10, []int64{1, 2, 3, 4}},
+		{"stored limit", s.GetStoredHeights, 1, 2, []int64{2, 3}},
+		{"stored empty", s.GetStoredHeights, 4, 10, []int64{}},
+	} {
+		heights, err := c.get(ctx, c.after, c.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(heights, c.expected) {
+			t.Fatalf("%s: expected heights %v, got %v", c.name, c.expected, heights)
+		}
+	}
+}
+
+func testFinalizedHeight(t *testing.T, s rep.Storage) {
+	ctx := context.Background()
+
+	if _, err := s.GetFinalizedHeight(ctx); !errors.Is(err, types.ErrFinalizedHeightNotFound) {
+		t.Fatalf("expected ErrFinalizedHeightNotFound, got %v", err)
+	}
+
+	for _, height := range []int64{5, 7} {
+		if err := s.SetFinalizedHeight(ctx, height); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := s.GetFinalizedHeight(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != height {
+			t.Fatalf("expected finalized height %d, got %d", height, got)
+		}
+	}
+}
+
+func testBackfillJobs(t *testing.T, s rep.Storage) {
+	ctx := context.Background()
+
+	if _, err := s.GetBackfillJob(ctx, "job"); !errors.Is(err, types.ErrBackfillJobNotFound) {
+		t.Fatalf("expected ErrBackfillJobNotFound, got %v", err)
+	}
+
+	if err := s.SetBackfillJobProgress(ctx, "job", 2); !errors.Is(err, types.ErrBackfillJobNotFound) {
+		t.Fatalf("expected ErrBackfillJobNotFound, got %v", err)
+	}
+
+	created := time.Now()
+	for i, id := range []string{"job", "other"} {
+		job := &model.BackfillJob{
+			Created: created.Add(time.Duration(i) * time.Second),
+			ID:      id,
+			From:    1,
+			To:      10,
+			Next:    1,
+			Status:  model.BackfillJobActive,
+		}
+
+		if err := s.CreateBackfillJob(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	jobs, err := s.GetBackfillJobs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(jobs) != 2 || jobs[0].ID != "job" || jobs[1].ID != "other" {
+		t.Fatalf("unexpected jobs: %+v", jobs)
+	}
+
+	// the progress never moves back
+	for _, next := range []int64{5, 3} {
+		if err = s.SetBackfillJobProgress(ctx, "job", next); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	job, err := s.GetBackfillJob(ctx, "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if job.Next != 5 || !job.Status.IsActive() {
+		t.Fatalf("unexpected job progress: %+v", job)
+	}
+
+	if err = s.SetBackfillJobProgress(ctx, "job", 11); err != nil {
+		t.Fatal(err)
+	}
+
+	if job, err = s.GetBackfillJob(ctx, "job"); err != nil {
+		t.Fatal(err)
+	}
+
+	if job.Status != model.BackfillJobFinished || job.Finished == nil {
+		t.Fatalf("job is not finished after the last height: %+v", job)
+	}
+
+	if err = s.SetBackfillJobStatus(ctx, "job", model.BackfillJobActive); !errors.Is(err, types.ErrBackfillJobDone) {
+		t.Fatalf("expected ErrBackfillJobDone, got %v", err)
+	}
+
+	if err = s.SetBackfillJobStatus(ctx, "other", model.BackfillJobPaused); err != nil {
+		t.Fatal(err)
+	}
+
+	if job, err = s.GetBackfillJob(ctx, "other"); err != nil {
+		t.Fatal(err)
+	}
+
+	if job.Status != model.BackfillJobPaused {
+		t.Fatalf("unexpected job status: %v", job.Status)
+	}
+}
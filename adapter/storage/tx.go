@@ -19,3 +19,17 @@ func (s *Storage) InsertErrorTx(ctx context.Context, tx model.Tx) error {
 func (s *Storage) CountErrorTxs(ctx context.Context) (int64, error) {
 	return s.txCollection.CountDocuments(ctx, bson.D{})
 }
+
+func (s *Storage) GetErrorTxs(ctx context.Context, height int64) ([]model.Tx, error) {
+	cursor, err := s.txCollection.Find(ctx, bson.D{{Key: "height", Value: height}})
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]model.Tx, 0)
+	if err = cursor.All(ctx, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
@@ -9,12 +9,17 @@ import (
 )
 
 func (c *Client) Block(ctx context.Context, height int64) (*cometbftcoretypes.ResultBlock, error) {
-	resp, err := c.TmsService.GetBlockByHeight(
-		ctx,
-		&tmservice.GetBlockByHeightRequest{
-			Height: height,
-		},
-	)
+	var resp *tmservice.GetBlockByHeightResponse
+
+	err := c.pool.Do(ctx, height, func(ctx context.Context, n *node) (err error) {
+		resp, err = n.tmsService.GetBlockByHeight(
+			ctx,
+			&tmservice.GetBlockByHeightRequest{
+				Height: height,
+			},
+		)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -3,7 +3,7 @@ package grpc
 import (
 	"context"
 	"crypto/tls"
-	"time"
+	"strings"
 
 	"github.com/cosmos/cosmos-sdk/client/grpc/tmservice"
 	"github.com/cosmos/cosmos-sdk/types/tx"
@@ -12,10 +12,13 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 
 	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/model"
+	"github.com/bro-n-bro/spacebox-crawler/v2/client/pool"
 )
 
 type (
@@ -23,29 +26,46 @@ type (
 		InsertErrorTx(ctx context.Context, tx model.Tx) error
 	}
 
-	Client struct {
-		TmsService tmservice.ServiceClient
-		TxService  tx.ServiceClient
+	deadLetters interface {
+		PublishDeadLetter(ctx context.Context, dl interface{}) error
+	}
 
-		conn    *grpc.ClientConn
-		log     *zerolog.Logger
-		storage storage
-		cfg     Config
+	// node is the client of a single gRPC endpoint.
+	node struct {
+		conn       *grpc.ClientConn
+		tmsService tmservice.ServiceClient
+		txService  tx.ServiceClient
+	}
+
+	Client struct {
+		pool        *pool.Pool[*node]
+		log         *zerolog.Logger
+		storage     storage
+		deadLetters deadLetters
+		cfg         Config
 	}
 )
 
-func New(cfg Config, l zerolog.Logger, st storage) *Client {
+func New(cfg Config, l zerolog.Logger, st storage, dl deadLetters) *Client {
 	l = l.With().Str("cmp", "grpc-client").Logger()
 
-	return &Client{cfg: cfg, log: &l, storage: st}
+	c := &Client{cfg: cfg, log: &l, storage: st, deadLetters: dl}
+	c.pool = pool.New(pool.Config{
+		HealthCheckInterval: cfg.HealthCheckInterval,
+		HealthCheckTimeout:  cfg.Timeout,
+		MaxHeightLag:        cfg.MaxHeightLag,
+		MaxFailures:         cfg.MaxFailures,
+		RateLimit:           cfg.RateLimit,
+		RateBurst:           cfg.RateBurst,
+		MaxInFlight:         cfg.MaxInFlight,
+		MetricsEnabled:      cfg.MetricsEnabled,
+	}, l, "grpc", checkNode, isThrottled)
+
+	return c
 }
 
 func (c *Client) Start(ctx context.Context) error {
-	ctx, cancel := context.WithTimeout(ctx, 15*time.Second) // dial timeout
-	defer cancel()
-
 	options := []grpc.DialOption{
-		grpc.WithBlock(),
 		grpc.WithChainUnaryInterceptor(timeout.UnaryClientInterceptor(c.cfg.Timeout)), // request timeout
 		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(c.cfg.MaxReceiveMessageSize)),
 	}
@@ -70,24 +90,73 @@ func (c *Client) Start(ctx context.Context) error {
 		options = append(options, grpc.WithTransportCredentials(insecure.NewCredentials()))
 	}
 
-	// Create a connection to the gRPC server.
-	grpcConn, err := grpc.DialContext(
-		ctx,
-		c.cfg.Host, // Or your gRPC server address.
-		options...,
-	)
+	for _, host := range c.cfg.Hosts {
+		if err := c.addNode(ctx, host, false, options); err != nil {
+			return err
+		}
+	}
+
+	for _, host := range c.cfg.ArchiveHosts {
+		if err := c.addNode(ctx, host, true, options); err != nil {
+			return err
+		}
+	}
+
+	return c.pool.Start(ctx)
+}
+
+// addNode adds the client of the endpoint to the pool.
+func (c *Client) addNode(ctx context.Context, host string, archive bool, options []grpc.DialOption) error {
+	// Create a connection to the gRPC server. The connection is established in background,
+	// the unavailable endpoint is detected by the status check.
+	grpcConn, err := grpc.DialContext(ctx, host, options...)
 	if err != nil {
 		return err
 	}
 
-	c.TmsService = tmservice.NewServiceClient(grpcConn)
-	c.TxService = tx.NewServiceClient(grpcConn)
-
-	c.conn = grpcConn
+	c.pool.Add(host, &node{
+		conn:       grpcConn,
+		tmsService: tmservice.NewServiceClient(grpcConn),
+		txService:  tx.NewServiceClient(grpcConn),
+	}, archive)
 
 	return nil
 }
 
-func (c *Client) Stop(_ context.Context) error { return c.conn.Close() }
+func (c *Client) Stop(_ context.Context) error {
+	c.pool.Stop()
 
-func (c *Client) Conn() *grpc.ClientConn { return c.conn }
+	var err error
+	for _, e := range c.pool.Endpoints() {
+		if closeErr := e.Client.conn.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+// checkNode returns the latest height of the node. The earliest height is unknown over gRPC,
+// so it is learned from the errors of the pruned heights.
+func checkNode(ctx context.Context, n *node) (pool.Status, error) {
+	resp, err := n.tmsService.GetLatestBlock(ctx, &tmservice.GetLatestBlockRequest{})
+	if err != nil {
+		return pool.Status{}, err
+	}
+
+	var height int64
+	if resp.SdkBlock != nil {
+		height = resp.SdkBlock.Header.Height
+	} else if resp.Block != nil { // nolint:staticcheck
+		height = resp.Block.Header.Height // nolint:staticcheck
+	}
+
+	return pool.Status{LatestHeight: height}, nil
+}
+
+// isThrottled reports whether the node rejected the request due to its rate limit. ResourceExhausted is also
+// returned for the messages exceeding the max size, retrying them later doesn't help.
+func isThrottled(err error) bool {
+	st, ok := status.FromError(err)
+	return ok && st.Code() == codes.ResourceExhausted && !strings.Contains(st.Message(), "larger than max")
+}
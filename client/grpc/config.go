@@ -4,10 +4,18 @@ import "time"
 
 type (
 	Config struct {
-		Host                  string        `env:"GRPC_URL" envDefault:"http://localhost:9090"`
+		Hosts                 []string      `env:"GRPC_URL" envDefault:"http://localhost:9090" envSeparator:","`
+		ArchiveHosts          []string      `env:"GRPC_ARCHIVE_URL" envSeparator:","` // endpoints keeping all heights
 		SecureConnection      bool          `env:"GRPC_SECURE_CONNECTION" envDefault:"false"`
+		DecodeTxs             bool          `env:"GRPC_DECODE_TXS" envDefault:"false"` // decode block txs instead of GetTx per tx
 		MetricsEnabled        bool          `env:"METRICS_ENABLED" envDefault:"false"`
 		MaxReceiveMessageSize int           `env:"GRPC_MAX_RECEIVE_MESSAGE_SIZE_BYTES" envDefault:"5242880"` // 5MB
+		MaxFailures           int           `env:"GRPC_MAX_FAILURES" envDefault:"3"`                         // consecutive failures to mark unhealthy
+		RateBurst             int           `env:"GRPC_RATE_BURST" envDefault:"0"`                           // 0 is the rate limit rounded up
+		MaxInFlight           int           `env:"GRPC_MAX_IN_FLIGHT" envDefault:"0"`                        // concurrent requests to an endpoint, 0 is unlimited
+		RateLimit             float64       `env:"GRPC_RATE_LIMIT" envDefault:"0"`                           // requests per second to an endpoint, 0 is unlimited
 		Timeout               time.Duration `env:"GRPC_TIMEOUT" envDefault:"15s"`
+		HealthCheckInterval   time.Duration `env:"GRPC_HEALTH_CHECK_INTERVAL" envDefault:"10s"`
+		MaxHeightLag          int64         `env:"GRPC_MAX_HEIGHT_LAG" envDefault:"10"` // max lag behind the best endpoint at tip
 	}
 )
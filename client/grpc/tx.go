@@ -3,38 +3,149 @@ package grpc
 import (
 	"context"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"time"
 
+	abci "github.com/cometbft/cometbft/abci/types"
+	cometbftcoretypes "github.com/cometbft/cometbft/rpc/core/types"
 	cometbfttypes "github.com/cometbft/cometbft/types"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/cosmos-sdk/types/tx"
 
 	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/model"
+	"github.com/bro-n-bro/spacebox-crawler/v2/types"
 )
 
-// Txs queries for all the transactions in a block. Transactions are returned
-// in sdk.TxResponse format which internally contains a sdk.Tx. An error is
-// returned if any query fails.
-func (c *Client) Txs(ctx context.Context, height int64, txs cometbfttypes.Txs) ([]*tx.GetTxResponse, error) {
-	txResponses := make([]*tx.GetTxResponse, 0, len(txs))
+// Txs returns all the transactions of the block in sdk.TxResponse format which internally contains a sdk.Tx.
+// If DecodeTxs is enabled, the transactions are decoded from the block data and joined with their results,
+// otherwise or if it fails they are queried one by one. A transaction which can't be queried is saved
+// to the error transactions and skipped.
+func (c *Client) Txs(ctx context.Context, block *cometbftcoretypes.ResultBlock,
+	results []*abci.ResponseDeliverTx) ([]*tx.GetTxResponse, error) {
 
-	for _, tmTx := range txs {
-		hash := hex.EncodeToString(tmTx.Hash())
+	var (
+		height      = block.Block.Height
+		txs         = block.Block.Data.Txs
+		timestamp   = block.Block.Time.Format(time.RFC3339)
+		decode      = c.cfg.DecodeTxs
+		txResponses = make([]*tx.GetTxResponse, 0, len(txs))
+	)
 
-		respPb, err := c.TxService.GetTx(ctx, &tx.GetTxRequest{Hash: hash})
+	if decode && len(results) != len(txs) {
+		c.log.Warn().Int64("height", height).Int("txs", len(txs)).Int("results", len(results)).
+			Msg("count of tx results doesn't match the block, query txs")
+
+		decode = false
+	}
+
+	for i, tmTx := range txs {
+		if decode {
+			resp, err := decodeTx(height, uint32(i), tmTx, results[i], timestamp)
+			if err == nil {
+				txResponses = append(txResponses, resp)
+				continue
+			}
+
+			c.log.Warn().Err(err).Int64("height", height).Int("index", i).Msg("can't decode tx, query it")
+		}
+
+		resp, err := c.getTx(ctx, height, tmTx)
 		if err != nil {
-			_ = c.storage.InsertErrorTx(ctx, model.Tx{
-				Created:      time.Now(),
-				ErrorMessage: err.Error(),
-				Hash:         hash,
-				Height:       height,
-			})
-
-			c.log.Warn().Err(err).Int64("height", height).Msg("GetTx error")
 			continue
 		}
 
-		txResponses = append(txResponses, &tx.GetTxResponse{Tx: respPb.Tx, TxResponse: respPb.TxResponse})
+		txResponses = append(txResponses, resp)
 	}
 
 	return txResponses, nil
 }
+
+// getTx queries for the transaction. The failure is saved to the error transactions.
+func (c *Client) getTx(ctx context.Context, height int64, tmTx cometbfttypes.Tx) (*tx.GetTxResponse, error) {
+	hash := hex.EncodeToString(tmTx.Hash())
+
+	var respPb *tx.GetTxResponse
+
+	err := c.pool.Do(ctx, height, func(ctx context.Context, n *node) (err error) {
+		respPb, err = n.txService.GetTx(ctx, &tx.GetTxRequest{Hash: hash})
+		return err
+	})
+	if err != nil {
+		_ = c.storage.InsertErrorTx(ctx, model.Tx{
+			Created:      time.Now(),
+			ErrorMessage: err.Error(),
+			Hash:         hash,
+			Height:       height,
+		})
+
+		c.publishDeadLetter(ctx, height, hash, err)
+
+		c.log.Warn().Err(err).Int64("height", height).Msg("GetTx error")
+		return nil, err
+	}
+
+	return &tx.GetTxResponse{Tx: respPb.Tx, TxResponse: respPb.TxResponse}, nil
+}
+
+// decodeTx builds the response of GetTx from the raw transaction of the block and its result.
+// The messages are left packed, as they are in the queried transactions.
+func decodeTx(height int64, index uint32, tmTx cometbfttypes.Tx, result *abci.ResponseDeliverTx,
+	timestamp string) (*tx.GetTxResponse, error) {
+
+	if result == nil {
+		return nil, errors.New("tx result is missing")
+	}
+
+	// the raw transaction is TxRaw which is wire compatible with Tx
+	var protoTx tx.Tx
+	if err := protoTx.Unmarshal(tmTx); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal tx: %w", err)
+	}
+
+	if protoTx.Body == nil || protoTx.AuthInfo == nil {
+		return nil, errors.New("tx body or auth info is missing")
+	}
+
+	anyTx, err := codectypes.NewAnyWithValue(&protoTx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack tx: %w", err)
+	}
+
+	txResponse := sdk.NewResponseResultTx(&cometbftcoretypes.ResultTx{
+		Hash:     tmTx.Hash(),
+		Height:   height,
+		Index:    index,
+		TxResult: *result,
+		Tx:       tmTx,
+	}, anyTx, timestamp)
+
+	return &tx.GetTxResponse{Tx: &protoTx, TxResponse: txResponse}, nil
+}
+
+// publishDeadLetter publishes the failure record of the transaction which can't be fetched.
+func (c *Client) publishDeadLetter(ctx context.Context, height int64, hash string, err error) {
+	if c.deadLetters == nil {
+		return
+	}
+
+	origin, _ := types.OriginFromContext(ctx)
+
+	dl := types.DeadLetter{
+		Timestamp: time.Now(),
+		Stage:     types.StageTxs,
+		TxHash:    hash,
+		Error:     err.Error(),
+		Height:    height,
+		Attempt:   origin.Attempt,
+	}
+
+	if errors.Is(err, types.ErrHeightPruned) {
+		dl.Class = types.ClassHeightPruned
+	}
+
+	if err = c.deadLetters.PublishDeadLetter(ctx, dl); err != nil {
+		c.log.Error().Err(err).Int64("height", height).Msg("can't publish dead letter")
+	}
+}
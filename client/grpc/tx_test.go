@@ -0,0 +1,73 @@
+package grpc
+
+import (
+	"fmt"
+	"testing"
+
+	abci "github.com/cometbft/cometbft/abci/types"
+	cometbfttypes "github.com/cometbft/cometbft/types"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	"github.com/cosmos/cosmos-sdk/types/tx"
+)
+
+func TestDecodeTx(t *testing.T) {
+	msg, err := codectypes.NewAnyWithValue(&tx.TxBody{Memo: "msg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := (&tx.TxRaw{
+		BodyBytes:     mustMarshal(t, &tx.TxBody{Messages: []*codectypes.Any{msg}, Memo: "memo"}),
+		AuthInfoBytes: mustMarshal(t, &tx.AuthInfo{Fee: &tx.Fee{GasLimit: 100}}),
+		Signatures:    [][]byte{{1}},
+	}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := &abci.ResponseDeliverTx{
+		Code:      5,
+		Data:      []byte{0xab},
+		Log:       "failed",
+		GasWanted: 100,
+		GasUsed:   70,
+		Events:    []abci.Event{{Type: "tx"}},
+	}
+
+	resp, err := decodeTx(10, 2, raw, result, "1970-01-01T00:00:10Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Tx.Body.Memo != "memo" || len(resp.Tx.Body.Messages) != 1 || resp.Tx.AuthInfo.Fee.GasLimit != 100 ||
+		len(resp.Tx.Signatures) != 1 {
+		t.Errorf("unexpected tx %+v", resp.Tx)
+	}
+
+	txResp := resp.TxResponse
+	if txResp.Height != 10 || txResp.TxHash != fmt.Sprintf("%X", cometbfttypes.Tx(raw).Hash()) || txResp.Code != 5 ||
+		txResp.Data != "AB" || txResp.RawLog != "failed" || txResp.GasWanted != 100 || txResp.GasUsed != 70 || len(txResp.Events) != 1 ||
+		txResp.Timestamp != "1970-01-01T00:00:10Z" || txResp.Tx.TypeUrl != "/cosmos.tx.v1beta1.Tx" {
+		t.Errorf("unexpected tx response %+v", txResp)
+	}
+
+	// the invalid tx is queried
+	if _, err = decodeTx(10, 0, cometbfttypes.Tx{0xff, 0xff}, result, ""); err == nil {
+		t.Error("want error for the invalid tx")
+	}
+
+	if _, err = decodeTx(10, 0, raw, nil, ""); err == nil {
+		t.Error("want error for the missing result")
+	}
+}
+
+func mustMarshal(t *testing.T, m interface{ Marshal() ([]byte, error) }) []byte {
+	t.Helper()
+
+	b, err := m.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
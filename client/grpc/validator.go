@@ -14,7 +14,20 @@ const (
 	defaultLimit = 100
 )
 
-func (c *Client) Validators(ctx context.Context, height int64) (*cometbftcoretypes.ResultValidators, error) {
+func (c *Client) Validators(ctx context.Context, height int64) (vals *cometbftcoretypes.ResultValidators, err error) {
+	err = c.pool.Do(ctx, height, func(ctx context.Context, n *node) error {
+		vals, err = validators(ctx, n, height)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return vals, nil
+}
+
+// validators returns all validators of the height from the node page by page.
+func validators(ctx context.Context, n *node, height int64) (*cometbftcoretypes.ResultValidators, error) {
 	vals := &cometbftcoretypes.ResultValidators{
 		BlockHeight: height,
 	}
@@ -22,7 +35,7 @@ func (c *Client) Validators(ctx context.Context, height int64) (*cometbftcoretyp
 	var offset uint64
 
 	for {
-		respPb, err := c.TmsService.GetValidatorSetByHeight(ctx, &tmservice.GetValidatorSetByHeightRequest{
+		respPb, err := n.tmsService.GetValidatorSetByHeight(ctx, &tmservice.GetValidatorSetByHeightRequest{
 			Height: height,
 			Pagination: &query.PageRequest{
 				Offset:     offset,
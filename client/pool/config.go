@@ -0,0 +1,22 @@
+package pool
+
+import "time"
+
+type Config struct {
+	// HealthCheckInterval is the interval of the endpoint status checks
+	HealthCheckInterval time.Duration
+	// HealthCheckTimeout is the timeout of a single status check
+	HealthCheckTimeout time.Duration
+	// MaxHeightLag is the max count of heights the endpoint may be behind the best one to serve the tip
+	MaxHeightLag int64
+	// MaxFailures is the count of consecutive failed requests after which the endpoint is unhealthy until
+	// the next successful status check
+	MaxFailures int
+	// RateLimit is the max requests per second to the endpoint, 0 is unlimited
+	RateLimit float64
+	// RateBurst is the max requests sent at once within the rate limit, the rate limit rounded up by default
+	RateBurst int
+	// MaxInFlight is the max concurrent requests to the endpoint, 0 is unlimited
+	MaxInFlight    int
+	MetricsEnabled bool
+}
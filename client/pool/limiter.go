@@ -0,0 +1,143 @@
+package pool
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	throttleBackoff    = time.Second
+	throttleMaxBackoff = time.Minute
+	// minRateDivisor limits the slow-down of the throttled endpoint to the part of the configured rate
+	minRateDivisor = 16
+	// rateRecoveryStep is the part of the configured rate restored after every successful request
+	rateRecoveryStep = 0.05
+)
+
+type (
+	// ThrottledFn reports whether the node rejected the request due to its rate limit.
+	ThrottledFn func(err error) bool
+
+	// limiter is the request budget of the endpoint: the token bucket of the requests per second and the slots of
+	// the in-flight requests. The endpoint rejecting requests is cooled down and its rate is halved,
+	// then the rate is restored step by step with the successful requests.
+	limiter struct {
+		rate  *rate.Limiter
+		slots chan struct{} // nil if the in-flight requests are unlimited
+		limit rate.Limit    // the configured rate
+
+		mu             sync.Mutex
+		throttledUntil time.Time
+		throttles      int // consecutive throttled requests
+	}
+)
+
+func newLimiter(rps float64, burst, maxInFlight int) *limiter {
+	l := &limiter{limit: rate.Inf}
+
+	if rps > 0 {
+		l.limit = rate.Limit(rps)
+	}
+
+	if burst <= 0 {
+		burst = int(math.Max(1, math.Ceil(rps)))
+	}
+
+	l.rate = rate.NewLimiter(l.limit, burst)
+
+	if maxInFlight > 0 {
+		l.slots = make(chan struct{}, maxInFlight)
+	}
+
+	return l
+}
+
+// acquire waits for the end of the cooldown, a free in-flight slot and a rate token.
+// The returned function releases the slot.
+func (l *limiter) acquire(ctx context.Context) (func(), error) {
+	l.mu.Lock()
+	cooldown := time.Until(l.throttledUntil)
+	l.mu.Unlock()
+
+	if cooldown > 0 {
+		timer := time.NewTimer(cooldown)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	release := func() {}
+
+	if l.slots != nil {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case l.slots <- struct{}{}:
+			release = func() { <-l.slots }
+		}
+	}
+
+	if err := l.rate.Wait(ctx); err != nil {
+		release()
+		return nil, err
+	}
+
+	return release, nil
+}
+
+// throttled cools the endpoint down and halves its rate. It returns the cooldown.
+func (l *limiter) throttled() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cooldown := throttleBackoff << min(l.throttles, 6)
+	if cooldown > throttleMaxBackoff {
+		cooldown = throttleMaxBackoff
+	}
+
+	l.throttles++
+	l.throttledUntil = time.Now().Add(cooldown)
+
+	if l.limit != rate.Inf {
+		l.rate.SetLimit(max(l.rate.Limit()/2, l.limit/minRateDivisor))
+	}
+
+	return cooldown
+}
+
+// succeeded restores the rate of the throttled endpoint step by step.
+func (l *limiter) succeeded() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.throttles = 0
+
+	if current := l.rate.Limit(); current < l.limit {
+		l.rate.SetLimit(min(current+l.limit*rateRecoveryStep, l.limit))
+	}
+}
+
+// cooling reports whether the endpoint is cooled down after throttling.
+func (l *limiter) cooling() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return time.Now().Before(l.throttledUntil)
+}
+
+// currentLimit returns the current rate, 0 if it is unlimited.
+func (l *limiter) currentLimit() float64 {
+	if current := l.rate.Limit(); current != rate.Inf {
+		return float64(current)
+	}
+
+	return 0
+}
@@ -0,0 +1,69 @@
+package pool
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	metricsOnce sync.Once
+
+	healthyGauge        *prometheus.GaugeVec
+	latestHeightGauge   *prometheus.GaugeVec
+	earliestHeightGauge *prometheus.GaugeVec
+	latencyGauge        *prometheus.GaugeVec
+	inFlightGauge       *prometheus.GaugeVec
+	rateLimitGauge      *prometheus.GaugeVec
+	requestsCounter     *prometheus.CounterVec
+)
+
+// registerMetrics registers the endpoint metrics shared by all pools.
+func registerMetrics() {
+	metricsOnce.Do(func() {
+		labels := []string{"client", "endpoint"}
+
+		healthyGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "spacebox_crawler",
+			Name:      "endpoint_healthy",
+			Help:      "Is the endpoint healthy",
+		}, labels)
+
+		latestHeightGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "spacebox_crawler",
+			Name:      "endpoint_latest_height",
+			Help:      "Latest height of the endpoint",
+		}, labels)
+
+		earliestHeightGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "spacebox_crawler",
+			Name:      "endpoint_earliest_height",
+			Help:      "Earliest height available on the endpoint, 0 if unknown",
+		}, labels)
+
+		latencyGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "spacebox_crawler",
+			Name:      "endpoint_latency_seconds",
+			Help:      "Latency of the last endpoint status check",
+		}, labels)
+
+		inFlightGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "spacebox_crawler",
+			Name:      "endpoint_in_flight_requests",
+			Help:      "Count of in-flight requests to the endpoint",
+		}, labels)
+
+		rateLimitGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "spacebox_crawler",
+			Name:      "endpoint_rate_limit",
+			Help:      "Current requests per second limit of the endpoint, 0 if unlimited",
+		}, labels)
+
+		requestsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "spacebox_crawler",
+			Name:      "endpoint_requests_total",
+			Help:      "Count of requests to the endpoint by result",
+		}, append(labels, "result"))
+	})
+}
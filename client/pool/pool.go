@@ -0,0 +1,542 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/types"
+)
+
+const (
+	defaultHealthCheckInterval = 10 * time.Second
+	defaultHealthCheckTimeout  = 5 * time.Second
+	defaultMaxFailures         = 3
+	// maxThrottledPasses is the count of passes over the endpoints while all of them reject the request due to
+	// the rate limit
+	maxThrottledPasses = 3
+)
+
+var (
+	// ErrNoEndpoints is returned if the pool has no endpoints.
+	ErrNoEndpoints = errors.New("no endpoints")
+
+	// lowestHeightRe matches the error of the node which doesn't have the requested height anymore
+	lowestHeightRe = regexp.MustCompile(`lowest height is (\d+)`)
+)
+
+type (
+	// Status is the state of the node reported by the status check.
+	Status struct {
+		// LatestHeight is the last block height of the node
+		LatestHeight int64
+		// EarliestHeight is the lowest height available on the pruned node, 0 if unknown
+		EarliestHeight int64
+	}
+
+	// CheckFn returns the status of the node or an error if it is unavailable.
+	CheckFn[T any] func(ctx context.Context, client T) (Status, error)
+
+	// Endpoint is the client of a single node with its health state.
+	Endpoint[T any] struct {
+		Client T
+		Host   string
+		// Archive is set for the node which keeps all heights, it is spared for the heights pruned on the others
+		Archive bool
+
+		limiter  *limiter
+		inFlight atomic.Int64
+
+		mu       sync.RWMutex
+		status   Status
+		latency  time.Duration
+		failures int
+		healthy  bool
+	}
+
+	// Pool routes the requests to the healthy endpoints which serve the requested height.
+	// The pruned endpoints are preferred over the archive ones and the least loaded endpoint is tried first,
+	// the others are tried in turn if the request fails.
+	Pool[T any] struct {
+		log       *zerolog.Logger
+		check     CheckFn[T]
+		throttled ThrottledFn
+		stop      context.CancelFunc
+		wg        sync.WaitGroup
+		name      string
+		endpoints []*Endpoint[T]
+		cfg       Config
+	}
+)
+
+// New creates the pool of the named client. The endpoints are checked by the check function and
+// slowed down if the request error is reported by the throttled function.
+func New[T any](cfg Config, l zerolog.Logger, name string, check CheckFn[T], throttled ThrottledFn) *Pool[T] {
+	l = l.With().Str("cmp", name+"-pool").Logger()
+
+	if cfg.HealthCheckInterval <= 0 {
+		cfg.HealthCheckInterval = defaultHealthCheckInterval
+	}
+
+	if cfg.HealthCheckTimeout <= 0 {
+		cfg.HealthCheckTimeout = defaultHealthCheckTimeout
+	}
+
+	if cfg.MaxFailures <= 0 {
+		cfg.MaxFailures = defaultMaxFailures
+	}
+
+	if cfg.MetricsEnabled {
+		registerMetrics()
+	}
+
+	return &Pool[T]{
+		cfg:       cfg,
+		log:       &l,
+		name:      name,
+		check:     check,
+		throttled: throttled,
+		stop:      func() {},
+	}
+}
+
+// Add adds the endpoint to the pool. It must be called before Start.
+func (p *Pool[T]) Add(host string, client T, archive bool) {
+	e := &Endpoint[T]{
+		Host:    host,
+		Client:  client,
+		Archive: archive,
+		limiter: newLimiter(p.cfg.RateLimit, p.cfg.RateBurst, p.cfg.MaxInFlight),
+	}
+
+	if p.cfg.MetricsEnabled {
+		rateLimitGauge.WithLabelValues(p.name, host).Set(e.limiter.currentLimit())
+	}
+
+	p.endpoints = append(p.endpoints, e)
+}
+
+// Endpoints returns all endpoints of the pool.
+func (p *Pool[T]) Endpoints() []*Endpoint[T] { return p.endpoints }
+
+// Start checks the endpoints and runs the periodic status checks.
+// It returns an error if no endpoint is healthy.
+func (p *Pool[T]) Start(ctx context.Context) error {
+	if len(p.endpoints) == 0 {
+		return ErrNoEndpoints
+	}
+
+	p.checkAll(ctx)
+
+	if !p.anyHealthy() {
+		return fmt.Errorf("no healthy %s endpoints", p.name)
+	}
+
+	ctx, p.stop = context.WithCancel(context.Background())
+
+	p.wg.Add(1)
+	go p.run(ctx)
+
+	return nil
+}
+
+// Stop stops the status checks.
+func (p *Pool[T]) Stop() {
+	p.stop()
+	p.wg.Wait()
+}
+
+// Do calls fn with the clients of the endpoints serving the height until it succeeds.
+// A non-positive height is served by the endpoints close to the tip.
+// The error of the last call is returned if all endpoints fail,
+// it is types.ErrHeightPruned if the height is not available on any endpoint.
+// The endpoints are tried again after the cooldown while all of them reject the request due to the rate limit.
+func (p *Pool[T]) Do(ctx context.Context, height int64, fn func(ctx context.Context, client T) error) error {
+	if len(p.endpoints) == 0 {
+		return ErrNoEndpoints
+	}
+
+	for pass := 1; ; pass++ {
+		err, pruned, throttled := p.try(ctx, height, fn)
+		switch {
+		case err == nil:
+			return nil
+		case throttled && pass < maxThrottledPasses && ctx.Err() == nil:
+			continue
+		case pruned:
+			return fmt.Errorf("%w: %w", types.ErrHeightPruned, err)
+		default:
+			return err
+		}
+	}
+}
+
+// try calls fn with the clients of the endpoints serving the height until it succeeds.
+// It reports whether all endpoints have pruned the height or rejected the request due to the rate limit.
+func (p *Pool[T]) try(ctx context.Context, height int64, fn func(ctx context.Context, client T) error) (
+	err error, pruned, throttled bool) {
+
+	candidates := p.candidates(height)
+	if len(candidates) == 0 {
+		return fmt.Errorf("height %d", height), true, false
+	}
+
+	pruned, throttled = true, true
+
+	for i, e := range candidates {
+		if err = p.call(ctx, e, fn); err == nil {
+			return nil, false, false
+		}
+
+		if ctx.Err() != nil {
+			return err, false, false
+		}
+
+		if earliest, ok := lowestHeight(err); ok {
+			p.setEarliestHeight(e, earliest)
+		} else {
+			pruned = false
+		}
+
+		if !p.isThrottled(err) {
+			throttled = false
+		}
+
+		p.log.Debug().Err(err).Str("endpoint", e.Host).Int("attempt", i+1).Int64("height", height).
+			Msg("request failed")
+	}
+
+	return err, pruned, throttled
+}
+
+// call calls fn with the client of the endpoint within its request budget and records the result.
+func (p *Pool[T]) call(ctx context.Context, e *Endpoint[T], fn func(ctx context.Context, client T) error) error {
+	e.inFlight.Add(1)
+	p.setInFlightMetric(e)
+
+	defer func() {
+		e.inFlight.Add(-1)
+		p.setInFlightMetric(e)
+	}()
+
+	release, err := e.limiter.acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = fn(ctx, e.Client)
+	release()
+
+	// the request cancelled by the caller says nothing about the endpoint
+	if ctx.Err() == nil {
+		p.observe(e, err)
+	}
+
+	return err
+}
+
+// observe counts the consecutive failed requests and marks the endpoint unhealthy after the max failures.
+func (p *Pool[T]) observe(e *Endpoint[T], err error) {
+	result := "ok"
+
+	if p.isThrottled(err) {
+		p.slowDown(e, err)
+		return
+	}
+
+	if err == nil {
+		e.limiter.succeeded()
+	}
+
+	e.mu.Lock()
+	if _, ok := lowestHeight(err); ok {
+		// the pruned height says nothing about the endpoint health
+		result = "pruned"
+	} else if err != nil {
+		result = "error"
+
+		e.failures++
+		if e.failures >= p.cfg.MaxFailures && e.healthy {
+			e.healthy = false
+			p.log.Warn().Err(err).Str("endpoint", e.Host).Msg("endpoint is unhealthy after failed requests")
+		}
+	} else {
+		e.failures = 0
+	}
+	healthy := e.healthy
+	e.mu.Unlock()
+
+	if p.cfg.MetricsEnabled {
+		requestsCounter.WithLabelValues(p.name, e.Host, result).Inc()
+		healthyGauge.WithLabelValues(p.name, e.Host).Set(boolToFloat(healthy))
+	}
+}
+
+// slowDown cools the endpoint which rejected the request due to its rate limit down and halves its rate.
+// The throttled request says nothing about the endpoint health.
+func (p *Pool[T]) slowDown(e *Endpoint[T], err error) {
+	cooldown := e.limiter.throttled()
+
+	p.log.Warn().Err(err).Str("endpoint", e.Host).Dur("cooldown", cooldown).
+		Float64("rate_limit", e.limiter.currentLimit()).Msg("endpoint throttles requests")
+
+	if p.cfg.MetricsEnabled {
+		requestsCounter.WithLabelValues(p.name, e.Host, "throttled").Inc()
+		rateLimitGauge.WithLabelValues(p.name, e.Host).Set(e.limiter.currentLimit())
+	}
+}
+
+func (p *Pool[T]) isThrottled(err error) bool {
+	return err != nil && p.throttled != nil && p.throttled(err)
+}
+
+// candidates returns the endpoints in the order to try for the height: the healthy ones serving the height first,
+// then the other healthy ones and the unhealthy ones at last. The endpoints known to have pruned the height are
+// skipped. Endpoints of the same group are ordered by the cooldown after throttling, the archive flag and by load.
+func (p *Pool[T]) candidates(height int64) []*Endpoint[T] {
+	type candidate struct {
+		endpoint *Endpoint[T]
+		latency  time.Duration
+		inFlight int64
+		group    int
+		archive  bool
+		cooling  bool
+	}
+
+	best := p.bestHeight()
+	candidates := make([]candidate, 0, len(p.endpoints))
+
+	for _, e := range p.endpoints {
+		e.mu.RLock()
+		c := candidate{
+			endpoint: e,
+			latency:  e.latency,
+			inFlight: e.inFlight.Load(),
+			group:    2,
+			archive:  e.Archive,
+			cooling:  e.limiter.cooling(),
+		}
+		if e.healthy {
+			c.group = 1
+			if e.serves(height, best, p.cfg.MaxHeightLag) {
+				c.group = 0
+			}
+		}
+		pruned := e.pruned(height)
+		e.mu.RUnlock()
+
+		if !pruned {
+			candidates = append(candidates, c)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.group != b.group {
+			return a.group < b.group
+		}
+
+		if a.cooling != b.cooling {
+			return !a.cooling
+		}
+
+		if a.archive != b.archive {
+			return !a.archive
+		}
+
+		if a.inFlight != b.inFlight {
+			return a.inFlight < b.inFlight
+		}
+
+		// latencies within a millisecond are equal, so such endpoints keep the configured order
+		return a.latency.Milliseconds() < b.latency.Milliseconds()
+	})
+
+	res := make([]*Endpoint[T], len(candidates))
+	for i, c := range candidates {
+		res[i] = c.endpoint
+	}
+
+	return res
+}
+
+// serves reports whether the height is available on the endpoint. The endpoint close to the best height serves
+// the heights above its last checked height too. It must be called under the lock.
+func (e *Endpoint[T]) serves(height, best, maxLag int64) bool {
+	synced := e.status.LatestHeight >= best-maxLag
+	if height <= 0 {
+		return synced
+	}
+
+	return height <= e.status.LatestHeight || synced
+}
+
+// pruned reports whether the height is known to be below the earliest height of the endpoint.
+// It must be called under the lock.
+func (e *Endpoint[T]) pruned(height int64) bool {
+	return height > 0 && e.status.EarliestHeight > 0 && height < e.status.EarliestHeight
+}
+
+// setEarliestHeight raises the earliest height of the endpoint learned from the pruned height error.
+func (p *Pool[T]) setEarliestHeight(e *Endpoint[T], earliest int64) {
+	e.mu.Lock()
+	updated := earliest > e.status.EarliestHeight
+	if updated {
+		e.status.EarliestHeight = earliest
+	}
+	e.mu.Unlock()
+
+	if !updated {
+		return
+	}
+
+	p.log.Info().Str("endpoint", e.Host).Int64("earliest_height", earliest).Msg("endpoint earliest height updated")
+
+	if p.cfg.MetricsEnabled {
+		earliestHeightGauge.WithLabelValues(p.name, e.Host).Set(float64(earliest))
+	}
+}
+
+// lowestHeight returns the earliest height of the node from its pruned height error.
+func lowestHeight(err error) (int64, bool) {
+	if err == nil {
+		return 0, false
+	}
+
+	match := lowestHeightRe.FindStringSubmatch(err.Error())
+	if match == nil {
+		return 0, false
+	}
+
+	height, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return height, true
+}
+
+// bestHeight returns the highest latest height of the healthy endpoints.
+func (p *Pool[T]) bestHeight() int64 {
+	var best int64
+
+	for _, e := range p.endpoints {
+		e.mu.RLock()
+		if e.healthy && e.status.LatestHeight > best {
+			best = e.status.LatestHeight
+		}
+		e.mu.RUnlock()
+	}
+
+	return best
+}
+
+func (p *Pool[T]) anyHealthy() bool {
+	for _, e := range p.endpoints {
+		e.mu.RLock()
+		healthy := e.healthy
+		e.mu.RUnlock()
+
+		if healthy {
+			return true
+		}
+	}
+
+	return false
+}
+
+// run checks the endpoints periodically until ctx is done.
+func (p *Pool[T]) run(ctx context.Context) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.cfg.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.checkAll(ctx)
+		}
+	}
+}
+
+// checkAll checks all endpoints concurrently.
+func (p *Pool[T]) checkAll(ctx context.Context) {
+	wg := &sync.WaitGroup{}
+
+	for _, e := range p.endpoints {
+		wg.Add(1)
+		go func(e *Endpoint[T]) {
+			defer wg.Done()
+			p.checkEndpoint(ctx, e)
+		}(e)
+	}
+
+	wg.Wait()
+}
+
+// checkEndpoint updates the status of the endpoint.
+func (p *Pool[T]) checkEndpoint(ctx context.Context, e *Endpoint[T]) {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.HealthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	status, err := p.check(ctx, e.Client)
+	latency := time.Since(start)
+
+	e.mu.Lock()
+	wasHealthy := e.healthy
+	e.healthy = err == nil
+	if err == nil {
+		// keep the earliest height learned from the errors if the node doesn't report it
+		if status.EarliestHeight == 0 {
+			status.EarliestHeight = e.status.EarliestHeight
+		}
+
+		e.status, e.latency, e.failures = status, latency, 0
+	}
+	e.mu.Unlock()
+
+	switch {
+	case err != nil && wasHealthy:
+		p.log.Warn().Err(err).Str("endpoint", e.Host).Msg("endpoint is unhealthy")
+	case err != nil:
+		p.log.Debug().Err(err).Str("endpoint", e.Host).Msg("endpoint is still unhealthy")
+	case !wasHealthy:
+		p.log.Info().Str("endpoint", e.Host).Int64("latest_height", status.LatestHeight).
+			Int64("earliest_height", status.EarliestHeight).Msg("endpoint is healthy")
+	}
+
+	if p.cfg.MetricsEnabled {
+		healthyGauge.WithLabelValues(p.name, e.Host).Set(boolToFloat(err == nil))
+		if err == nil {
+			latestHeightGauge.WithLabelValues(p.name, e.Host).Set(float64(status.LatestHeight))
+			earliestHeightGauge.WithLabelValues(p.name, e.Host).Set(float64(status.EarliestHeight))
+			latencyGauge.WithLabelValues(p.name, e.Host).Set(latency.Seconds())
+		}
+	}
+}
+
+func (p *Pool[T]) setInFlightMetric(e *Endpoint[T]) {
+	if p.cfg.MetricsEnabled {
+		inFlightGauge.WithLabelValues(p.name, e.Host).Set(float64(e.inFlight.Load()))
+	}
+}
+
+func boolToFloat(v bool) float64 {
+	if v {
+		return 1
+	}
+
+	return 0
+}
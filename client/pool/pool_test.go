@@ -0,0 +1,275 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/types"
+)
+
+// node is a scripted endpoint client.
+type node struct {
+	err     error
+	name    string
+	status  Status
+	lowest  int64 // heights below are pruned, the status doesn't report it
+	archive bool
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (n *node) setErr(err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.err = err
+}
+
+func check(_ context.Context, n *node) (Status, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.status, n.err
+}
+
+// request calls the endpoint and returns its name.
+func request(ctx context.Context, p *Pool[*node], height int64) (string, error) {
+	var name string
+
+	err := p.Do(ctx, height, func(_ context.Context, n *node) error {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+
+		n.calls++
+		if n.err != nil {
+			return n.err
+		}
+
+		if height < n.lowest {
+			return fmt.Errorf("rpc error: code = Unknown desc = height %d is not available, lowest height is %d",
+				height, n.lowest)
+		}
+
+		name = n.name
+		return nil
+	})
+
+	return name, err
+}
+
+func newPool(t *testing.T, nodes ...*node) *Pool[*node] {
+	t.Helper()
+
+	p := New(Config{MaxHeightLag: 5, MaxFailures: 2}, zerolog.Nop(), "test", check, nil)
+	for _, n := range nodes {
+		p.Add(n.name, n, n.archive)
+	}
+
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(p.Stop)
+
+	return p
+}
+
+func TestPoolRouting(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		archive = &node{name: "archive", status: Status{LatestHeight: 90}, archive: true}
+		pruned  = &node{name: "pruned", status: Status{EarliestHeight: 50, LatestHeight: 100}}
+	)
+
+	p := newPool(t, archive, pruned)
+
+	for _, tc := range []struct {
+		height int64
+		want   string
+	}{
+		{0, "pruned"},   // the archive endpoint is behind the tip
+		{10, "archive"}, // the height is pruned
+		{101, "pruned"}, // above the checked height of the synced endpoint
+		{80, "pruned"},  // both serve the height, the archive endpoint is spared
+	} {
+		if got, err := request(ctx, p, tc.height); err != nil || got != tc.want {
+			t.Errorf("height %d: want %s, got %s, err %v", tc.height, tc.want, got, err)
+		}
+	}
+}
+
+func TestPoolFailover(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		first  = &node{name: "first", status: Status{LatestHeight: 100}}
+		second = &node{name: "second", status: Status{LatestHeight: 100}}
+	)
+
+	p := newPool(t, first, second)
+
+	first.setErr(errors.New("unavailable"))
+
+	// the failed endpoint becomes unhealthy after the max failures and isn't tried first anymore
+	for i := 0; i < 3; i++ {
+		if got, err := request(ctx, p, 10); err != nil || got != "second" {
+			t.Fatalf("request %d: want second, got %s, err %v", i, got, err)
+		}
+	}
+
+	if first.calls != 2 {
+		t.Errorf("want 2 calls of the failed endpoint, got %d", first.calls)
+	}
+
+	// the status check restores the endpoint
+	first.setErr(nil)
+	p.checkAll(ctx)
+
+	if got, _ := request(ctx, p, 10); got != "first" {
+		t.Errorf("want first after recovery, got %s", got)
+	}
+
+	// the error of the last endpoint is returned if all of them fail
+	errLast := errors.New("last")
+	first.setErr(errors.New("first"))
+	second.setErr(errLast)
+
+	if _, err := request(ctx, p, 10); !errors.Is(err, errLast) {
+		t.Errorf("want last error, got %v", err)
+	}
+}
+
+func TestPoolStart(t *testing.T) {
+	p := New(Config{}, zerolog.Nop(), "test", check, nil)
+	if err := p.Start(context.Background()); !errors.Is(err, ErrNoEndpoints) {
+		t.Errorf("want ErrNoEndpoints, got %v", err)
+	}
+
+	p.Add("down", &node{err: errors.New("unavailable")}, false)
+	if err := p.Start(context.Background()); err == nil {
+		t.Error("want error without healthy endpoints")
+	}
+}
+
+func TestPoolPrunedHeight(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		pruned  = &node{name: "pruned", status: Status{LatestHeight: 100}, lowest: 50}
+		archive = &node{name: "archive", status: Status{LatestHeight: 100}, archive: true}
+	)
+
+	p := newPool(t, pruned, archive)
+
+	// the earliest height of the pruned endpoint is learned from its error
+	if got, err := request(ctx, p, 10); err != nil || got != "archive" {
+		t.Fatalf("want archive, got %s, err %v", got, err)
+	}
+
+	if got, err := request(ctx, p, 20); err != nil || got != "archive" || pruned.calls != 1 {
+		t.Fatalf("want archive without pruned call, got %s, err %v, pruned calls %d", got, err, pruned.calls)
+	}
+
+	// the pruned height doesn't make the endpoint unhealthy
+	if got, _ := request(ctx, p, 60); got != "pruned" {
+		t.Errorf("want pruned for the recent height, got %s", got)
+	}
+
+	// the failure of the archive endpoint is not the pruned height
+	archive.setErr(errors.New("unavailable"))
+
+	if _, err := request(ctx, p, 10); err == nil || errors.Is(err, types.ErrHeightPruned) {
+		t.Errorf("want archive error, got %v", err)
+	}
+
+	// the height is pruned on all endpoints
+	p2 := newPool(t, &node{name: "pruned", status: Status{LatestHeight: 100}, lowest: 50})
+	if _, err := request(ctx, p2, 10); !errors.Is(err, types.ErrHeightPruned) {
+		t.Errorf("want ErrHeightPruned, got %v", err)
+	}
+
+	if _, err := request(ctx, p2, 10); !errors.Is(err, types.ErrHeightPruned) {
+		t.Errorf("want ErrHeightPruned without calls, got %v", err)
+	}
+}
+
+func TestPoolThrottled(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		errThrottled = errors.New("429 Too Many Requests")
+		first        = &node{name: "first", status: Status{LatestHeight: 100}}
+		second       = &node{name: "second", status: Status{LatestHeight: 100}}
+	)
+
+	p := New(Config{MaxFailures: 1, RateLimit: 100}, zerolog.Nop(), "test", check,
+		func(err error) bool { return errors.Is(err, errThrottled) })
+	p.Add(first.name, first, false)
+	p.Add(second.name, second, false)
+
+	if err := p.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(p.Stop)
+
+	first.setErr(errThrottled)
+
+	if got, err := request(ctx, p, 10); err != nil || got != "second" {
+		t.Fatalf("want second, got %s, err %v", got, err)
+	}
+
+	// the throttled endpoint is healthy, but it is cooled down and slowed down
+	e := p.Endpoints()[0]
+
+	e.mu.RLock()
+	healthy := e.healthy
+	e.mu.RUnlock()
+
+	if !healthy || !e.limiter.cooling() || e.limiter.currentLimit() != 50 {
+		t.Fatalf("want healthy cooling endpoint with halved rate, got healthy %v, cooling %v, rate %v",
+			healthy, e.limiter.cooling(), e.limiter.currentLimit())
+	}
+
+	first.setErr(nil)
+
+	if got, _ := request(ctx, p, 10); got != "second" || first.calls != 1 {
+		t.Errorf("want second while first is cooling, got %s, first calls %d", got, first.calls)
+	}
+
+	// the rate is restored step by step
+	e.limiter.succeeded()
+
+	if got := e.limiter.currentLimit(); got != 55 {
+		t.Errorf("want restored rate 55, got %v", got)
+	}
+}
+
+func TestLimiterMaxInFlight(t *testing.T) {
+	l := newLimiter(0, 0, 1)
+
+	release, err := l.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err = l.acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("want context.Canceled without free slots, got %v", err)
+	}
+
+	release()
+
+	if _, err = l.acquire(context.Background()); err != nil {
+		t.Errorf("want free slot after release, got %v", err)
+	}
+}
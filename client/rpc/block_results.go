@@ -6,11 +6,14 @@ import (
 	coretypes "github.com/cometbft/cometbft/rpc/core/types"
 )
 
-func (c *Client) GetBlockResults(ctx context.Context, height int64) (*coretypes.ResultBlockResults, error) {
-	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
-	defer cancel()
+func (c *Client) GetBlockResults(ctx context.Context, height int64) (result *coretypes.ResultBlockResults, err error) {
+	err = c.pool.Do(ctx, height, func(ctx context.Context, n *node) error {
+		ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+		defer cancel()
 
-	result, err := c.RPCClient.BlockResults(ctx, &height)
+		result, err = n.BlockResults(ctx, &height)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -3,27 +3,75 @@ package rpc
 import (
 	"context"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	cometbftHttp "github.com/cometbft/cometbft/rpc/client/http"
 	jsonrpcclient "github.com/cometbft/cometbft/rpc/jsonrpc/client"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/client/pool"
 )
 
-type Client struct {
-	*jsonrpcclient.WSClient
-	*cometbftHttp.WSEvents
+const defaultSubscribeTimeout = time.Minute
 
-	RPCClient *cometbftHttp.HTTP
+type (
+	// node is the client of a single RPC endpoint.
+	node struct {
+		*cometbftHttp.HTTP
+		host string
+	}
 
-	cfg Config
+	Client struct {
+		log  *zerolog.Logger
+		pool *pool.Pool[*node]
+		cfg  Config
+	}
+)
+
+func New(cfg Config, l zerolog.Logger) *Client {
+	l = l.With().Str("cmp", "rpc-client").Logger()
+
+	if cfg.SubscribeTimeout <= 0 {
+		cfg.SubscribeTimeout = defaultSubscribeTimeout
+	}
+
+	c := &Client{cfg: cfg, log: &l}
+	c.pool = pool.New(pool.Config{
+		HealthCheckInterval: cfg.HealthCheckInterval,
+		HealthCheckTimeout:  cfg.Timeout,
+		MaxHeightLag:        cfg.MaxHeightLag,
+		MaxFailures:         cfg.MaxFailures,
+		RateLimit:           cfg.RateLimit,
+		RateBurst:           cfg.RateBurst,
+		MaxInFlight:         cfg.MaxInFlight,
+		MetricsEnabled:      cfg.MetricsEnabled,
+	}, l, "rpc", checkNode, isThrottled)
+
+	return c
 }
 
-func New(cfg Config) *Client {
-	return &Client{cfg: cfg}
+func (c *Client) Start(ctx context.Context) error {
+	for _, host := range c.cfg.Hosts {
+		if err := c.addNode(host, false); err != nil {
+			return err
+		}
+	}
+
+	for _, host := range c.cfg.ArchiveHosts {
+		if err := c.addNode(host, true); err != nil {
+			return err
+		}
+	}
+
+	return c.pool.Start(ctx)
 }
 
-func (c *Client) Start(_ context.Context) error {
-	httpClient, err := jsonrpcclient.DefaultHTTPClient(c.cfg.Host)
+// addNode adds the client of the endpoint to the pool.
+func (c *Client) addNode(host string, archive bool) error {
+	httpClient, err := jsonrpcclient.DefaultHTTPClient(host)
 	if err != nil {
 		return err
 	}
@@ -37,22 +85,40 @@ func (c *Client) Start(_ context.Context) error {
 		)
 	}
 
-	c.RPCClient, err = cometbftHttp.NewWithClient(c.cfg.Host, "/websocket", httpClient)
+	cli, err := cometbftHttp.NewWithClient(host, "/websocket", httpClient)
 	if err != nil {
 		return err
 	}
 
-	if err = c.RPCClient.Start(); err != nil {
-		return err
-	}
+	c.pool.Add(host, &node{HTTP: cli, host: host}, archive)
 
 	return nil
 }
 
 func (c *Client) Stop(_ context.Context) error {
-	if err := c.RPCClient.Stop(); err != nil {
-		return err
-	}
+	c.pool.Stop()
 
 	return nil
 }
+
+// checkNode returns the latest height and the pruning window of the node.
+func checkNode(ctx context.Context, n *node) (pool.Status, error) {
+	status, err := n.Status(ctx)
+	if err != nil {
+		return pool.Status{}, err
+	}
+
+	return pool.Status{
+		LatestHeight:   status.SyncInfo.LatestBlockHeight,
+		EarliestHeight: status.SyncInfo.EarliestBlockHeight,
+	}, nil
+}
+
+// throttledStatus is the HTTP status of the node response rejected due to the rate limit,
+// the JSON-RPC client reports it within the error text.
+var throttledStatus = "Status: " + strconv.Itoa(http.StatusTooManyRequests)
+
+// isThrottled reports whether the node rejected the request with 429 Too Many Requests.
+func isThrottled(err error) bool {
+	return strings.Contains(err.Error(), throttledStatus)
+}
@@ -3,7 +3,15 @@ package rpc
 import "time"
 
 type Config struct {
-	Host           string        `env:"RPC_URL" envDefault:"http://localhost:26657"`
-	MetricsEnabled bool          `env:"METRICS_ENABLED" envDefault:"false"`
-	Timeout        time.Duration `env:"RPC_TIMEOUT" envDefault:"15s"`
+	Hosts               []string      `env:"RPC_URL" envDefault:"http://localhost:26657" envSeparator:","`
+	ArchiveHosts        []string      `env:"RPC_ARCHIVE_URL" envSeparator:","` // endpoints keeping all heights
+	MetricsEnabled      bool          `env:"METRICS_ENABLED" envDefault:"false"`
+	Timeout             time.Duration `env:"RPC_TIMEOUT" envDefault:"15s"`
+	SubscribeTimeout    time.Duration `env:"RPC_SUBSCRIBE_TIMEOUT" envDefault:"1m"` // max interval between new blocks
+	HealthCheckInterval time.Duration `env:"RPC_HEALTH_CHECK_INTERVAL" envDefault:"10s"`
+	MaxHeightLag        int64         `env:"RPC_MAX_HEIGHT_LAG" envDefault:"10"` // max lag behind the best endpoint at tip
+	MaxFailures         int           `env:"RPC_MAX_FAILURES" envDefault:"3"`    // consecutive failures to mark unhealthy
+	RateLimit           float64       `env:"RPC_RATE_LIMIT" envDefault:"0"`      // requests per second to an endpoint, 0 is unlimited
+	RateBurst           int           `env:"RPC_RATE_BURST" envDefault:"0"`      // 0 is the rate limit rounded up
+	MaxInFlight         int           `env:"RPC_MAX_IN_FLIGHT" envDefault:"0"`   // concurrent requests to an endpoint, 0 is unlimited
 }
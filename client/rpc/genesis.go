@@ -9,8 +9,17 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
-func (c *Client) Genesis(ctx context.Context) (*cometbfttypes.GenesisDoc, error) {
-	chunk, err := c.RPCClient.GenesisChunked(ctx, 0)
+func (c *Client) Genesis(ctx context.Context) (genesis *cometbfttypes.GenesisDoc, err error) {
+	err = c.pool.Do(ctx, 0, func(ctx context.Context, n *node) error {
+		genesis, err = c.genesis(ctx, n)
+		return err
+	})
+
+	return genesis, err
+}
+
+func (c *Client) genesis(ctx context.Context, n *node) (*cometbfttypes.GenesisDoc, error) {
+	chunk, err := n.GenesisChunked(ctx, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -28,7 +37,7 @@ func (c *Client) Genesis(ctx context.Context) (*cometbfttypes.GenesisDoc, error)
 		func(index uint) {
 			g.Go(func() error {
 				var ch []byte
-				ch, err = c.getGenesisChunk(ctx2, index)
+				ch, err = getGenesisChunk(ctx2, n, index)
 				if err != nil {
 					return err
 				}
@@ -57,8 +66,8 @@ func (c *Client) Genesis(ctx context.Context) (*cometbfttypes.GenesisDoc, error)
 	return resp, nil
 }
 
-func (c *Client) getGenesisChunk(ctx context.Context, id uint) ([]byte, error) {
-	resp, err := c.RPCClient.GenesisChunked(ctx, id)
+func getGenesisChunk(ctx context.Context, n *node, id uint) ([]byte, error) {
+	resp, err := n.GenesisChunked(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -4,14 +4,19 @@ import (
 	"context"
 )
 
-func (c *Client) GetLastBlockHeight(ctx context.Context) (int64, error) {
-	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
-	defer cancel()
+func (c *Client) GetLastBlockHeight(ctx context.Context) (height int64, err error) {
+	err = c.pool.Do(ctx, 0, func(ctx context.Context, n *node) error {
+		ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+		defer cancel()
 
-	resp, err := c.RPCClient.ABCIInfo(ctx)
-	if err != nil {
-		return 0, err
-	}
+		resp, err := n.ABCIInfo(ctx)
+		if err != nil {
+			return err
+		}
 
-	return resp.Response.LastBlockHeight, nil
+		height = resp.Response.LastBlockHeight
+		return nil
+	})
+
+	return height, err
 }
@@ -2,10 +2,93 @@ package rpc
 
 import (
 	"context"
+	"sync"
+	"time"
 
+	cmtjson "github.com/cometbft/cometbft/libs/json"
 	cometbftcoretypes "github.com/cometbft/cometbft/rpc/core/types"
+	jsonrpcclient "github.com/cometbft/cometbft/rpc/jsonrpc/client"
 )
 
-func (c *Client) SubscribeNewBlocks(ctx context.Context) (<-chan cometbftcoretypes.ResultEvent, error) {
-	return c.RPCClient.Subscribe(ctx, "", "tm.event = 'NewBlock'")
+const newBlockQuery = "tm.event = 'NewBlock'"
+
+// SubscribeNewBlocks subscribes to the new blocks over a dedicated websocket connection to the endpoint at tip.
+// The channel is closed when ctx is done or the subscription is lost: the connection is broken or no block arrives
+// within the subscribe timeout. The connection is not restored silently, because the blocks produced meanwhile
+// are not delivered, so the caller must subscribe again and catch up the missed heights.
+func (c *Client) SubscribeNewBlocks(ctx context.Context) (eventCh <-chan cometbftcoretypes.ResultEvent, err error) {
+	err = c.pool.Do(ctx, 0, func(ctx context.Context, n *node) error {
+		eventCh, err = c.subscribeNewBlocks(ctx, n.host)
+		return err
+	})
+
+	return eventCh, err
+}
+
+func (c *Client) subscribeNewBlocks(ctx context.Context, host string) (<-chan cometbftcoretypes.ResultEvent, error) {
+	var (
+		lost     = make(chan struct{})
+		lostOnce sync.Once
+	)
+
+	ws, err := jsonrpcclient.NewWS(host, "/websocket",
+		jsonrpcclient.MaxReconnectAttempts(0),
+		// the server forgets the subscription of the broken connection
+		jsonrpcclient.OnReconnect(func() { lostOnce.Do(func() { close(lost) }) }),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = ws.Start(); err != nil {
+		return nil, err
+	}
+
+	if err = ws.Subscribe(ctx, newBlockQuery); err != nil {
+		_ = ws.Stop()
+		return nil, err
+	}
+
+	eventCh := make(chan cometbftcoretypes.ResultEvent)
+
+	go func() {
+		defer close(eventCh)
+		defer ws.Stop() //nolint:errcheck
+
+		timer := time.NewTimer(c.cfg.SubscribeTimeout)
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-lost:
+				return
+			case <-timer.C:
+				return
+			case resp, ok := <-ws.ResponsesCh:
+				if !ok || resp.Error != nil {
+					return
+				}
+
+				var event cometbftcoretypes.ResultEvent
+				if err := cmtjson.Unmarshal(resp.Result, &event); err != nil || event.Query != newBlockQuery {
+					continue // the subscription confirmation
+				}
+
+				if !timer.Stop() {
+					<-timer.C
+				}
+				timer.Reset(c.cfg.SubscribeTimeout)
+
+				select {
+				case <-ctx.Done():
+					return
+				case eventCh <- event:
+				}
+			}
+		}
+	}()
+
+	return eventCh, nil
 }
@@ -3,15 +3,19 @@ package broker
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
-	jsoniter "github.com/json-iterator/go"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/delivery/broker/serde"
 )
 
 const (
 	MsgErrJSONMarshalFail   = "json marshal fail: %w"
+	MsgErrEncodeFail        = "encode fail: %w"
+	MsgErrClaimCheck        = "can't create claim check: %w"
 	MsgErrCreateProducer    = "can't create producer connection to broker: %w "
 	MsgErrCreateAdminClient = "can't create admin client connection to broker: %w"
 	MsgErrCreateTopics      = "can't create topics in broker: %w"
@@ -24,6 +28,20 @@ type (
 		log *zerolog.Logger
 		p   *kafka.Producer
 		ac  *kafka.AdminClient
+
+		// txProducers is the pool of transactional producers, one per open transaction
+		txProducers chan *txProducer
+		txStopped   atomic.Bool
+
+		// encoder encodes messages to the configured format
+		encoder serde.Encoder
+
+		// deadLetter is the configured topic of the failure records
+		deadLetter Topic
+
+		// version is the crawler version sent in the record headers
+		version string
+
 		cfg Config
 	}
 
@@ -34,8 +52,14 @@ func New(cfg Config, l zerolog.Logger, opts ...opt) *Broker {
 	l = l.With().Str("cmp", "broker").Logger()
 
 	b := &Broker{
-		log: &l,
-		cfg: cfg,
+		log:        &l,
+		cfg:        cfg,
+		encoder:    serde.JSON{},
+		deadLetter: DeadLetter,
+	}
+
+	if cfg.DeadLetterTopic != "" {
+		b.deadLetter = newTopic(cfg.DeadLetterTopic)
 	}
 
 	for _, apply := range opts {
@@ -50,6 +74,29 @@ func (b *Broker) Start(ctx context.Context) error {
 		return nil
 	}
 
+	if err := validatePartitionKey(b.cfg.PartitionKey); err != nil {
+		return err
+	}
+
+	if err := validateLargeMessageMode(b.cfg.LargeMessageMode); err != nil {
+		return err
+	}
+
+	encoder, err := serde.New(b.cfg.Encoding,
+		serde.NewRegistry(b.cfg.SchemaRegistryURL, b.cfg.SchemaRegistryUser, b.cfg.SchemaRegistryPassword),
+		rawSchemas,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err = encoder.Register(ctx); err != nil {
+		b.log.Error().Err(err).Msg("can't register schemas")
+		return err
+	}
+
+	b.encoder = encoder
+
 	// create an admin client connection
 	ac, err := kafka.NewAdminClient(&kafka.ConfigMap{
 		"bootstrap.servers": b.cfg.ServerURL,
@@ -59,9 +106,11 @@ func (b *Broker) Start(ctx context.Context) error {
 		return errors.Wrap(err, MsgErrCreateAdminClient)
 	}
 
-	kafkaTopics := make([]kafka.TopicSpecification, len(allTopics))
+	topics := removeDuplicates(append(append([]string(nil), allTopics...), *b.deadLetter))
+
+	kafkaTopics := make([]kafka.TopicSpecification, len(topics))
 	// kafkaPartitions := make([]kafka.PartitionsSpecification, len(topics))
-	for i, topic := range allTopics {
+	for i, topic := range topics {
 		kafkaTopics[i] = kafka.TopicSpecification{
 			Topic:         topic,
 			NumPartitions: b.cfg.PartitionsCount,
@@ -79,35 +128,40 @@ func (b *Broker) Start(ctx context.Context) error {
 	}
 
 	// create a producer connection
-	p, err := kafka.NewProducer(&kafka.ConfigMap{
-		"bootstrap.servers": b.cfg.ServerURL,
-		"message.max.bytes": b.cfg.MaxMessageBytes,
-		"go.batch.producer": b.cfg.BatchProducer,
-	})
+	producerCfg := b.producerConfig()
+
+	p, err := kafka.NewProducer(&producerCfg)
 	if err != nil {
 		b.log.Error().Err(err).Msg(MsgErrCreateProducer)
 		return errors.New(MsgErrCreateProducer)
 	}
 
-	go func(drs chan kafka.Event) {
-		for ev := range drs {
-			m, ok := ev.(*kafka.Message)
-			if !ok {
-				continue
-			}
-
-			if err := m.TopicPartition.Error; err != nil {
-				b.log.Error().Str("topic_partition", m.TopicPartition.String()).Err(err).Msg("delivery error")
-			}
-		}
-	}(p.Events())
+	go b.logDeliveryErrors(p.Events())
 
 	b.p = p
 	b.ac = ac
 
+	if b.cfg.TransactionalID != "" {
+		return b.startTransactionalProducers(ctx)
+	}
+
 	return nil
 }
 
+// logDeliveryErrors reads delivery reports of the producer and logs failed ones.
+func (b *Broker) logDeliveryErrors(drs chan kafka.Event) {
+	for ev := range drs {
+		m, ok := ev.(*kafka.Message)
+		if !ok {
+			continue
+		}
+
+		if err := m.TopicPartition.Error; err != nil {
+			b.log.Error().Str("topic_partition", m.TopicPartition.String()).Err(err).Msg("delivery error")
+		}
+	}
+}
+
 func (b *Broker) Stop(ctx context.Context) error {
 	if !b.cfg.Enabled {
 		return nil
@@ -118,41 +172,67 @@ func (b *Broker) Stop(ctx context.Context) error {
 	b.p.Close()
 	b.ac.Close()
 
+	b.stopTransactionalProducers()
+
 	return nil
 }
 
-// marshalAndProduce marshals the message to JSON and produces it to the kafka.
-func (b *Broker) marshalAndProduce(topic Topic, msg interface{}) error {
-	data, err := jsoniter.Marshal(msg)
+// marshalAndProduce encodes the message and produces it to the kafka.
+// The message is produced within the transaction if the context holds one.
+func (b *Broker) marshalAndProduce(ctx context.Context, topic Topic, msg interface{}) error {
+	data, err := b.encoder.Encode(*topic, msg)
+	if err != nil {
+		return errors.Wrap(err, MsgErrEncodeFail)
+	}
+
+	recs, err := b.claimCheck(newRecord(ctx, topic, data, msg, b.cfg.PartitionKey, b.version))
 	if err != nil {
-		return errors.Wrap(err, MsgErrJSONMarshalFail)
+		return errors.Wrap(err, MsgErrClaimCheck)
+	}
+
+	p := b.p
+	if tx, ok := transactionFromContext(ctx); ok {
+		p = tx.p
 	}
 
-	if err = b.produce(topic, data); err != nil {
-		return errors.Wrap(err, MsgErrProduceTopic)
+	for _, rec := range recs {
+		if err = b.produce(p, newMessage(rec)); err != nil {
+			return errors.Wrap(err, MsgErrProduceTopic)
+		}
 	}
 
 	return nil
 }
 
+// producerConfig returns the config of the producers.
+func (b *Broker) producerConfig() kafka.ConfigMap {
+	return kafka.ConfigMap{
+		"bootstrap.servers": b.cfg.ServerURL,
+		"message.max.bytes": b.cfg.MaxMessageBytes,
+		"go.batch.producer": b.cfg.BatchProducer,
+		"partitioner":       b.cfg.Partitioner,
+		"compression.type":  b.cfg.Compression,
+		"compression.level": b.cfg.CompressionLevel,
+	}
+}
+
 // produce produces the message to the kafka.
-func (b *Broker) produce(topic Topic, data []byte) error {
+func (b *Broker) produce(p *kafka.Producer, msg *kafka.Message) error {
 	if !b.cfg.Enabled {
 		return nil
 	}
 
-	err := b.p.Produce(&kafka.Message{
-		TopicPartition: kafka.TopicPartition{Topic: topic, Partition: kafka.PartitionAny},
-		Value:          data,
-	}, nil)
+	topic := msg.TopicPartition.Topic
+
+	err := p.Produce(msg, nil)
 
 	if kafkaError, ok := err.(kafka.Error); ok && kafkaError.Code() == kafka.ErrQueueFull {
 		b.log.Info().Str("topic", *topic).Msg("kafka local queue full error. Going to Flush then retry")
-		flushedMessages := b.p.Flush(30 * 1000)
+		flushedMessages := p.Flush(30 * 1000)
 		b.log.Info().Str("topic", *topic).Int("flushed_messages", flushedMessages).
 			Msg("flushed kafka messages. Outstanding events still un-flushed")
 
-		return b.produce(topic, data)
+		return b.produce(p, msg)
 	}
 
 	if err != nil {
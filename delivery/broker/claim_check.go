@@ -0,0 +1,155 @@
+package broker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	LargeMessageNone   = "none"   // oversize messages fail
+	LargeMessageChunks = "chunks" // split into ordered chunks and a manifest record
+	LargeMessageBlob   = "blob"   // store in the blob directory and publish a reference record
+
+	HeaderClaimCheck   = "claim_check"
+	HeaderClaimCheckID = "claim_check_id"
+	HeaderChunkIndex   = "chunk_index"
+	HeaderChunkCount   = "chunk_count"
+
+	// recordOverhead is reserved for the key, headers and batch framing of the record
+	recordOverhead = 64 * 1024
+)
+
+// ClaimCheck is published instead of the oversize message. Consumers reassemble the message
+// from the chunk topic records with the claim check id, or read it from the blob URI.
+type ClaimCheck struct {
+	Type   string `json:"type"`
+	ID     string `json:"id"` // sha256 of the message
+	Topic  string `json:"topic,omitempty"`
+	URI    string `json:"uri,omitempty"`
+	Size   int    `json:"size"`
+	Chunks int    `json:"chunks,omitempty"`
+}
+
+func validateLargeMessageMode(mode string) error {
+	switch mode {
+	case LargeMessageNone, LargeMessageChunks, LargeMessageBlob:
+		return nil
+	default:
+		return fmt.Errorf("unknown large message mode %q", mode)
+	}
+}
+
+// maxRecordSize returns the max size of the record value which fits into the message size limit.
+func (b *Broker) maxRecordSize() int {
+	if b.cfg.LargeMessageThreshold > 0 {
+		return b.cfg.LargeMessageThreshold
+	}
+
+	if b.cfg.MaxMessageBytes > 2*recordOverhead {
+		return b.cfg.MaxMessageBytes - recordOverhead
+	}
+
+	return b.cfg.MaxMessageBytes / 2
+}
+
+// claimCheck replaces the oversize record with the records to produce in order: chunks and the manifest.
+// Returns the record as is if it fits or large messages are not supported for the topic.
+func (b *Broker) claimCheck(rec Record) ([]Record, error) {
+	chunkTopic, ok := chunkTopics[rec.Topic]
+	if b.cfg.LargeMessageMode == LargeMessageNone || !ok || len(rec.Value) <= b.maxRecordSize() {
+		return []Record{rec}, nil
+	}
+
+	sum := sha256.Sum256(rec.Value)
+	cc := ClaimCheck{
+		Type: b.cfg.LargeMessageMode,
+		ID:   hex.EncodeToString(sum[:]),
+		Size: len(rec.Value),
+	}
+
+	res := make([]Record, 0)
+
+	switch b.cfg.LargeMessageMode {
+	case LargeMessageChunks:
+		size := b.maxRecordSize()
+		cc.Topic = *chunkTopic
+		cc.Chunks = (len(rec.Value) + size - 1) / size
+
+		for i := 0; i < cc.Chunks; i++ {
+			end := (i + 1) * size
+			if end > len(rec.Value) {
+				end = len(rec.Value)
+			}
+
+			res = append(res, Record{
+				Topic: cc.Topic,
+				Key:   rec.Key,
+				Value: rec.Value[i*size : end],
+				Headers: append(append([]Header(nil), rec.Headers...),
+					Header{Key: HeaderClaimCheckID, Value: cc.ID},
+					Header{Key: HeaderChunkIndex, Value: strconv.Itoa(i)},
+					Header{Key: HeaderChunkCount, Value: strconv.Itoa(cc.Chunks)},
+				),
+			})
+		}
+	case LargeMessageBlob:
+		uri, err := b.writeBlob(rec.Topic, cc.ID, rec.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		cc.URI = uri
+	}
+
+	manifest, err := jsoniter.Marshal(struct {
+		ClaimCheck ClaimCheck `json:"claim_check"`
+	}{cc})
+	if err != nil {
+		return nil, err
+	}
+
+	b.log.Info().
+		Str("topic", rec.Topic).
+		Str("mode", cc.Type).
+		Int("size", cc.Size).
+		Msg("message exceeds the max size. publish claim check")
+
+	return append(res, Record{
+		Topic:   rec.Topic,
+		Key:     rec.Key,
+		Value:   manifest,
+		Headers: append(append([]Header(nil), rec.Headers...), Header{Key: HeaderClaimCheck, Value: cc.Type}),
+	}), nil
+}
+
+// writeBlob writes the message to <blob dir>/<topic>/<id> and returns its URI.
+// The id is the content hash, so reprocessing of the height overwrites the same blob.
+func (b *Broker) writeBlob(topic, id string, data []byte) (string, error) {
+	dir := filepath.Join(b.cfg.BlobDir, topic)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("can't create blob dir: %w", err)
+	}
+
+	path, err := filepath.Abs(filepath.Join(dir, id))
+	if err != nil {
+		return "", err
+	}
+
+	// write to a temp file and rename, so readers never see a partial blob
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o644); err != nil { //nolint:gosec
+		return "", fmt.Errorf("can't write blob: %w", err)
+	}
+
+	if err = os.Rename(tmp, path); err != nil {
+		return "", fmt.Errorf("can't write blob: %w", err)
+	}
+
+	return "file://" + filepath.ToSlash(path), nil
+}
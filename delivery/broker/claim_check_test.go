@@ -0,0 +1,119 @@
+package broker
+
+import (
+	"bytes"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/rs/zerolog"
+)
+
+func TestClaimCheck(t *testing.T) {
+	var (
+		value = bytes.Repeat([]byte("x"), 25)
+		rec   = Record{
+			Topic:   *RawBlockResults,
+			Key:     []byte("5"),
+			Value:   value,
+			Headers: []Header{{Key: HeaderHeight, Value: "5"}},
+		}
+	)
+
+	t.Run("fits", func(t *testing.T) {
+		b := New(Config{LargeMessageMode: LargeMessageChunks, LargeMessageThreshold: 25}, zerolog.Nop())
+
+		recs, err := b.claimCheck(rec)
+		if err != nil || len(recs) != 1 || !bytes.Equal(recs[0].Value, value) {
+			t.Fatalf("want the record as is, got %v: %v", recs, err)
+		}
+	})
+
+	t.Run("chunks", func(t *testing.T) {
+		b := New(Config{LargeMessageMode: LargeMessageChunks, LargeMessageThreshold: 10}, zerolog.Nop())
+
+		recs, err := b.claimCheck(rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// 3 chunks and the manifest
+		if len(recs) != 4 {
+			t.Fatalf("want 4 records, got %d", len(recs))
+		}
+
+		var joined []byte
+		for i, chunk := range recs[:3] {
+			if chunk.Topic != *RawBlockResultsChunks || string(chunk.Key) != "5" {
+				t.Errorf("chunk %d: unexpected topic %s or key %s", i, chunk.Topic, chunk.Key)
+			}
+			joined = append(joined, chunk.Value...)
+		}
+
+		if !bytes.Equal(joined, value) {
+			t.Errorf("want joined chunks %s, got %s", value, joined)
+		}
+
+		cc := manifest(t, recs[3])
+		if cc.Type != LargeMessageChunks || cc.Chunks != 3 || cc.Size != len(value) || cc.Topic != *RawBlockResultsChunks {
+			t.Errorf("unexpected claim check: %+v", cc)
+		}
+	})
+
+	t.Run("blob", func(t *testing.T) {
+		b := New(Config{LargeMessageMode: LargeMessageBlob, LargeMessageThreshold: 10, BlobDir: t.TempDir()},
+			zerolog.Nop())
+
+		recs, err := b.claimCheck(rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(recs) != 1 {
+			t.Fatalf("want the manifest only, got %d records", len(recs))
+		}
+
+		cc := manifest(t, recs[0])
+		if cc.Type != LargeMessageBlob || !strings.HasPrefix(cc.URI, "file://") {
+			t.Fatalf("unexpected claim check: %+v", cc)
+		}
+
+		u, err := url.Parse(cc.URI)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := os.ReadFile(u.Path)
+		if err != nil || !bytes.Equal(data, value) {
+			t.Errorf("want blob %s, got %s: %v", value, data, err)
+		}
+	})
+}
+
+func manifest(t *testing.T, rec Record) ClaimCheck {
+	t.Helper()
+
+	if rec.Topic != *RawBlockResults {
+		t.Errorf("want manifest in %s, got %s", *RawBlockResults, rec.Topic)
+	}
+
+	var hasHeader bool
+	for _, h := range rec.Headers {
+		hasHeader = hasHeader || h.Key == HeaderClaimCheck
+	}
+
+	if !hasHeader {
+		t.Errorf("manifest has no %s header", HeaderClaimCheck)
+	}
+
+	var res struct {
+		ClaimCheck ClaimCheck `json:"claim_check"`
+	}
+	if err := jsoniter.Unmarshal(rec.Value, &res); err != nil {
+		t.Fatal(err)
+	}
+
+	return res.ClaimCheck
+}
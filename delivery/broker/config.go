@@ -1,11 +1,40 @@
 package broker
 
+import "time"
+
 type (
 	Config struct {
-		ServerURL       string `env:"BROKER_SERVER"`
-		PartitionsCount int    `env:"PARTITIONS_COUNT" envDefault:"1"`
-		MaxMessageBytes int    `env:"MAX_MESSAGE_MAX_BYTES" envDefault:"5242880"` // 5MB
-		BatchProducer   bool   `env:"BATCH_PRODUCER"`
-		Enabled         bool   `env:"BROKER_ENABLED"`
+		ServerURL string `env:"BROKER_SERVER"`
+		// TransactionalID enables publishing of all messages of a height in one kafka transaction
+		TransactionalID string `env:"BROKER_TRANSACTIONAL_ID"`
+		// Partitioner is the librdkafka partitioner of the keyed records
+		Partitioner  string `env:"BROKER_PARTITIONER" envDefault:"murmur2_random"`
+		PartitionKey string `env:"BROKER_PARTITION_KEY" envDefault:"message"`
+		// DeadLetterTopic is the topic of the failure records
+		DeadLetterTopic string `env:"DEAD_LETTER_TOPIC" envDefault:"dead_letter"`
+		// Encoding of the raw topics: json, avro or protobuf
+		Encoding               string `env:"BROKER_ENCODING" envDefault:"json"`
+		SchemaRegistryURL      string `env:"SCHEMA_REGISTRY_URL"`
+		SchemaRegistryUser     string `env:"SCHEMA_REGISTRY_USER"`
+		SchemaRegistryPassword string `env:"SCHEMA_REGISTRY_PASSWORD"`
+		// Compression is the producer compression codec: none, gzip, snappy, lz4 or zstd
+		Compression string `env:"BROKER_COMPRESSION" envDefault:"none"`
+		// LargeMessageMode is the handling of oversize raw_block_results: none, chunks or blob
+		LargeMessageMode   string        `env:"LARGE_MESSAGE_MODE" envDefault:"none"`
+		BlobDir            string        `env:"LARGE_MESSAGE_BLOB_DIR" envDefault:"blobs"`
+		TransactionTimeout time.Duration `env:"BROKER_TRANSACTION_TIMEOUT" envDefault:"1m"`
+		// CommitBackoff is the initial delay of the commit retries, doubled up to CommitMaxBackoff
+		CommitBackoff    time.Duration `env:"BROKER_COMMIT_BACKOFF" envDefault:"100ms"`
+		CommitMaxBackoff time.Duration `env:"BROKER_COMMIT_MAX_BACKOFF" envDefault:"5s"`
+		PartitionsCount  int           `env:"PARTITIONS_COUNT" envDefault:"1"`
+		MaxMessageBytes  int           `env:"MAX_MESSAGE_MAX_BYTES" envDefault:"5242880"` // 5MB
+		CompressionLevel int           `env:"BROKER_COMPRESSION_LEVEL" envDefault:"-1"`   // codec default
+		// LargeMessageThreshold is the max size of the message value, MAX_MESSAGE_MAX_BYTES without overhead if 0
+		LargeMessageThreshold  int `env:"LARGE_MESSAGE_THRESHOLD"`
+		TransactionalProducers int `env:"BROKER_TRANSACTIONAL_PRODUCERS" envDefault:"1"`
+		// CommitRetries is the max count of the commit retries on a retriable error before the transaction is aborted
+		CommitRetries int  `env:"BROKER_COMMIT_RETRIES" envDefault:"5"`
+		BatchProducer bool `env:"BATCH_PRODUCER"`
+		Enabled       bool `env:"BROKER_ENABLED"`
 	}
 )
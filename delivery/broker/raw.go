@@ -4,18 +4,18 @@ import (
 	"context"
 )
 
-func (b *Broker) PublishRawBlock(_ context.Context, block interface{}) error {
-	return b.marshalAndProduce(RawBlock, block)
+func (b *Broker) PublishRawBlock(ctx context.Context, block interface{}) error {
+	return b.marshalAndProduce(ctx, RawBlock, block)
 }
 
-func (b *Broker) PublishRawTransaction(_ context.Context, tx interface{}) error {
-	return b.marshalAndProduce(RawTransaction, tx)
+func (b *Broker) PublishRawTransaction(ctx context.Context, tx interface{}) error {
+	return b.marshalAndProduce(ctx, RawTransaction, tx)
 }
 
-func (b *Broker) PublishRawBlockResults(_ context.Context, br interface{}) error {
-	return b.marshalAndProduce(RawBlockResults, br)
+func (b *Broker) PublishRawBlockResults(ctx context.Context, br interface{}) error {
+	return b.marshalAndProduce(ctx, RawBlockResults, br)
 }
 
-func (b *Broker) PublishRawGenesis(_ context.Context, g interface{}) error {
-	return b.marshalAndProduce(RawGenesis, g)
+func (b *Broker) PublishRawGenesis(ctx context.Context, g interface{}) error {
+	return b.marshalAndProduce(ctx, RawGenesis, g)
 }
@@ -0,0 +1,132 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/types"
+)
+
+const (
+	// SchemaVersion is the version of the message payloads. Increase it on incompatible changes.
+	SchemaVersion = "1"
+
+	PartitionKeyMessage = "message" // key by the message type: height, tx hash or chain id
+	PartitionKeyHeight  = "height"  // key all messages by the height, so the height is kept in one partition
+	PartitionKeyNone    = "none"    // no key, messages are spread over partitions by the partitioner
+
+	HeaderHeight         = "height"
+	HeaderChainID        = "chain_id"
+	HeaderCrawlerVersion = "crawler_version"
+	HeaderSchemaVersion  = "schema_version"
+)
+
+type (
+	// Record is the marshaled message with its key and headers, independent of the sink.
+	Record struct {
+		Topic   string
+		Key     []byte
+		Value   []byte
+		Headers []Header
+	}
+
+	Header struct {
+		Key   string
+		Value string
+	}
+
+	// keyer is implemented by messages with their own record key, e.g. transactions keyed by the hash.
+	keyer interface {
+		RecordKey() string
+	}
+)
+
+// WithCrawlerVersion sets the crawler version header of the produced records.
+func WithCrawlerVersion(version string) opt {
+	return func(b *Broker) {
+		b.version = version
+	}
+}
+
+// AllTopics returns names of all topics.
+func AllTopics() []string {
+	return append([]string(nil), allTopics...)
+}
+
+// NewRecord marshals the message to JSON and creates the record with the key and headers
+// from the origin of the context.
+func NewRecord(ctx context.Context, topic Topic, msg interface{}, partitionKey, version string) (Record, error) {
+	data, err := jsoniter.Marshal(msg)
+	if err != nil {
+		return Record{}, errors.Wrap(err, MsgErrJSONMarshalFail)
+	}
+
+	return newRecord(ctx, topic, data, msg, partitionKey, version), nil
+}
+
+// newRecord creates the record of the encoded message.
+func newRecord(ctx context.Context, topic Topic, data []byte, msg interface{}, partitionKey, version string) Record {
+	origin, hasOrigin := types.OriginFromContext(ctx)
+
+	res := Record{
+		Topic: *topic,
+		Value: data,
+		Headers: []Header{
+			{Key: HeaderCrawlerVersion, Value: version},
+			{Key: HeaderSchemaVersion, Value: SchemaVersion},
+		},
+	}
+
+	var height []byte
+	if hasOrigin {
+		height = []byte(strconv.FormatInt(origin.Height, 10))
+		res.Headers = append(res.Headers,
+			Header{Key: HeaderHeight, Value: string(height)},
+			Header{Key: HeaderChainID, Value: origin.ChainID},
+		)
+	}
+
+	switch partitionKey {
+	case PartitionKeyNone:
+	case PartitionKeyHeight:
+		res.Key = height
+	default:
+		if k, ok := msg.(keyer); ok {
+			res.Key = []byte(k.RecordKey())
+		} else {
+			res.Key = height
+		}
+	}
+
+	return res
+}
+
+func validatePartitionKey(key string) error {
+	switch key {
+	case PartitionKeyMessage, PartitionKeyHeight, PartitionKeyNone:
+		return nil
+	default:
+		return fmt.Errorf("unknown partition key strategy %q", key)
+	}
+}
+
+// newMessage converts the record to the kafka message.
+func newMessage(rec Record) *kafka.Message {
+	res := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &rec.Topic, Partition: kafka.PartitionAny},
+		Key:            rec.Key,
+		Value:          rec.Value,
+		Headers:        make([]kafka.Header, len(rec.Headers)),
+	}
+
+	for i, h := range rec.Headers {
+		res.Headers[i] = kafka.Header{Key: h.Key, Value: []byte(h.Value)}
+	}
+
+	return res
+}
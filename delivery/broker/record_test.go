@@ -0,0 +1,57 @@
+package broker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/types"
+)
+
+type keyedMessage struct{ key string }
+
+func (m keyedMessage) RecordKey() string { return m.key }
+
+func TestNewRecord(t *testing.T) {
+	ctx := types.WithOrigin(context.Background(), types.Origin{ChainID: "test-1", Height: 5})
+
+	tests := []struct {
+		name         string
+		ctx          context.Context
+		msg          interface{}
+		partitionKey string
+		wantKey      string
+	}{
+		{"message key", ctx, keyedMessage{key: "hash"}, PartitionKeyMessage, "hash"},
+		{"height key by default", ctx, struct{}{}, PartitionKeyMessage, "5"},
+		{"height key", ctx, keyedMessage{key: "hash"}, PartitionKeyHeight, "5"},
+		{"no key", ctx, keyedMessage{key: "hash"}, PartitionKeyNone, ""},
+		{"no origin", context.Background(), struct{}{}, PartitionKeyMessage, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, err := NewRecord(tt.ctx, RawBlock, tt.msg, tt.partitionKey, "v1.0.0")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := string(rec.Key); got != tt.wantKey {
+				t.Errorf("want key %q, got %q", tt.wantKey, got)
+			}
+
+			headers := make(map[string]string)
+			for _, h := range newMessage(rec).Headers {
+				headers[h.Key] = string(h.Value)
+			}
+
+			if headers[HeaderCrawlerVersion] != "v1.0.0" || headers[HeaderSchemaVersion] != SchemaVersion {
+				t.Errorf("unexpected version headers: %v", headers)
+			}
+
+			if _, ok := types.OriginFromContext(tt.ctx); ok &&
+				(headers[HeaderHeight] != "5" || headers[HeaderChainID] != "test-1") {
+				t.Errorf("unexpected origin headers: %v", headers)
+			}
+		})
+	}
+}
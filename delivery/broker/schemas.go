@@ -0,0 +1,48 @@
+package broker
+
+import "github.com/bro-n-bro/spacebox-crawler/v2/delivery/broker/serde"
+
+// rawSchemas are the schemas of the raw topics for the Avro and Protobuf encodings.
+// Nested objects, e.g. the block itself, are JSON strings.
+var rawSchemas = map[string]serde.Schema{
+	*RawBlock: {
+		Name: "RawBlock",
+		Fields: []serde.Field{
+			{Name: "hash", Type: serde.TypeString},
+			{Name: "proposer_address", Type: serde.TypeString},
+			{Name: "block", Type: serde.TypeJSON},
+			{Name: "total_gas", Type: serde.TypeLong},
+			{Name: "num_txs", Type: serde.TypeLong},
+		},
+	},
+	*RawTransaction: {
+		Name: "RawTransaction",
+		Fields: []serde.Field{
+			{Name: "signer", Type: serde.TypeString},
+			{Name: "tx_response", Type: serde.TypeJSON},
+		},
+	},
+	*RawBlockResults: {
+		Name: "RawBlockResults",
+		Fields: []serde.Field{
+			{Name: "height", Type: serde.TypeLong},
+			{Name: "txs_results", Type: serde.TypeJSON},
+			{Name: "begin_block_events", Type: serde.TypeJSON},
+			{Name: "end_block_events", Type: serde.TypeJSON},
+			{Name: "validator_updates", Type: serde.TypeJSON},
+			{Name: "consensus_param_updates", Type: serde.TypeJSON},
+			{Name: "timestamp", Type: serde.TypeString},
+		},
+	},
+	*RawGenesis: {
+		Name: "RawGenesis",
+		Fields: []serde.Field{
+			{Name: "genesis_time", Type: serde.TypeString},
+			{Name: "chain_id", Type: serde.TypeString},
+			{Name: "app_hash", Type: serde.TypeString},
+			{Name: "consensus_params", Type: serde.TypeJSON},
+			{Name: "app_state", Type: serde.TypeJSON},
+			{Name: "initial_height", Type: serde.TypeLong},
+		},
+	},
+}
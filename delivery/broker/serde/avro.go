@@ -0,0 +1,55 @@
+package serde
+
+import (
+	"encoding/binary"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const avroNamespace = "spacebox"
+
+// avro encodes records in the Avro binary encoding. JSON fields are Avro strings.
+type avro struct{}
+
+func (avro) schemaType() string { return "AVRO" }
+
+func (avro) schemaText(s Schema) string {
+	type field struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	}
+
+	fields := make([]field, len(s.Fields))
+	for i, f := range s.Fields {
+		fields[i] = field{Name: f.Name, Type: "string"}
+		if f.Type == TypeLong {
+			fields[i].Type = "long"
+		}
+	}
+
+	text, _ := jsoniter.MarshalToString(struct {
+		Type      string  `json:"type"`
+		Name      string  `json:"name"`
+		Namespace string  `json:"namespace"`
+		Fields    []field `json:"fields"`
+	}{
+		Type:      "record",
+		Name:      s.Name,
+		Namespace: avroNamespace,
+		Fields:    fields,
+	})
+
+	return text
+}
+
+func (avro) prefix() []byte { return nil }
+
+func (avro) appendString(dst []byte, _ int, v string) []byte {
+	dst = binary.AppendVarint(dst, int64(len(v)))
+	return append(dst, v...)
+}
+
+// appendLong appends the zig-zag encoded value.
+func (avro) appendLong(dst []byte, _ int, v int64) []byte {
+	return binary.AppendVarint(dst, v)
+}
@@ -0,0 +1,64 @@
+package serde
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+const (
+	protoPackage = "spacebox"
+
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+// protobuf encodes records as proto3 messages with field numbers in the schema order.
+// JSON fields are proto strings.
+type protobuf struct{}
+
+func (protobuf) schemaType() string { return "PROTOBUF" }
+
+func (protobuf) schemaText(s Schema) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "syntax = \"proto3\";\npackage %s;\n\nmessage %s {\n", protoPackage, s.Name)
+
+	for i, f := range s.Fields {
+		typ := "string"
+		if f.Type == TypeLong {
+			typ = "int64"
+		}
+
+		fmt.Fprintf(&b, "  %s %s = %d;\n", typ, f.Name, i+1)
+	}
+
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+// prefix is the message indexes of the first message in the schema.
+func (protobuf) prefix() []byte { return []byte{0} }
+
+// appendString appends the length-delimited field. Proto3 doesn't encode default values.
+func (protobuf) appendString(dst []byte, index int, v string) []byte {
+	if v == "" {
+		return dst
+	}
+
+	dst = binary.AppendUvarint(dst, uint64(index+1)<<3|wireBytes)
+	dst = binary.AppendUvarint(dst, uint64(len(v)))
+
+	return append(dst, v...)
+}
+
+func (protobuf) appendLong(dst []byte, index int, v int64) []byte {
+	if v == 0 {
+		return dst
+	}
+
+	dst = binary.AppendUvarint(dst, uint64(index+1)<<3|wireVarint)
+
+	return binary.AppendUvarint(dst, uint64(v))
+}
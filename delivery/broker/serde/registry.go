@@ -0,0 +1,82 @@
+package serde
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const contentType = "application/vnd.schemaregistry.v1+json"
+
+// Registry is the client of the Confluent compatible schema registry.
+type Registry struct {
+	cli      *http.Client
+	url      string
+	user     string
+	password string
+}
+
+func NewRegistry(url, user, password string) *Registry {
+	return &Registry{
+		cli:      &http.Client{Timeout: 10 * time.Second},
+		url:      strings.TrimSuffix(url, "/"),
+		user:     user,
+		password: password,
+	}
+}
+
+// Register registers the schema under the subject and returns its id.
+// The registry returns the id of the existing schema if it is already registered.
+func (r *Registry) Register(ctx context.Context, subject, schemaType, schema string) (int, error) {
+	body, err := jsoniter.Marshal(struct {
+		Schema     string `json:"schema"`
+		SchemaType string `json:"schemaType"`
+	}{
+		Schema:     schema,
+		SchemaType: schemaType,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url+"/subjects/"+subject+"/versions",
+		bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	if r.user != "" {
+		req.SetBasicAuth(r.user, r.password)
+	}
+
+	resp, err := r.cli.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("schema registry status %d: %s", resp.StatusCode, data)
+	}
+
+	var res struct {
+		ID int `json:"id"`
+	}
+	if err = jsoniter.Unmarshal(data, &res); err != nil {
+		return 0, err
+	}
+
+	return res.ID, nil
+}
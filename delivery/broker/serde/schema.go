@@ -0,0 +1,92 @@
+package serde
+
+import (
+	"fmt"
+	"strconv"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	TypeString FieldType = iota
+	TypeLong
+	// TypeJSON is a nested object encoded as a JSON string
+	TypeJSON
+)
+
+type (
+	FieldType int
+
+	// Schema is the flat record schema of the message, which is converted to the Avro or Protobuf schema.
+	Schema struct {
+		Name   string
+		Fields []Field
+	}
+
+	// Field is the field of the message with the JSON name.
+	Field struct {
+		Name string
+		Type FieldType
+	}
+)
+
+// values returns the field values of the message in the schema order: string for string and JSON fields,
+// int64 for long fields. Missing fields have zero values.
+func (s Schema) values(msg interface{}) ([]interface{}, error) {
+	data, err := jsoniter.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]jsoniter.RawMessage
+	if err = jsoniter.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("message of %s is not an object: %w", s.Name, err)
+	}
+
+	res := make([]interface{}, len(s.Fields))
+
+	for i, f := range s.Fields {
+		v := raw[f.Name]
+		null := len(v) == 0 || string(v) == "null"
+
+		switch f.Type {
+		case TypeLong:
+			var n int64
+			if !null {
+				if n, err = parseLong(v); err != nil {
+					return nil, fmt.Errorf("field %s.%s: %w", s.Name, f.Name, err)
+				}
+			}
+			res[i] = n
+		case TypeJSON:
+			if null {
+				res[i] = ""
+			} else {
+				res[i] = string(v)
+			}
+		default:
+			var str string
+			if !null {
+				if err = jsoniter.Unmarshal(v, &str); err != nil {
+					return nil, fmt.Errorf("field %s.%s: %w", s.Name, f.Name, err)
+				}
+			}
+			res[i] = str
+		}
+	}
+
+	return res, nil
+}
+
+// parseLong parses the JSON number or the number in a string, e.g. int64 values of protobuf JSON.
+func parseLong(v []byte) (int64, error) {
+	var str string
+	if err := jsoniter.Unmarshal(v, &str); err == nil {
+		return strconv.ParseInt(str, 10, 64)
+	}
+
+	var n int64
+	err := jsoniter.Unmarshal(v, &n)
+
+	return n, err
+}
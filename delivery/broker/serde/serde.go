@@ -0,0 +1,126 @@
+// Package serde serializes broker messages to JSON, or to Avro and Protobuf in the Confluent wire format
+// with schemas registered in a schema registry.
+package serde
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	FormatJSON     = "json"
+	FormatAvro     = "avro"
+	FormatProtobuf = "protobuf"
+
+	// magicByte starts every message in the Confluent wire format
+	magicByte byte = 0
+)
+
+type (
+	// Encoder serializes the message of the topic.
+	Encoder interface {
+		// Register registers the schemas of the topics. It must be called before Encode.
+		Register(ctx context.Context) error
+		Encode(topic string, msg interface{}) ([]byte, error)
+	}
+
+	// JSON encodes all messages to JSON without a schema.
+	JSON struct{}
+
+	// schemaEncoder encodes messages of the topics with a schema, other messages are encoded to JSON.
+	schemaEncoder struct {
+		registry *Registry
+		schemas  map[string]Schema
+		ids      map[string]int
+		format   format
+	}
+
+	// format is the schema format: Avro or Protobuf.
+	format interface {
+		schemaType() string
+		schemaText(s Schema) string
+		// prefix is written after the schema id
+		prefix() []byte
+		appendString(dst []byte, index int, v string) []byte
+		appendLong(dst []byte, index int, v int64) []byte
+	}
+)
+
+// New creates the encoder of the format. Avro and Protobuf encoders register the schemas in the registry.
+func New(formatName string, registry *Registry, schemas map[string]Schema) (Encoder, error) {
+	var f format
+
+	switch formatName {
+	case FormatJSON, "":
+		return JSON{}, nil
+	case FormatAvro:
+		f = avro{}
+	case FormatProtobuf:
+		f = protobuf{}
+	default:
+		return nil, fmt.Errorf("unknown encoding format %q", formatName)
+	}
+
+	return &schemaEncoder{
+		registry: registry,
+		schemas:  schemas,
+		ids:      make(map[string]int, len(schemas)),
+		format:   f,
+	}, nil
+}
+
+func (JSON) Register(_ context.Context) error { return nil }
+
+func (JSON) Encode(_ string, msg interface{}) ([]byte, error) {
+	return jsoniter.Marshal(msg)
+}
+
+// Register registers the schemas with the topic name strategy: the subject is <topic>-value.
+func (e *schemaEncoder) Register(ctx context.Context) error {
+	for topic, s := range e.schemas {
+		id, err := e.registry.Register(ctx, topic+"-value", e.format.schemaType(), e.format.schemaText(s))
+		if err != nil {
+			return fmt.Errorf("can't register schema of %s: %w", topic, err)
+		}
+
+		e.ids[topic] = id
+	}
+
+	return nil
+}
+
+func (e *schemaEncoder) Encode(topic string, msg interface{}) ([]byte, error) {
+	s, ok := e.schemas[topic]
+	if !ok {
+		return jsoniter.Marshal(msg)
+	}
+
+	id, ok := e.ids[topic]
+	if !ok {
+		return nil, fmt.Errorf("schema of %s is not registered", topic)
+	}
+
+	values, err := s.values(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]byte, 5, 1024)
+	res[0] = magicByte
+	binary.BigEndian.PutUint32(res[1:5], uint32(id))
+	res = append(res, e.format.prefix()...)
+
+	for i, f := range s.Fields {
+		switch f.Type {
+		case TypeLong:
+			res = e.format.appendLong(res, i, values[i].(int64)) //nolint:forcetypeassert
+		default:
+			res = e.format.appendString(res, i, values[i].(string)) //nolint:forcetypeassert
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,182 @@
+package serde
+
+import (
+	"context"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+var testSchema = Schema{
+	Name: "RawBlock",
+	Fields: []Field{
+		{Name: "hash", Type: TypeString},
+		{Name: "block", Type: TypeJSON},
+		{Name: "total_gas", Type: TypeLong},
+		{Name: "num_txs", Type: TypeLong},
+	},
+}
+
+// registryStub is a local stand-in of the schema registry. It assigns ids in the registration order.
+type registryStub struct {
+	mu       sync.Mutex
+	subjects map[string]int
+	types    map[string]string
+}
+
+func newRegistryStub(t *testing.T) (*registryStub, *Registry) {
+	t.Helper()
+
+	stub := &registryStub{subjects: make(map[string]int), types: make(map[string]string)}
+	srv := httptest.NewServer(stub)
+	t.Cleanup(srv.Close)
+
+	return stub, NewRegistry(srv.URL, "", "")
+}
+
+func (s *registryStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	subject := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/subjects/"), "/versions")
+	if r.Method != http.MethodPost || subject == r.URL.Path {
+		http.NotFound(w, r)
+		return
+	}
+
+	var req struct {
+		Schema     string `json:"schema"`
+		SchemaType string `json:"schemaType"`
+	}
+	if err := jsoniter.NewDecoder(r.Body).Decode(&req); err != nil || req.Schema == "" {
+		http.Error(w, "invalid schema", http.StatusUnprocessableEntity)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, ok := s.subjects[subject]
+	if !ok {
+		id = len(s.subjects) + 1
+		s.subjects[subject] = id
+		s.types[subject] = req.SchemaType
+	}
+
+	_, _ = w.Write([]byte(`{"id":` + strconv.Itoa(id) + `}`))
+}
+
+func TestEncode(t *testing.T) {
+	msg := map[string]interface{}{
+		"hash":      "ABC",
+		"block":     map[string]int{"height": 5},
+		"total_gas": 300,
+		"num_txs":   "2", // int64 in protobuf JSON
+	}
+
+	tests := []struct {
+		format  string
+		want    []byte
+		wantTyp string
+	}{
+		{
+			format:  FormatAvro,
+			wantTyp: "AVRO",
+			want: concat(
+				[]byte{0, 0, 0, 0, 1},
+				[]byte{6}, []byte("ABC"), // zig-zag length 3
+				[]byte{24}, []byte(`{"height":5}`), // zig-zag length 12
+				binary.AppendVarint(nil, 300),
+				[]byte{4}, // zig-zag 2
+			),
+		},
+		{
+			format:  FormatProtobuf,
+			wantTyp: "PROTOBUF",
+			want: concat(
+				[]byte{0, 0, 0, 0, 1},
+				[]byte{0},                          // message indexes
+				[]byte{1<<3 | 2, 3}, []byte("ABC"), // field 1
+				[]byte{2<<3 | 2, 12}, []byte(`{"height":5}`), // field 2
+				[]byte{3 << 3}, binary.AppendUvarint(nil, 300), // field 3
+				[]byte{4 << 3, 2}, // field 4
+			),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			stub, registry := newRegistryStub(t)
+
+			enc, err := New(tt.format, registry, map[string]Schema{"raw_block": testSchema})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err = enc.Encode("raw_block", msg); err == nil {
+				t.Fatal("want error of the unregistered schema")
+			}
+
+			if err = enc.Register(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := stub.types["raw_block-value"]; got != tt.wantTyp {
+				t.Errorf("want %s schema type, got %q", tt.wantTyp, got)
+			}
+
+			got, err := enc.Encode("raw_block", msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != string(tt.want) {
+				t.Errorf("want %v, got %v", tt.want, got)
+			}
+
+			// topics without schema are encoded to JSON
+			if got, err = enc.Encode("reorg", map[string]int{"height": 1}); err != nil || string(got) != `{"height":1}` {
+				t.Errorf("want JSON, got %s: %v", got, err)
+			}
+		})
+	}
+}
+
+func TestSchemaText(t *testing.T) {
+	var avroSchema struct {
+		Type   string `json:"type"`
+		Name   string `json:"name"`
+		Fields []struct {
+			Name string `json:"name"`
+			Type string `json:"type"`
+		} `json:"fields"`
+	}
+
+	if err := jsoniter.UnmarshalFromString(avro{}.schemaText(testSchema), &avroSchema); err != nil {
+		t.Fatal(err)
+	}
+
+	if avroSchema.Type != "record" || avroSchema.Name != "RawBlock" || len(avroSchema.Fields) != 4 ||
+		avroSchema.Fields[2].Type != "long" || avroSchema.Fields[1].Type != "string" {
+		t.Errorf("unexpected avro schema: %+v", avroSchema)
+	}
+
+	proto := protobuf{}.schemaText(testSchema)
+	for _, want := range []string{"message RawBlock {", "string hash = 1;", "int64 total_gas = 3;"} {
+		if !strings.Contains(proto, want) {
+			t.Errorf("protobuf schema has no %q:\n%s", want, proto)
+		}
+	}
+}
+
+func concat(parts ...[]byte) []byte {
+	var res []byte
+	for _, p := range parts {
+		res = append(res, p...)
+	}
+
+	return res
+}
@@ -0,0 +1,19 @@
+package broker
+
+import (
+	"context"
+)
+
+func (b *Broker) PublishReorg(ctx context.Context, r interface{}) error {
+	return b.marshalAndProduce(ctx, Reorg, r)
+}
+
+func (b *Broker) PublishFinalizedHeight(ctx context.Context, fh interface{}) error {
+	return b.marshalAndProduce(ctx, FinalizedHeight, fh)
+}
+
+// PublishDeadLetter publishes the failure record to the dead letter topic.
+// Dead letters are never a part of the transaction, so they are not lost when the transaction is aborted.
+func (b *Broker) PublishDeadLetter(ctx context.Context, dl interface{}) error {
+	return b.marshalAndProduce(withoutTransaction(ctx), b.deadLetter, dl)
+}
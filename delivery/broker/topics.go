@@ -5,8 +5,18 @@ var (
 	RawBlockResults Topic = newTopic("raw_block_results")
 	RawGenesis      Topic = newTopic("raw_genesis")
 	RawTransaction  Topic = newTopic("raw_transaction")
+	Reorg           Topic = newTopic("reorg")
+	FinalizedHeight Topic = newTopic("finalized_height")
+	DeadLetter      Topic = newTopic("dead_letter")
 
-	rawTopics = Topics{RawBlock, RawTransaction, RawBlockResults, RawGenesis}
+	RawBlockResultsChunks Topic = newTopic("raw_block_results_chunks")
+
+	rawTopics          = Topics{RawBlock, RawTransaction, RawBlockResults, RawGenesis}
+	serviceTopics      = Topics{Reorg, FinalizedHeight, DeadLetter}
+	largeMessageTopics = Topics{RawBlockResultsChunks}
+
+	// chunkTopics are the topics of the oversize message chunks by the message topic
+	chunkTopics = map[string]Topic{*RawBlockResults: RawBlockResultsChunks}
 
 	// allTopics is the list of all topics.
 	allTopics = func(tcs []Topics) []string {
@@ -15,7 +25,7 @@ var (
 			stringTopics = append(stringTopics, t.ToStringSlice()...)
 		}
 		return removeDuplicates(stringTopics)
-	}([]Topics{rawTopics})
+	}([]Topics{rawTopics, serviceTopics, largeMessageTopics})
 )
 
 type (
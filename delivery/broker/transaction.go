@@ -0,0 +1,223 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/pkg/errors"
+)
+
+const (
+	MsgErrInitTransactions   = "can't init kafka transactions: %w"
+	MsgErrBeginTransaction   = "can't begin kafka transaction: %w"
+	MsgErrCommitTransaction  = "can't commit kafka transaction: %w"
+	MsgErrAbortTransaction   = "can't abort kafka transaction: %w"
+	MsgErrNoTransactionSlots = "no free transactional producer: %w"
+)
+
+type (
+	// txProducer is a transactional producer with the unique transactional id.
+	txProducer struct {
+		p  *kafka.Producer
+		id string
+	}
+
+	transactionKey struct{}
+)
+
+// Transactional reports whether messages of a height are published in one kafka transaction.
+func (b *Broker) Transactional() bool {
+	return b.cfg.Enabled && b.cfg.TransactionalID != ""
+}
+
+// BeginTransaction starts the transaction for the messages published with the returned context.
+// It returns the context as is if transactions are disabled.
+func (b *Broker) BeginTransaction(ctx context.Context) (context.Context, error) {
+	if !b.cfg.Enabled || b.txProducers == nil {
+		return ctx, nil
+	}
+
+	var tx *txProducer
+	select {
+	case <-ctx.Done():
+		return ctx, errors.Wrap(ctx.Err(), MsgErrNoTransactionSlots)
+	case tx = <-b.txProducers:
+	}
+
+	if err := tx.p.BeginTransaction(); err != nil {
+		b.releaseTransactionalProducer(tx, err)
+		return ctx, errors.Wrap(err, MsgErrBeginTransaction)
+	}
+
+	return context.WithValue(ctx, transactionKey{}, tx), nil
+}
+
+// CommitTransaction commits the transaction of the context and returns the producer to the pool.
+func (b *Broker) CommitTransaction(ctx context.Context) error {
+	tx, ok := transactionFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	err := b.commitWithRetry(ctx, tx)
+	if err == nil {
+		b.releaseTransactionalProducer(tx, nil)
+		return nil
+	}
+
+	var kafkaErr kafka.Error
+	if errors.As(err, &kafkaErr) && (kafkaErr.TxnRequiresAbort() || kafkaErr.IsRetriable()) {
+		if abortErr := tx.p.AbortTransaction(context.WithoutCancel(ctx)); abortErr != nil {
+			err = abortErr
+		}
+	}
+
+	b.releaseTransactionalProducer(tx, err)
+
+	return errors.Wrap(err, MsgErrCommitTransaction)
+}
+
+// commitWithRetry commits the transaction and retries the retriable errors with backoff.
+// The last error is returned once the retries are exhausted or ctx is done.
+func (b *Broker) commitWithRetry(ctx context.Context, tx *txProducer) error {
+	delay := b.cfg.CommitBackoff
+
+	for attempt := 0; ; attempt++ {
+		err := tx.p.CommitTransaction(ctx)
+		if err == nil {
+			return nil
+		}
+
+		var kafkaErr kafka.Error
+		if !errors.As(err, &kafkaErr) || !kafkaErr.IsRetriable() || attempt >= b.cfg.CommitRetries {
+			return err
+		}
+
+		b.log.Warn().Err(err).Str("transactional_id", tx.id).Int("attempt", attempt+1).Dur("backoff", delay).
+			Msg("retry commit transaction")
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+
+		if delay *= 2; delay > b.cfg.CommitMaxBackoff {
+			delay = b.cfg.CommitMaxBackoff
+		}
+	}
+}
+
+// AbortTransaction aborts the transaction of the context and returns the producer to the pool.
+func (b *Broker) AbortTransaction(ctx context.Context) error {
+	tx, ok := transactionFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	err := tx.p.AbortTransaction(ctx)
+	b.releaseTransactionalProducer(tx, err)
+
+	if err != nil {
+		return errors.Wrap(err, MsgErrAbortTransaction)
+	}
+
+	return nil
+}
+
+func transactionFromContext(ctx context.Context) (*txProducer, bool) {
+	tx, ok := ctx.Value(transactionKey{}).(*txProducer)
+	return tx, ok
+}
+
+// withoutTransaction returns the context for publishing messages outside the transaction of the given one.
+func withoutTransaction(ctx context.Context) context.Context {
+	return context.WithValue(ctx, transactionKey{}, nil)
+}
+
+// startTransactionalProducers creates the pool of the transactional producers.
+func (b *Broker) startTransactionalProducers(ctx context.Context) error {
+	count := b.cfg.TransactionalProducers
+	if count <= 0 {
+		count = 1
+	}
+
+	b.txProducers = make(chan *txProducer, count)
+
+	for i := 0; i < count; i++ {
+		tx, err := b.newTransactionalProducer(ctx, fmt.Sprintf("%s-%d", b.cfg.TransactionalID, i))
+		if err != nil {
+			return err
+		}
+
+		b.txProducers <- tx
+	}
+
+	return nil
+}
+
+func (b *Broker) stopTransactionalProducers() {
+	if b.txProducers == nil {
+		return
+	}
+
+	b.txStopped.Store(true)
+
+	for {
+		select {
+		case tx := <-b.txProducers:
+			tx.p.Close()
+		default:
+			return
+		}
+	}
+}
+
+func (b *Broker) newTransactionalProducer(ctx context.Context, id string) (*txProducer, error) {
+	cfg := b.producerConfig()
+	cfg["transactional.id"] = id
+	cfg["transaction.timeout.ms"] = int(b.cfg.TransactionTimeout.Milliseconds())
+
+	p, err := kafka.NewProducer(&cfg)
+	if err != nil {
+		b.log.Error().Err(err).Msg(MsgErrCreateProducer)
+		return nil, errors.Wrap(err, MsgErrCreateProducer)
+	}
+
+	go b.logDeliveryErrors(p.Events())
+
+	// fences the previous producer with the same transactional id and aborts its open transactions
+	if err = p.InitTransactions(ctx); err != nil {
+		p.Close()
+		b.log.Error().Err(err).Str("transactional_id", id).Msg(MsgErrInitTransactions)
+		return nil, errors.Wrap(err, MsgErrInitTransactions)
+	}
+
+	return &txProducer{p: p, id: id}, nil
+}
+
+// releaseTransactionalProducer returns the producer to the pool. The producer is recreated after a fatal error.
+func (b *Broker) releaseTransactionalProducer(tx *txProducer, err error) {
+	var kafkaErr kafka.Error
+	if err == nil || !errors.As(err, &kafkaErr) || !kafkaErr.IsFatal() {
+		b.txProducers <- tx
+		return
+	}
+
+	b.log.Error().Err(err).Str("transactional_id", tx.id).Msg("fatal transactional producer error. recreate producer")
+	tx.p.Close()
+
+	go func() {
+		for !b.txStopped.Load() {
+			newTx, err := b.newTransactionalProducer(context.Background(), tx.id)
+			if err == nil {
+				b.txProducers <- newTx
+				return
+			}
+
+			time.Sleep(time.Second)
+		}
+	}()
+}
@@ -0,0 +1,6 @@
+package file
+
+type Config struct {
+	Dir     string `env:"FILE_SINK_DIR" envDefault:"data"`
+	MaxSize int64  `env:"FILE_SINK_MAX_SIZE" envDefault:"104857600"` // 100MB
+}
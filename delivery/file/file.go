@@ -0,0 +1,176 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/delivery/broker"
+	"github.com/bro-n-bro/spacebox-crawler/v2/internal/rep"
+)
+
+const (
+	fileExt         = ".ndjson"
+	rotatedTimeFmt  = "20060102T150405.000000000"
+	filePermissions = 0o644
+)
+
+var _ rep.Broker = &Sink{}
+
+type (
+	// Sink writes messages as newline-delimited JSON to a file per topic.
+	// The file is rotated when its size exceeds the max size: it is renamed to <topic>-<time>.ndjson.
+	Sink struct {
+		log   *zerolog.Logger
+		files map[string]*topicFile
+		mu    sync.Mutex
+
+		version string
+		cfg     Config
+	}
+
+	topicFile struct {
+		f    *os.File
+		size int64
+	}
+)
+
+func New(cfg Config, l zerolog.Logger, version string) *Sink {
+	l = l.With().Str("cmp", "file_sink").Logger()
+
+	return &Sink{
+		log:     &l,
+		cfg:     cfg,
+		version: version,
+		files:   make(map[string]*topicFile),
+	}
+}
+
+func (s *Sink) Start(_ context.Context) error {
+	return os.MkdirAll(s.cfg.Dir, 0o755)
+}
+
+func (s *Sink) Stop(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for topic, tf := range s.files {
+		errs = append(errs, tf.f.Close())
+		delete(s.files, topic)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *Sink) PublishRawBlock(ctx context.Context, b interface{}) error {
+	return s.publish(ctx, broker.RawBlock, b)
+}
+
+func (s *Sink) PublishRawTransaction(ctx context.Context, tx interface{}) error {
+	return s.publish(ctx, broker.RawTransaction, tx)
+}
+
+func (s *Sink) PublishRawBlockResults(ctx context.Context, br interface{}) error {
+	return s.publish(ctx, broker.RawBlockResults, br)
+}
+
+func (s *Sink) PublishRawGenesis(ctx context.Context, g interface{}) error {
+	return s.publish(ctx, broker.RawGenesis, g)
+}
+
+func (s *Sink) PublishReorg(ctx context.Context, r interface{}) error {
+	return s.publish(ctx, broker.Reorg, r)
+}
+
+func (s *Sink) PublishFinalizedHeight(ctx context.Context, fh interface{}) error {
+	return s.publish(ctx, broker.FinalizedHeight, fh)
+}
+
+func (s *Sink) PublishDeadLetter(ctx context.Context, dl interface{}) error {
+	return s.publish(ctx, broker.DeadLetter, dl)
+}
+
+// BeginTransaction is not supported, messages are written immediately.
+func (s *Sink) BeginTransaction(ctx context.Context) (context.Context, error) { return ctx, nil }
+
+func (s *Sink) CommitTransaction(_ context.Context) error { return nil }
+
+func (s *Sink) AbortTransaction(_ context.Context) error { return nil }
+
+func (s *Sink) publish(ctx context.Context, topic broker.Topic, msg interface{}) error {
+	rec, err := broker.NewRecord(ctx, topic, msg, broker.PartitionKeyMessage, s.version)
+	if err != nil {
+		return err
+	}
+
+	line := append(rec.Value, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tf, err := s.file(rec.Topic, int64(len(line)))
+	if err != nil {
+		return err
+	}
+
+	n, err := tf.f.Write(line)
+	tf.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", rec.Topic, err)
+	}
+
+	return nil
+}
+
+// file returns the file of the topic, rotating it if the line doesn't fit into the max size.
+func (s *Sink) file(topic string, lineSize int64) (*topicFile, error) {
+	tf, ok := s.files[topic]
+	if ok && (s.cfg.MaxSize <= 0 || tf.size == 0 || tf.size+lineSize <= s.cfg.MaxSize) {
+		return tf, nil
+	}
+
+	path := filepath.Join(s.cfg.Dir, topic+fileExt)
+
+	if ok {
+		if err := tf.f.Close(); err != nil {
+			return nil, fmt.Errorf("failed to close %s: %w", path, err)
+		}
+
+		delete(s.files, topic)
+
+		rotated := filepath.Join(s.cfg.Dir, topic+"-"+time.Now().UTC().Format(rotatedTimeFmt)+fileExt)
+		if err := os.Rename(path, rotated); err != nil {
+			return nil, fmt.Errorf("failed to rotate %s: %w", path, err)
+		}
+
+		s.log.Info().Str("topic", topic).Str("file", rotated).Msg("file rotated")
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, filePermissions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+
+	tf = &topicFile{f: f, size: info.Size()}
+	s.files[topic] = tf
+
+	// the existing file may be already full
+	if tf.size > 0 && s.cfg.MaxSize > 0 && tf.size+lineSize > s.cfg.MaxSize {
+		return s.file(topic, lineSize)
+	}
+
+	return tf, nil
+}
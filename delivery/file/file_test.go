@@ -0,0 +1,81 @@
+package file
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/delivery/broker"
+)
+
+func TestSinkRotation(t *testing.T) {
+	var (
+		ctx = context.Background()
+		dir = t.TempDir()
+		// each line is {"height":N}\n, 13 bytes for a single digit height
+		s = New(Config{Dir: dir, MaxSize: 30}, zerolog.Nop(), "test")
+	)
+
+	if err := s.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for height := 1; height <= 5; height++ {
+		if err := s.PublishRawBlock(ctx, map[string]int{"height": height}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.PublishReorg(ctx, map[string]int{"height": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := filepath.Glob(filepath.Join(dir, *broker.RawBlock+"-*"+fileExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2 lines per file: 2 rotated files and the current one
+	if len(rotated) != 2 {
+		t.Fatalf("want 2 rotated files, got %v", rotated)
+	}
+
+	if got := countLines(t, filepath.Join(dir, *broker.RawBlock+fileExt)); got != 1 {
+		t.Errorf("want 1 line in the current file, got %d", got)
+	}
+
+	for _, path := range rotated {
+		if got := countLines(t, path); got != 2 {
+			t.Errorf("want 2 lines in %s, got %d", path, got)
+		}
+	}
+
+	if got := countLines(t, filepath.Join(dir, *broker.Reorg+fileExt)); got != 1 {
+		t.Errorf("want 1 reorg line, got %d", got)
+	}
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var count int
+	for sc := bufio.NewScanner(f); sc.Scan(); {
+		count++
+	}
+
+	return count
+}
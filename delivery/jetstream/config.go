@@ -0,0 +1,11 @@
+package jetstream
+
+import "time"
+
+type Config struct {
+	URL    string `env:"NATS_URL" envDefault:"nats://localhost:4222"`
+	Stream string `env:"NATS_STREAM" envDefault:"SPACEBOX"`
+	// SubjectPrefix is prepended to the topic names to get the subjects
+	SubjectPrefix  string        `env:"NATS_SUBJECT_PREFIX" envDefault:"spacebox."`
+	PublishTimeout time.Duration `env:"NATS_PUBLISH_TIMEOUT" envDefault:"10s"`
+}
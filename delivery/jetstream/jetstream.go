@@ -0,0 +1,145 @@
+package jetstream
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/rs/zerolog"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/delivery/broker"
+	"github.com/bro-n-bro/spacebox-crawler/v2/internal/rep"
+)
+
+var _ rep.Broker = &Publisher{}
+
+// Publisher publishes messages to NATS JetStream subjects named <prefix><topic>.
+// Retries of the same record are deduplicated by the stream with the <topic>:<payload hash> message id.
+type Publisher struct {
+	log *zerolog.Logger
+	nc  *nats.Conn
+	js  jetstream.JetStream
+
+	version string
+	cfg     Config
+}
+
+func New(cfg Config, l zerolog.Logger, version string) *Publisher {
+	l = l.With().Str("cmp", "jetstream").Logger()
+
+	return &Publisher{
+		log:     &l,
+		cfg:     cfg,
+		version: version,
+	}
+}
+
+func (p *Publisher) Start(ctx context.Context) error {
+	nc, err := nats.Connect(p.cfg.URL, nats.Name("spacebox-crawler"), nats.MaxReconnects(-1))
+	if err != nil {
+		return fmt.Errorf("can't connect to nats: %w", err)
+	}
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return fmt.Errorf("can't create jetstream context: %w", err)
+	}
+
+	topics := broker.AllTopics()
+	subjects := make([]string, len(topics))
+	for i, topic := range topics {
+		subjects[i] = p.cfg.SubjectPrefix + topic
+	}
+
+	// create the stream if needed
+	if _, err = js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:     p.cfg.Stream,
+		Subjects: subjects,
+	}); err != nil {
+		nc.Close()
+		return fmt.Errorf("can't create stream %s: %w", p.cfg.Stream, err)
+	}
+
+	p.nc = nc
+	p.js = js
+
+	return nil
+}
+
+func (p *Publisher) Stop(_ context.Context) error {
+	// the connection is not opened if the start is failed
+	if p.nc == nil {
+		return nil
+	}
+
+	return p.nc.Drain()
+}
+
+func (p *Publisher) PublishRawBlock(ctx context.Context, b interface{}) error {
+	return p.publish(ctx, broker.RawBlock, b)
+}
+
+func (p *Publisher) PublishRawTransaction(ctx context.Context, tx interface{}) error {
+	return p.publish(ctx, broker.RawTransaction, tx)
+}
+
+func (p *Publisher) PublishRawBlockResults(ctx context.Context, br interface{}) error {
+	return p.publish(ctx, broker.RawBlockResults, br)
+}
+
+func (p *Publisher) PublishRawGenesis(ctx context.Context, g interface{}) error {
+	return p.publish(ctx, broker.RawGenesis, g)
+}
+
+func (p *Publisher) PublishReorg(ctx context.Context, r interface{}) error {
+	return p.publish(ctx, broker.Reorg, r)
+}
+
+func (p *Publisher) PublishFinalizedHeight(ctx context.Context, fh interface{}) error {
+	return p.publish(ctx, broker.FinalizedHeight, fh)
+}
+
+func (p *Publisher) PublishDeadLetter(ctx context.Context, dl interface{}) error {
+	return p.publish(ctx, broker.DeadLetter, dl)
+}
+
+// BeginTransaction is not supported, messages are published immediately.
+func (p *Publisher) BeginTransaction(ctx context.Context) (context.Context, error) { return ctx, nil }
+
+func (p *Publisher) CommitTransaction(_ context.Context) error { return nil }
+
+func (p *Publisher) AbortTransaction(_ context.Context) error { return nil }
+
+// msgID returns the deduplication id of the record built from its payload.
+// The record key is not unique: e.g. all dead letters of the height or a raw block republished
+// after the rollback have the same key, so only retries of the same payload are deduplicated.
+func msgID(rec broker.Record) string {
+	sum := sha256.Sum256(rec.Value)
+	return rec.Topic + ":" + hex.EncodeToString(sum[:])
+}
+
+func (p *Publisher) publish(ctx context.Context, topic broker.Topic, msg interface{}) error {
+	rec, err := broker.NewRecord(ctx, topic, msg, broker.PartitionKeyMessage, p.version)
+	if err != nil {
+		return err
+	}
+
+	m := nats.NewMsg(p.cfg.SubjectPrefix + rec.Topic)
+	m.Data = rec.Value
+	for _, h := range rec.Headers {
+		m.Header.Set(h.Key, h.Value)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.PublishTimeout)
+	defer cancel()
+
+	if _, err = p.js.PublishMsg(ctx, m, jetstream.WithMsgID(msgID(rec))); err != nil {
+		return fmt.Errorf("can't publish %s: %w", rec.Topic, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package jetstream
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/delivery/broker"
+	"github.com/bro-n-bro/spacebox-crawler/v2/types"
+)
+
+func TestMsgID(t *testing.T) {
+	ctx := context.Background()
+
+	record := func(msg interface{}) broker.Record {
+		t.Helper()
+
+		rec, err := broker.NewRecord(ctx, broker.DeadLetter, msg, broker.PartitionKeyMessage, "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return rec
+	}
+
+	var (
+		tx1   = record(types.DeadLetter{Height: 1, Stage: types.StageTxs, TxHash: "a"})
+		tx2   = record(types.DeadLetter{Height: 1, Stage: types.StageTxs, TxHash: "b"})
+		retry = record(types.DeadLetter{Height: 1, Stage: types.StageTxs, TxHash: "a"})
+	)
+
+	// dead letters of the same height have the same key, but are not duplicates
+	if string(tx1.Key) != string(tx2.Key) {
+		t.Fatalf("want the same key, got %q and %q", tx1.Key, tx2.Key)
+	}
+
+	if msgID(tx1) == msgID(tx2) {
+		t.Error("want different message ids of different dead letters of the height")
+	}
+
+	if msgID(tx1) != msgID(retry) {
+		t.Error("want the same message id of the retried record")
+	}
+}
+
+func TestStopNotStarted(t *testing.T) {
+	if err := New(Config{}, zerolog.Nop(), "test").Stop(context.Background()); err != nil {
+		t.Errorf("want no error on stop without start, got %v", err)
+	}
+}
@@ -0,0 +1,312 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/model"
+	"github.com/bro-n-bro/spacebox-crawler/v2/types"
+)
+
+const defaultPageLimit = 100
+
+var (
+	errInvalidRange       = errors.New("invalid height range")
+	errAdminTokenRequired = errors.New("ADMIN_API_TOKEN is required to enable the admin API")
+)
+
+type (
+	// worker is the block processing controlled by the admin API.
+	worker interface {
+		Pause()
+		Resume()
+		Paused() bool
+		Reprocess(from, to int64)
+	}
+
+	blockView struct {
+		Created      time.Time  `json:"created"`
+		Processed    *time.Time `json:"processed,omitempty"`
+		LeaseExpires *time.Time `json:"lease_expires,omitempty"`
+		LastAttempt  *time.Time `json:"last_attempt,omitempty"`
+		Status       string     `json:"status"`
+		ErrorMessage string     `json:"error_message,omitempty"`
+		Owner        string     `json:"owner,omitempty"`
+		Hash         string     `json:"hash,omitempty"`
+		ParentHash   string     `json:"parent_hash,omitempty"`
+		Height       int64      `json:"height"`
+		Attempts     int        `json:"attempts"`
+	}
+
+	errorTxView struct {
+		Created      time.Time `json:"created"`
+		Hash         string    `json:"hash"`
+		ErrorMessage string    `json:"error_message"`
+	}
+
+	errorMessageView struct {
+		Created      time.Time `json:"created"`
+		ErrorMessage string    `json:"error_message"`
+	}
+)
+
+// registerAdminHandlers registers the JSON API for inspecting and controlling block processing.
+func (s *Server) registerAdminHandlers() {
+	http.HandleFunc("GET /admin/blocks", s.admin(s.handleListBlocks))
+	http.HandleFunc("GET /admin/blocks/{height}", s.admin(s.handleGetBlock))
+	http.HandleFunc("POST /admin/blocks/{height}/reprocess", s.admin(s.handleReprocessBlock))
+	http.HandleFunc("POST /admin/blocks/{height}/skip", s.admin(s.handleSkipBlock))
+	http.HandleFunc("POST /admin/reprocess", s.admin(s.handleReprocessRange))
+	http.HandleFunc("POST /admin/skip", s.admin(s.handleSkipRange))
+	http.HandleFunc("GET /admin/worker", s.admin(s.handleWorkerState))
+	http.HandleFunc("POST /admin/worker/pause", s.admin(s.handlePauseWorker))
+	http.HandleFunc("POST /admin/worker/resume", s.admin(s.handleResumeWorker))
+}
+
+// admin checks the bearer token of the request. The admin API is not served without the token.
+func (s *Server) admin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.cfg.AdminToken == "" || r.Header.Get("Authorization") != "Bearer "+s.cfg.AdminToken {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// handleListBlocks returns the page of blocks with the status in ascending height order.
+func (s *Server) handleListBlocks(w http.ResponseWriter, r *http.Request) {
+	status, ok := model.ParseStatus(r.URL.Query().Get("status"))
+	if !ok {
+		http.Error(w, "unknown status", http.StatusBadRequest)
+		return
+	}
+
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		http.Error(w, "invalid offset", http.StatusBadRequest)
+		return
+	}
+
+	limit, err := queryInt(r, "limit", defaultPageLimit)
+	if err != nil || limit <= 0 {
+		http.Error(w, "invalid limit", http.StatusBadRequest)
+		return
+	}
+
+	blocks, err := s.storage.GetBlocksByStatus(r.Context(), status, offset, limit)
+	if err != nil {
+		s.log.Error().Err(err).Msg("can't get blocks by status")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res := make([]blockView, len(blocks))
+	for i, b := range blocks {
+		res[i] = newBlockView(b)
+	}
+
+	s.writeJSON(w, struct {
+		Blocks []blockView `json:"blocks"`
+		Offset int64       `json:"offset"`
+		Limit  int64       `json:"limit"`
+	}{Blocks: res, Offset: offset, Limit: limit})
+}
+
+// handleGetBlock returns the block of the height with the related error txs and error messages.
+func (s *Server) handleGetBlock(w http.ResponseWriter, r *http.Request) {
+	height, ok := pathHeight(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	block, err := s.storage.GetBlockByHeight(ctx, height)
+	switch {
+	case errors.Is(err, types.ErrBlockNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		s.log.Error().Err(err).Int64("height", height).Msg("can't get block")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	txs, err := s.storage.GetErrorTxs(ctx, height)
+	if err != nil {
+		s.log.Error().Err(err).Int64("height", height).Msg("can't get error txs")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	messages, err := s.storage.GetErrorMessages(ctx, height)
+	if err != nil {
+		s.log.Error().Err(err).Int64("height", height).Msg("can't get error messages")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res := struct {
+		ErrorTxs      []errorTxView      `json:"error_txs"`
+		ErrorMessages []errorMessageView `json:"error_messages"`
+		Block         blockView          `json:"block"`
+	}{
+		Block:         newBlockView(block),
+		ErrorTxs:      make([]errorTxView, len(txs)),
+		ErrorMessages: make([]errorMessageView, len(messages)),
+	}
+
+	for i, tx := range txs {
+		res.ErrorTxs[i] = errorTxView{Created: tx.Created, Hash: tx.Hash, ErrorMessage: tx.ErrorMessage}
+	}
+
+	for i, m := range messages {
+		res.ErrorMessages[i] = errorMessageView{Created: m.Created, ErrorMessage: m.ErrorMessage}
+	}
+
+	s.writeJSON(w, res)
+}
+
+func (s *Server) handleReprocessBlock(w http.ResponseWriter, r *http.Request) {
+	if height, ok := pathHeight(w, r); ok {
+		s.reprocess(r.Context(), w, height, height)
+	}
+}
+
+func (s *Server) handleReprocessRange(w http.ResponseWriter, r *http.Request) {
+	if from, to, ok := s.queryRange(w, r); ok {
+		s.reprocess(r.Context(), w, from, to)
+	}
+}
+
+// reprocess deletes the stored blocks of the range and enqueues the heights, so they are processed from scratch.
+// Heights which are processing right now are left as is.
+func (s *Server) reprocess(ctx context.Context, w http.ResponseWriter, from, to int64) {
+	deleted, err := s.storage.DeleteBlocks(ctx, from, to)
+	if err != nil {
+		s.log.Error().Err(err).Int64("from", from).Int64("to", to).Msg("can't delete blocks")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.worker.Reprocess(from, to)
+
+	s.writeJSON(w, struct {
+		From    int64 `json:"from"`
+		To      int64 `json:"to"`
+		Deleted int64 `json:"deleted"`
+	}{From: from, To: to, Deleted: deleted})
+}
+
+func (s *Server) handleSkipBlock(w http.ResponseWriter, r *http.Request) {
+	if height, ok := pathHeight(w, r); ok {
+		s.skip(r.Context(), w, height, height)
+	}
+}
+
+func (s *Server) handleSkipRange(w http.ResponseWriter, r *http.Request) {
+	if from, to, ok := s.queryRange(w, r); ok {
+		s.skip(r.Context(), w, from, to)
+	}
+}
+
+// skip sets the skipped status of the heights, so they are not processed anymore.
+func (s *Server) skip(ctx context.Context, w http.ResponseWriter, from, to int64) {
+	for height := from; height <= to; height++ {
+		if err := s.storage.SkipBlock(ctx, height); err != nil {
+			s.log.Error().Err(err).Int64("height", height).Msg("can't skip block")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	s.log.Info().Int64("from", from).Int64("to", to).Msg("heights skipped")
+
+	s.writeJSON(w, struct {
+		From int64 `json:"from"`
+		To   int64 `json:"to"`
+	}{From: from, To: to})
+}
+
+func (s *Server) handleWorkerState(w http.ResponseWriter, _ *http.Request) {
+	s.writeWorkerState(w)
+}
+
+func (s *Server) handlePauseWorker(w http.ResponseWriter, _ *http.Request) {
+	s.worker.Pause()
+	s.writeWorkerState(w)
+}
+
+func (s *Server) handleResumeWorker(w http.ResponseWriter, _ *http.Request) {
+	s.worker.Resume()
+	s.writeWorkerState(w)
+}
+
+func (s *Server) writeWorkerState(w http.ResponseWriter) {
+	s.writeJSON(w, struct {
+		Paused bool `json:"paused"`
+	}{Paused: s.worker.Paused()})
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := jsoniter.NewEncoder(w).Encode(v); err != nil {
+		s.log.Error().Err(err).Msg("can't write response")
+	}
+}
+
+// queryRange parses the from and to query parameters limited by the max range of the config.
+func (s *Server) queryRange(w http.ResponseWriter, r *http.Request) (from, to int64, ok bool) {
+	from, fromErr := queryInt(r, "from", -1)
+	to, toErr := queryInt(r, "to", -1)
+
+	if fromErr != nil || toErr != nil || from < 0 || to < from || to-from >= s.cfg.AdminMaxRange {
+		http.Error(w, errInvalidRange.Error(), http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	return from, to, true
+}
+
+func pathHeight(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	height, err := strconv.ParseInt(r.PathValue("height"), 10, 64)
+	if err != nil || height < 0 {
+		http.Error(w, "invalid height", http.StatusBadRequest)
+		return 0, false
+	}
+
+	return height, true
+}
+
+func queryInt(r *http.Request, key string, def int64) (int64, error) {
+	val := strings.TrimSpace(r.URL.Query().Get(key))
+	if val == "" {
+		return def, nil
+	}
+
+	return strconv.ParseInt(val, 10, 64)
+}
+
+func newBlockView(b *model.Block) blockView {
+	return blockView{
+		Created:      b.Created,
+		Processed:    b.Processed,
+		LeaseExpires: b.LeaseExpires,
+		LastAttempt:  b.LastAttempt,
+		Status:       b.Status.ToString(),
+		ErrorMessage: b.ErrorMessage,
+		Owner:        b.Owner,
+		Hash:         b.Hash,
+		ParentHash:   b.ParentHash,
+		Height:       b.Height,
+		Attempts:     b.Attempts,
+	}
+}
@@ -0,0 +1,245 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/rs/zerolog"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/memory"
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/model"
+)
+
+type fakeWorker struct {
+	reprocessed [][2]int64
+	paused      bool
+}
+
+func (w *fakeWorker) Pause()       { w.paused = true }
+func (w *fakeWorker) Resume()      { w.paused = false }
+func (w *fakeWorker) Paused() bool { return w.paused }
+
+func (w *fakeWorker) Reprocess(from, to int64) {
+	w.reprocessed = append(w.reprocessed, [2]int64{from, to})
+}
+
+func newTestServer(t *testing.T) (*Server, *memory.Storage, *fakeWorker) {
+	t.Helper()
+
+	var (
+		ctx = context.Background()
+		sto = memory.New()
+		wrk = &fakeWorker{}
+	)
+
+	for height := int64(1); height <= 5; height++ {
+		status := model.StatusProcessed
+		if height%2 == 0 {
+			status = model.StatusError
+		}
+
+		if err := sto.CreateBlock(ctx, &model.Block{Height: height, Status: status, Created: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srv := New(Config{AdminEnabled: true, AdminToken: "secret", AdminMaxRange: 3}, sto, wrk, zerolog.Nop())
+
+	return srv, sto, wrk
+}
+
+func serve(h http.HandlerFunc, method, target string, pathValues ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	r.Header.Set("Authorization", "Bearer secret")
+
+	for i := 0; i+1 < len(pathValues); i += 2 {
+		r.SetPathValue(pathValues[i], pathValues[i+1])
+	}
+
+	w := httptest.NewRecorder()
+	h(w, r)
+
+	return w
+}
+
+func TestAdminToken(t *testing.T) {
+	srv, _, _ := newTestServer(t)
+
+	r := httptest.NewRequest(http.MethodGet, "/admin/worker", nil)
+	w := httptest.NewRecorder()
+	srv.admin(srv.handleWorkerState)(w, r)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("want %d without token, got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	// the admin API is not served without the configured token
+	noToken := New(Config{AdminEnabled: true}, nil, nil, zerolog.Nop())
+	if err := noToken.Start(context.Background()); !errors.Is(err, errAdminTokenRequired) {
+		t.Errorf("want errAdminTokenRequired on start without token, got %v", err)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/admin/worker", nil)
+	r.Header.Set("Authorization", "Bearer ")
+	w = httptest.NewRecorder()
+	noToken.admin(srv.handleWorkerState)(w, r)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("want %d with empty token, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestAdminListBlocks(t *testing.T) {
+	srv, _, _ := newTestServer(t)
+
+	w := serve(srv.admin(srv.handleListBlocks), http.MethodGet, "/admin/blocks?status=error&offset=1&limit=10")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected code %d: %s", w.Code, w.Body.String())
+	}
+
+	var res struct {
+		Blocks []blockView `json:"blocks"`
+	}
+	if err := jsoniter.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Blocks) != 1 || res.Blocks[0].Height != 4 || res.Blocks[0].Status != "error" {
+		t.Errorf("want the second error block 4, got %+v", res.Blocks)
+	}
+
+	if w = serve(srv.handleListBlocks, http.MethodGet, "/admin/blocks?status=unknown"); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown status: want %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestAdminGetBlock(t *testing.T) {
+	srv, sto, _ := newTestServer(t)
+
+	ctx := context.Background()
+	_ = sto.InsertErrorTx(ctx, model.Tx{Hash: "AB", Height: 2, ErrorMessage: "tx not found"})
+	_ = sto.InsertErrorTx(ctx, model.Tx{Hash: "CD", Height: 3, ErrorMessage: "tx not found"})
+	_ = sto.InsertErrorMessage(ctx, model.Message{Height: 2, ErrorMessage: "no concrete type"})
+
+	w := serve(srv.handleGetBlock, http.MethodGet, "/admin/blocks/2", "height", "2")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected code %d: %s", w.Code, w.Body.String())
+	}
+
+	var res struct {
+		ErrorTxs      []errorTxView      `json:"error_txs"`
+		ErrorMessages []errorMessageView `json:"error_messages"`
+		Block         blockView          `json:"block"`
+	}
+	if err := jsoniter.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Block.Height != 2 || len(res.ErrorTxs) != 1 || res.ErrorTxs[0].Hash != "AB" || len(res.ErrorMessages) != 1 {
+		t.Errorf("unexpected response %s", w.Body.String())
+	}
+
+	if w = serve(srv.handleGetBlock, http.MethodGet, "/admin/blocks/9", "height", "9"); w.Code != http.StatusNotFound {
+		t.Errorf("missing block: want %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestAdminReprocessAndSkip(t *testing.T) {
+	srv, sto, wrk := newTestServer(t)
+	ctx := context.Background()
+
+	w := serve(srv.handleReprocessRange, http.MethodPost, "/admin/reprocess?from=2&to=4")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected code %d: %s", w.Code, w.Body.String())
+	}
+
+	if _, err := sto.GetBlockByHeight(ctx, 3); err == nil {
+		t.Error("want the block 3 deleted")
+	}
+	if len(wrk.reprocessed) != 1 || wrk.reprocessed[0] != [2]int64{2, 4} {
+		t.Errorf("want range 2-4 reprocessed, got %v", wrk.reprocessed)
+	}
+
+	// the range exceeds the max
+	if w = serve(srv.handleSkipRange, http.MethodPost, "/admin/skip?from=1&to=4"); w.Code != http.StatusBadRequest {
+		t.Errorf("want %d for the long range, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	if w = serve(srv.handleSkipBlock, http.MethodPost, "/admin/blocks/7/skip", "height", "7"); w.Code != http.StatusOK {
+		t.Fatalf("unexpected code %d: %s", w.Code, w.Body.String())
+	}
+
+	block, err := sto.GetBlockByHeight(ctx, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !block.Status.IsSkipped() {
+		t.Errorf("want skipped status, got %s", block.Status.ToString())
+	}
+}
+
+func TestAdminPauseResume(t *testing.T) {
+	srv, _, wrk := newTestServer(t)
+
+	w := serve(srv.handlePauseWorker, http.MethodPost, "/admin/worker/pause")
+	if !wrk.paused || !strings.Contains(w.Body.String(), `"paused":true`) {
+		t.Errorf("want paused worker, got %s", w.Body.String())
+	}
+
+	w = serve(srv.handleResumeWorker, http.MethodPost, "/admin/worker/resume")
+	if wrk.paused || !strings.Contains(w.Body.String(), `"paused":false`) {
+		t.Errorf("want resumed worker, got %s", w.Body.String())
+	}
+}
+
+func TestAdminBackfillJobs(t *testing.T) {
+	srv, sto, _ := newTestServer(t)
+
+	w := serve(srv.handleCreateBackfillJob, http.MethodPost, "/admin/backfill?from=100&to=199&priority=3")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("unexpected code %d: %s", w.Code, w.Body.String())
+	}
+
+	var job backfillJobView
+	if err := jsoniter.Unmarshal(w.Body.Bytes(), &job); err != nil {
+		t.Fatal(err)
+	}
+
+	if job.ID == "" || job.Status != "active" || job.Next != 100 || job.Priority != 3 {
+		t.Errorf("unexpected job %+v", job)
+	}
+
+	if err := sto.SetBackfillJobProgress(context.Background(), job.ID, 150); err != nil {
+		t.Fatal(err)
+	}
+
+	w = serve(srv.handleGetBackfillJob, http.MethodGet, "/admin/backfill/"+job.ID, "id", job.ID)
+	if !strings.Contains(w.Body.String(), `"progress":0.5`) {
+		t.Errorf("want half progress, got %s", w.Body.String())
+	}
+
+	cancelJob := srv.backfillJobStatusHandler(model.BackfillJobCancelled)
+	if w = serve(cancelJob, http.MethodPost, "/", "id", job.ID); w.Code != http.StatusOK {
+		t.Fatalf("cancel: unexpected code %d: %s", w.Code, w.Body.String())
+	}
+
+	// the cancelled job can't be resumed
+	resumeJob := srv.backfillJobStatusHandler(model.BackfillJobActive)
+	if w = serve(resumeJob, http.MethodPost, "/", "id", job.ID); w.Code != http.StatusConflict {
+		t.Errorf("resume: want %d, got %d", http.StatusConflict, w.Code)
+	}
+
+	if w = serve(resumeJob, http.MethodPost, "/", "id", "missing"); w.Code != http.StatusNotFound {
+		t.Errorf("missing job: want %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	if w = serve(srv.handleCreateBackfillJob, http.MethodPost, "/admin/backfill?from=9&to=1"); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid range: want %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
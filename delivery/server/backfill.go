@@ -0,0 +1,160 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/model"
+	"github.com/bro-n-bro/spacebox-crawler/v2/types"
+)
+
+type backfillJobView struct {
+	Created  time.Time  `json:"created"`
+	Finished *time.Time `json:"finished,omitempty"`
+	ID       string     `json:"id"`
+	Status   string     `json:"status"`
+	From     int64      `json:"from"`
+	To       int64      `json:"to"`
+	Next     int64      `json:"next"`
+	Progress float64    `json:"progress"`
+	Priority int        `json:"priority"`
+}
+
+// registerBackfillHandlers registers the admin API of the backfill jobs.
+func (s *Server) registerBackfillHandlers() {
+	http.HandleFunc("POST /admin/backfill", s.admin(s.handleCreateBackfillJob))
+	http.HandleFunc("GET /admin/backfill", s.admin(s.handleListBackfillJobs))
+	http.HandleFunc("GET /admin/backfill/{id}", s.admin(s.handleGetBackfillJob))
+	http.HandleFunc("POST /admin/backfill/{id}/pause", s.admin(s.backfillJobStatusHandler(model.BackfillJobPaused)))
+	http.HandleFunc("POST /admin/backfill/{id}/resume", s.admin(s.backfillJobStatusHandler(model.BackfillJobActive)))
+	http.HandleFunc("POST /admin/backfill/{id}/cancel", s.admin(s.backfillJobStatusHandler(model.BackfillJobCancelled)))
+}
+
+// handleCreateBackfillJob creates the active job of the from-to height range with the optional priority.
+func (s *Server) handleCreateBackfillJob(w http.ResponseWriter, r *http.Request) {
+	from, fromErr := queryInt(r, "from", -1)
+	to, toErr := queryInt(r, "to", -1)
+
+	if fromErr != nil || toErr != nil || from < 0 || to < from {
+		http.Error(w, errInvalidRange.Error(), http.StatusBadRequest)
+		return
+	}
+
+	priority, err := strconv.Atoi(r.URL.Query().Get("priority"))
+	if err != nil && r.URL.Query().Has("priority") {
+		http.Error(w, "invalid priority", http.StatusBadRequest)
+		return
+	}
+
+	job := &model.BackfillJob{
+		Created:  time.Now(),
+		ID:       newBackfillJobID(),
+		From:     from,
+		To:       to,
+		Next:     from,
+		Priority: priority,
+		Status:   model.BackfillJobActive,
+	}
+
+	if err = s.storage.CreateBackfillJob(r.Context(), job); err != nil {
+		s.log.Error().Err(err).Msg("can't create backfill job")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.log.Info().Str("job_id", job.ID).Int64("from", from).Int64("to", to).Msg("backfill job created")
+
+	w.WriteHeader(http.StatusCreated)
+	s.writeJSON(w, newBackfillJobView(job))
+}
+
+func (s *Server) handleListBackfillJobs(w http.ResponseWriter, r *http.Request) {
+	jobs, err := s.storage.GetBackfillJobs(r.Context())
+	if err != nil {
+		s.log.Error().Err(err).Msg("can't get backfill jobs")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res := make([]backfillJobView, len(jobs))
+	for i, job := range jobs {
+		res[i] = newBackfillJobView(job)
+	}
+
+	s.writeJSON(w, struct {
+		Jobs []backfillJobView `json:"jobs"`
+	}{Jobs: res})
+}
+
+func (s *Server) handleGetBackfillJob(w http.ResponseWriter, r *http.Request) {
+	job, err := s.storage.GetBackfillJob(r.Context(), r.PathValue("id"))
+	if err != nil {
+		s.writeBackfillJobError(w, err)
+		return
+	}
+
+	s.writeJSON(w, newBackfillJobView(job))
+}
+
+// backfillJobStatusHandler returns the handler which sets the status of the job.
+func (s *Server) backfillJobStatusHandler(status model.BackfillJobStatus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			ctx = r.Context()
+			id  = r.PathValue("id")
+		)
+
+		if err := s.storage.SetBackfillJobStatus(ctx, id, status); err != nil {
+			s.writeBackfillJobError(w, err)
+			return
+		}
+
+		job, err := s.storage.GetBackfillJob(ctx, id)
+		if err != nil {
+			s.writeBackfillJobError(w, err)
+			return
+		}
+
+		s.log.Info().Str("job_id", id).Str("status", status.ToString()).Msg("backfill job status changed")
+
+		s.writeJSON(w, newBackfillJobView(job))
+	}
+}
+
+func (s *Server) writeBackfillJobError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, types.ErrBackfillJobNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, types.ErrBackfillJobDone):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		s.log.Error().Err(err).Msg("backfill job error")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func newBackfillJobID() string {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+
+	return hex.EncodeToString(id)
+}
+
+func newBackfillJobView(job *model.BackfillJob) backfillJobView {
+	return backfillJobView{
+		Created:  job.Created,
+		Finished: job.Finished,
+		ID:       job.ID,
+		Status:   job.Status.ToString(),
+		From:     job.From,
+		To:       job.To,
+		Next:     job.Next,
+		Progress: job.Progress(),
+		Priority: job.Priority,
+	}
+}
@@ -1,6 +1,11 @@
 package server
 
 type Config struct {
-	Port           string `env:"SERVER_PORT" envDefault:"8080"`
-	MetricsEnabled bool   `env:"METRICS_ENABLED" envDefault:"false"`
+	Port string `env:"SERVER_PORT" envDefault:"8080"`
+	// AdminToken is the bearer token of the admin API requests, required if the admin API is enabled
+	AdminToken string `env:"ADMIN_API_TOKEN"`
+	// AdminMaxRange is the max count of heights reprocessed or skipped by one request
+	AdminMaxRange  int64 `env:"ADMIN_API_MAX_RANGE" envDefault:"10000"`
+	AdminEnabled   bool  `env:"ADMIN_API_ENABLED" envDefault:"false"`
+	MetricsEnabled bool  `env:"METRICS_ENABLED" envDefault:"false"`
 }
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"net/http"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/types"
+)
+
+// handleFinalizedHeight returns the height up to which all heights are processed.
+func (s *Server) handleFinalizedHeight(w http.ResponseWriter, r *http.Request) {
+	height, err := s.storage.GetFinalizedHeight(r.Context())
+	switch {
+	case errors.Is(err, types.ErrFinalizedHeightNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		s.log.Error().Err(err).Msg("can't get finalized height")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = jsoniter.NewEncoder(w).Encode(struct {
+		Height int64 `json:"height"`
+	}{Height: height}); err != nil {
+		s.log.Error().Err(err).Msg("can't write finalized height")
+	}
+}
@@ -26,6 +26,20 @@ func (s *Server) startMetricsScrapper() {
 			Help:      "Total blocks for each status",
 		}, []string{"status"})
 
+		// processing attempts of blocks for each status
+		attemptsMetric = promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "block_attempts_by_status",
+			Help:      "Total processing attempts of blocks for each status",
+		}, []string{"status"})
+
+		// count of error blocks which are retried at least once
+		retriedMetric = promauto.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "retried_error_blocks",
+			Help:      "Total error blocks processed more than once",
+		})
+
 		// last processed height
 		heightMetric = promauto.NewGauge(prometheus.GaugeOpts{
 			Namespace: namespace,
@@ -33,6 +47,13 @@ func (s *Server) startMetricsScrapper() {
 			Help:      "Last processed block height",
 		})
 
+		// height up to which all heights are processed
+		finalizedHeightMetric = promauto.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "stored_finalized_height",
+			Help:      "Stored height up to which all heights are processed",
+		})
+
 		// count of error messages in storage
 		errorMessagesCount = promauto.NewGauge(prometheus.GaugeOpts{
 			Namespace: namespace,
@@ -46,11 +67,12 @@ func (s *Server) startMetricsScrapper() {
 			Help:      "Total error txs",
 		})
 
-		statusMap map[string]int
-		ctx       = context.Background()
-		ticker    = time.NewTicker(1 * time.Minute)
-		blocks    []*model.Block
-		err       error
+		statusMap   map[string]int
+		attemptsMap map[string]int
+		ctx         = context.Background()
+		ticker      = time.NewTicker(1 * time.Minute)
+		blocks      []*model.Block
+		err         error
 	)
 
 	defer ticker.Stop()
@@ -75,20 +97,37 @@ func (s *Server) startMetricsScrapper() {
 				model.StatusProcessing.ToString(): 0,
 				model.StatusProcessed.ToString():  0,
 				model.StatusError.ToString():      0,
+				model.StatusFailed.ToString():     0,
+				model.StatusSkipped.ToString():    0,
 			}
+			attemptsMap = make(map[string]int, len(statusMap))
 
-			var maxHeight int64
+			var (
+				maxHeight int64
+				retried   int
+			)
 			for _, b := range blocks {
 				if b.Status.IsProcessed() && b.Height > maxHeight {
 					maxHeight = b.Height
 				}
+				if b.Status.IsError() && b.Attempts > 1 {
+					retried++
+				}
 				statusMap[b.Status.ToString()] += 1
+				attemptsMap[b.Status.ToString()] += b.Attempts
 			}
 
+			retriedMetric.Set(float64(retried))
+
 			heightMetric.Set(float64(maxHeight))
 
 			for statusName, count := range statusMap {
 				statusMetric.With(prometheus.Labels{"status": statusName}).Set(float64(count))
+				attemptsMetric.With(prometheus.Labels{"status": statusName}).Set(float64(attemptsMap[statusName]))
+			}
+
+			if height, err := s.storage.GetFinalizedHeight(ctx); err == nil {
+				finalizedHeightMetric.Set(float64(height))
 			}
 
 			var count int64
@@ -10,31 +10,60 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog"
 
-	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage"
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/model"
 )
 
-type Server struct {
-	log     *zerolog.Logger
-	srv     *http.Server
-	storage *storage.Storage
+type (
+	storage interface {
+		Ping(ctx context.Context) error
+		GetAllBlocks(ctx context.Context) ([]*model.Block, error)
+		CountErrorMessages(ctx context.Context) (int64, error)
+		CountErrorTxs(ctx context.Context) (int64, error)
+		GetFinalizedHeight(ctx context.Context) (int64, error)
+
+		GetBlockByHeight(ctx context.Context, height int64) (*model.Block, error)
+		GetBlocksByStatus(ctx context.Context, status model.Status, offset, limit int64) ([]*model.Block, error)
+		GetErrorTxs(ctx context.Context, height int64) ([]model.Tx, error)
+		GetErrorMessages(ctx context.Context, height int64) ([]model.Message, error)
+		DeleteBlocks(ctx context.Context, from, to int64) (int64, error)
+		SkipBlock(ctx context.Context, height int64) error
+
+		CreateBackfillJob(ctx context.Context, job *model.BackfillJob) error
+		GetBackfillJob(ctx context.Context, id string) (*model.BackfillJob, error)
+		GetBackfillJobs(ctx context.Context) ([]*model.BackfillJob, error)
+		SetBackfillJobStatus(ctx context.Context, id string, status model.BackfillJobStatus) error
+	}
 
-	stopScraping chan struct{}
+	Server struct {
+		log     *zerolog.Logger
+		srv     *http.Server
+		storage storage
+		worker  worker
 
-	cfg Config
-}
+		stopScraping chan struct{}
+
+		cfg Config
+	}
+)
 
-func New(cfg Config, s *storage.Storage, l zerolog.Logger) *Server {
+func New(cfg Config, s storage, w worker, l zerolog.Logger) *Server {
 	l = l.With().Str("cmp", "server").Logger()
 
 	return &Server{
 		log:          &l,
 		cfg:          cfg,
 		storage:      s,
+		worker:       w,
 		stopScraping: make(chan struct{}),
 	}
 }
 
 func (s *Server) Start(ctx context.Context) error {
+	// the admin API changes the block processing, so it is never served without authorization
+	if s.cfg.AdminEnabled && s.cfg.AdminToken == "" {
+		return errAdminTokenRequired
+	}
+
 	s.srv = &http.Server{
 		Addr:              ":" + s.cfg.Port,
 		ReadHeaderTimeout: 1 * time.Second,
@@ -52,6 +81,13 @@ func (s *Server) Start(ctx context.Context) error {
 		http.Handle("/metrics/", promhttp.Handler())
 	}
 
+	http.HandleFunc("/finalized_height", s.handleFinalizedHeight)
+
+	if s.cfg.AdminEnabled {
+		s.registerAdminHandlers()
+		s.registerBackfillHandlers()
+	}
+
 	go func() {
 		if err := s.srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
 			s.log.Fatal().Err(err).Msg("ListenAndServe error")
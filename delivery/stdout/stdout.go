@@ -0,0 +1,114 @@
+package stdout
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/rs/zerolog"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/delivery/broker"
+	"github.com/bro-n-bro/spacebox-crawler/v2/internal/rep"
+)
+
+var _ rep.Broker = &Sink{}
+
+type (
+	// Sink writes messages of all topics to stdout as newline-delimited JSON. Useful for debugging.
+	Sink struct {
+		log *zerolog.Logger
+		out io.Writer
+		mu  sync.Mutex
+
+		version string
+	}
+
+	line struct {
+		Headers map[string]string `json:"headers"`
+		Topic   string            `json:"topic"`
+		Key     string            `json:"key,omitempty"`
+		Value   json.RawMessage   `json:"value"`
+	}
+)
+
+func New(l zerolog.Logger, version string) *Sink {
+	l = l.With().Str("cmp", "stdout_sink").Logger()
+
+	return &Sink{
+		log:     &l,
+		out:     os.Stdout,
+		version: version,
+	}
+}
+
+func (s *Sink) Start(_ context.Context) error { return nil }
+
+func (s *Sink) Stop(_ context.Context) error { return nil }
+
+func (s *Sink) PublishRawBlock(ctx context.Context, b interface{}) error {
+	return s.publish(ctx, broker.RawBlock, b)
+}
+
+func (s *Sink) PublishRawTransaction(ctx context.Context, tx interface{}) error {
+	return s.publish(ctx, broker.RawTransaction, tx)
+}
+
+func (s *Sink) PublishRawBlockResults(ctx context.Context, br interface{}) error {
+	return s.publish(ctx, broker.RawBlockResults, br)
+}
+
+func (s *Sink) PublishRawGenesis(ctx context.Context, g interface{}) error {
+	return s.publish(ctx, broker.RawGenesis, g)
+}
+
+func (s *Sink) PublishReorg(ctx context.Context, r interface{}) error {
+	return s.publish(ctx, broker.Reorg, r)
+}
+
+func (s *Sink) PublishFinalizedHeight(ctx context.Context, fh interface{}) error {
+	return s.publish(ctx, broker.FinalizedHeight, fh)
+}
+
+func (s *Sink) PublishDeadLetter(ctx context.Context, dl interface{}) error {
+	return s.publish(ctx, broker.DeadLetter, dl)
+}
+
+// BeginTransaction is not supported, messages are written immediately.
+func (s *Sink) BeginTransaction(ctx context.Context) (context.Context, error) { return ctx, nil }
+
+func (s *Sink) CommitTransaction(_ context.Context) error { return nil }
+
+func (s *Sink) AbortTransaction(_ context.Context) error { return nil }
+
+func (s *Sink) publish(ctx context.Context, topic broker.Topic, msg interface{}) error {
+	rec, err := broker.NewRecord(ctx, topic, msg, broker.PartitionKeyMessage, s.version)
+	if err != nil {
+		return err
+	}
+
+	l := line{
+		Topic:   rec.Topic,
+		Key:     string(rec.Key),
+		Value:   rec.Value,
+		Headers: make(map[string]string, len(rec.Headers)),
+	}
+
+	for _, h := range rec.Headers {
+		l.Headers[h.Key] = h.Value
+	}
+
+	data, err := jsoniter.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.out.Write(append(data, '\n'))
+
+	return err
+}
@@ -46,11 +46,9 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/rs/zerolog"
 
-	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage"
 	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/model"
 	grpcClient "github.com/bro-n-bro/spacebox-crawler/v2/client/grpc"
 	rpcClient "github.com/bro-n-bro/spacebox-crawler/v2/client/rpc"
-	"github.com/bro-n-bro/spacebox-crawler/v2/delivery/broker"
 	"github.com/bro-n-bro/spacebox-crawler/v2/delivery/server"
 	"github.com/bro-n-bro/spacebox-crawler/v2/internal/rep"
 	"github.com/bro-n-bro/spacebox-crawler/v2/modules"
@@ -105,20 +103,28 @@ func (a *App) Start(ctx context.Context) error {
 		}).Inc()
 	}
 
+	sto, err := newStorage(a.cfg, *a.log)
+	if err != nil {
+		return err
+	}
+
+	brk, err := newBroker(a.cfg, *a.log, a.version)
+	if err != nil {
+		return err
+	}
+
 	var (
 		cod     = MakeEncodingConfig()
-		sto     = storage.New(a.cfg.StorageConfig, *a.log)
-		rpcCli  = rpcClient.New(a.cfg.RPCConfig)
-		grpcCli = grpcClient.New(a.cfg.GRPCConfig, *a.log, sto)
-
-		brk = broker.New(a.cfg.BrokerConfig, *a.log)
+		rpcCli  = rpcClient.New(a.cfg.RPCConfig, *a.log)
+		seq     = worker.NewSequencer(a.cfg.WorkerConfig, brk, *a.log)
+		grpcCli = grpcClient.New(a.cfg.GRPCConfig, *a.log, sto, seq)
 
-		raw  = rawModule.New(brk, rpcCli)
+		raw  = rawModule.New(seq)
 		mods = modules.NewModuleLoader().WithLogger(a.log).WithModules(raw)
 
 		tos = ts.NewToStorage()
-		wrk = worker.New(a.cfg.WorkerConfig, *a.log, brk, rpcCli, grpcCli, mods.Build(), sto, cod, *tos)
-		srv = server.New(a.cfg.Server, sto, *a.log)
+		wrk = worker.New(a.cfg.WorkerConfig, *a.log, seq, rpcCli, grpcCli, mods.Build(), sto, cod, *tos)
+		srv = server.New(a.cfg.Server, sto, wrk, *a.log)
 		hc  = healthchecker.New(*a.log, checkLastBlockDiff(a.cfg.HealthcheckConfig.MaxBlockLag, sto), a.cfg.HealthcheckConfig) //nolint:lll
 	)
 
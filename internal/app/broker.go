@@ -0,0 +1,41 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/rs/zerolog"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/delivery/broker"
+	"github.com/bro-n-bro/spacebox-crawler/v2/delivery/file"
+	"github.com/bro-n-bro/spacebox-crawler/v2/delivery/jetstream"
+	"github.com/bro-n-bro/spacebox-crawler/v2/delivery/stdout"
+	"github.com/bro-n-bro/spacebox-crawler/v2/internal/rep"
+)
+
+const (
+	BrokerTypeKafka     = "kafka"
+	BrokerTypeJetStream = "jetstream"
+	BrokerTypeFile      = "file"
+	BrokerTypeStdout    = "stdout"
+)
+
+type messageBroker interface {
+	rep.Lifecycle
+	rep.Broker
+}
+
+// newBroker creates a message sink based on the configured broker type.
+func newBroker(cfg Config, l zerolog.Logger, version string) (messageBroker, error) {
+	switch cfg.BrokerType {
+	case BrokerTypeKafka:
+		return broker.New(cfg.BrokerConfig, l, broker.WithCrawlerVersion(version)), nil
+	case BrokerTypeJetStream:
+		return jetstream.New(cfg.JetStreamConfig, l, version), nil
+	case BrokerTypeFile:
+		return file.New(cfg.FileSinkConfig, l, version), nil
+	case BrokerTypeStdout:
+		return stdout.New(l, version), nil
+	}
+
+	return nil, fmt.Errorf("unknown broker type: %q", cfg.BrokerType)
+}
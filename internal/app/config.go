@@ -4,9 +4,13 @@ import (
 	"time"
 
 	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage"
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/bolt"
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/postgres"
 	"github.com/bro-n-bro/spacebox-crawler/v2/client/grpc"
 	"github.com/bro-n-bro/spacebox-crawler/v2/client/rpc"
 	"github.com/bro-n-bro/spacebox-crawler/v2/delivery/broker"
+	"github.com/bro-n-bro/spacebox-crawler/v2/delivery/file"
+	"github.com/bro-n-bro/spacebox-crawler/v2/delivery/jetstream"
 	"github.com/bro-n-bro/spacebox-crawler/v2/delivery/server"
 	healthchecker "github.com/bro-n-bro/spacebox-crawler/v2/pkg/health_checker"
 	"github.com/bro-n-bro/spacebox-crawler/v2/pkg/worker"
@@ -16,11 +20,17 @@ type Config struct {
 	ChainPrefix       string `env:"CHAIN_PREFIX"`
 	DefaultDenom      string `env:"DEFAULT_DENOM" envDefault:"uatom"`
 	LogLevel          string `env:"LOG_LEVEL" envDefault:"info"`
+	StorageDriver     string `env:"STORAGE_DRIVER" envDefault:"mongo"`
+	BrokerType        string `env:"BROKER_TYPE" envDefault:"kafka"`
 	Server            server.Config
 	GRPCConfig        grpc.Config
 	RPCConfig         rpc.Config
 	BrokerConfig      broker.Config
+	JetStreamConfig   jetstream.Config
+	FileSinkConfig    file.Config
 	StorageConfig     storage.Config
+	PostgresConfig    postgres.Config
+	BoltConfig        bolt.Config
 	WorkerConfig      worker.Config
 	HealthcheckConfig healthchecker.Config
 	StartTimeout      time.Duration `env:"START_TIMEOUT"`
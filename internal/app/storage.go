@@ -0,0 +1,55 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/zerolog"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage"
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/bolt"
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/memory"
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/model"
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/postgres"
+	"github.com/bro-n-bro/spacebox-crawler/v2/internal/rep"
+)
+
+const (
+	StorageDriverMongo    = "mongo"
+	StorageDriverPostgres = "postgres"
+	StorageDriverBolt     = "bolt"
+	StorageDriverMemory   = "memory"
+)
+
+type blockStorage interface {
+	rep.Lifecycle
+	rep.Storage
+
+	GetLatestBlock(ctx context.Context) (*model.Block, error)
+	GetAllBlocks(ctx context.Context) ([]*model.Block, error)
+	CountErrorMessages(ctx context.Context) (int64, error)
+	CountErrorTxs(ctx context.Context) (int64, error)
+
+	// admin api
+	GetBlocksByStatus(ctx context.Context, status model.Status, offset, limit int64) ([]*model.Block, error)
+	GetErrorTxs(ctx context.Context, height int64) ([]model.Tx, error)
+	GetErrorMessages(ctx context.Context, height int64) ([]model.Message, error)
+	DeleteBlocks(ctx context.Context, from, to int64) (int64, error)
+	SkipBlock(ctx context.Context, height int64) error
+}
+
+// newStorage creates a block storage based on the configured driver.
+func newStorage(cfg Config, l zerolog.Logger) (blockStorage, error) {
+	switch cfg.StorageDriver {
+	case StorageDriverMongo:
+		return storage.New(cfg.StorageConfig, l), nil
+	case StorageDriverPostgres:
+		return postgres.New(cfg.PostgresConfig, l), nil
+	case StorageDriverBolt:
+		return bolt.New(cfg.BoltConfig, l), nil
+	case StorageDriverMemory:
+		return memory.New(), nil
+	}
+
+	return nil, fmt.Errorf("unknown storage driver: %q", cfg.StorageDriver)
+}
@@ -0,0 +1,157 @@
+package fake
+
+import (
+	"context"
+	"sync"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/delivery/broker"
+)
+
+type (
+	// Broker records every published message by the topic name instead of sending it anywhere.
+	// Messages of a transaction are recorded on commit.
+	Broker struct {
+		published map[string][]interface{}
+		// slots limits the count of open transactions as the pool of transactional producers does
+		slots     chan struct{}
+		mu        sync.Mutex
+		committed int
+		aborted   int
+	}
+
+	transaction struct {
+		topics   []string
+		messages []interface{}
+	}
+
+	transactionKey struct{}
+)
+
+func NewBroker() *Broker {
+	return &Broker{published: make(map[string][]interface{})}
+}
+
+// WithTransactionSlots limits the count of open transactions. BeginTransaction waits for a free slot.
+func (b *Broker) WithTransactionSlots(count int) *Broker {
+	b.slots = make(chan struct{}, count)
+	return b
+}
+
+// Transactional reports that messages are published within transactions.
+func (b *Broker) Transactional() bool { return true }
+
+func (b *Broker) PublishRawBlock(ctx context.Context, block interface{}) error {
+	b.record(ctx, *broker.RawBlock, block)
+	return nil
+}
+
+func (b *Broker) PublishRawTransaction(ctx context.Context, tx interface{}) error {
+	b.record(ctx, *broker.RawTransaction, tx)
+	return nil
+}
+
+func (b *Broker) PublishRawBlockResults(ctx context.Context, br interface{}) error {
+	b.record(ctx, *broker.RawBlockResults, br)
+	return nil
+}
+
+func (b *Broker) PublishRawGenesis(ctx context.Context, g interface{}) error {
+	b.record(ctx, *broker.RawGenesis, g)
+	return nil
+}
+
+func (b *Broker) PublishReorg(ctx context.Context, r interface{}) error {
+	b.record(ctx, *broker.Reorg, r)
+	return nil
+}
+
+func (b *Broker) PublishFinalizedHeight(ctx context.Context, fh interface{}) error {
+	b.record(ctx, *broker.FinalizedHeight, fh)
+	return nil
+}
+
+func (b *Broker) PublishDeadLetter(_ context.Context, dl interface{}) error {
+	// dead letters are never a part of the transaction
+	b.record(context.Background(), *broker.DeadLetter, dl)
+	return nil
+}
+
+func (b *Broker) BeginTransaction(ctx context.Context) (context.Context, error) {
+	if b.slots != nil {
+		select {
+		case <-ctx.Done():
+			return ctx, ctx.Err()
+		case b.slots <- struct{}{}:
+		}
+	}
+
+	return context.WithValue(ctx, transactionKey{}, &transaction{}), nil
+}
+
+func (b *Broker) CommitTransaction(ctx context.Context) error {
+	tx, ok := ctx.Value(transactionKey{}).(*transaction)
+	if !ok {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, topic := range tx.topics {
+		b.published[topic] = append(b.published[topic], tx.messages[i])
+	}
+
+	b.committed++
+	b.release()
+
+	return nil
+}
+
+func (b *Broker) AbortTransaction(ctx context.Context) error {
+	if _, ok := ctx.Value(transactionKey{}).(*transaction); !ok {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.aborted++
+	b.release()
+
+	return nil
+}
+
+func (b *Broker) release() {
+	if b.slots != nil {
+		<-b.slots
+	}
+}
+
+// Transactions returns the count of committed and aborted transactions.
+func (b *Broker) Transactions() (committed, aborted int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.committed, b.aborted
+}
+
+// Published returns a copy of the messages published to the topic in the publishing order.
+func (b *Broker) Published(topic string) []interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]interface{}(nil), b.published[topic]...)
+}
+
+func (b *Broker) record(ctx context.Context, topic string, msg interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if tx, ok := ctx.Value(transactionKey{}).(*transaction); ok {
+		tx.topics = append(tx.topics, topic)
+		tx.messages = append(tx.messages, msg)
+		return
+	}
+
+	b.published[topic] = append(b.published[topic], msg)
+}
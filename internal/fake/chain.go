@@ -0,0 +1,167 @@
+package fake
+
+import (
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	abci "github.com/cometbft/cometbft/abci/types"
+	"github.com/cometbft/cometbft/crypto/tmhash"
+	cometbftcoretypes "github.com/cometbft/cometbft/rpc/core/types"
+	cometbfttypes "github.com/cometbft/cometbft/types"
+	codec "github.com/cosmos/cosmos-sdk/codec/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/tx"
+)
+
+type (
+	// Height is a scripted state of the chain at a single height.
+	Height struct {
+		// Err is returned by every client call for the height when set.
+		Err        error
+		Block      *cometbftcoretypes.ResultBlock
+		Validators *cometbftcoretypes.ResultValidators
+		Results    *cometbftcoretypes.ResultBlockResults
+		Txs        []*tx.GetTxResponse
+	}
+
+	// Chain is a synthetic blockchain shared by the fake RPC and gRPC clients.
+	Chain struct {
+		mu      sync.RWMutex
+		heights map[int64]*Height
+		genesis *cometbfttypes.GenesisDoc
+		events  chan cometbftcoretypes.ResultEvent
+		last    int64
+
+		resultsCalls atomic.Int64
+	}
+
+	// Tx describes a synthetic transaction.
+	Tx struct {
+		Messages []*codec.Any
+		Code     uint32
+		GasUsed  int64
+	}
+)
+
+func NewChain(chainID string) *Chain {
+	return &Chain{
+		heights: make(map[int64]*Height),
+		genesis: &cometbfttypes.GenesisDoc{
+			GenesisTime:   time.Unix(0, 0).UTC(),
+			ChainID:       chainID,
+			InitialHeight: 1,
+			AppState:      []byte(`{}`),
+		},
+		events: make(chan cometbftcoretypes.ResultEvent),
+	}
+}
+
+// AddHeight builds a block with the given transactions and begin/end blocker events and adds it to the chain.
+// The block is linked to the parent height if it exists, so a height added again starts a fork.
+func (c *Chain) AddHeight(height int64, txs []Tx, begin, end []abci.Event) *Height {
+	var (
+		tmTxs   = make(cometbfttypes.Txs, len(txs))
+		results = make([]*abci.ResponseDeliverTx, len(txs))
+		txResps = make([]*tx.GetTxResponse, len(txs))
+	)
+
+	for i, t := range txs {
+		tmTxs[i] = cometbfttypes.Tx(append([]byte{byte(i)}, sdk.Uint64ToBigEndian(uint64(height))...))
+		results[i] = &abci.ResponseDeliverTx{Code: t.Code, GasUsed: t.GasUsed}
+		txResps[i] = &tx.GetTxResponse{
+			Tx: &tx.Tx{Body: &tx.TxBody{Messages: t.Messages}},
+			TxResponse: &sdk.TxResponse{
+				Height:  height,
+				TxHash:  hex.EncodeToString(tmTxs[i].Hash()),
+				Code:    t.Code,
+				GasUsed: t.GasUsed,
+			},
+		}
+	}
+
+	block := cometbfttypes.MakeBlock(height, tmTxs, &cometbfttypes.Commit{}, nil)
+	block.Time = time.Unix(height, 0).UTC()
+	block.ValidatorsHash = tmhash.Sum([]byte(c.genesis.ChainID)) // header without validators has no hash
+
+	// link the block to the current parent
+	c.mu.RLock()
+	if parent, ok := c.heights[height-1]; ok && parent.Block != nil {
+		block.LastBlockID = parent.Block.BlockID
+	}
+	c.mu.RUnlock()
+
+	h := &Height{
+		Block: &cometbftcoretypes.ResultBlock{
+			BlockID: cometbfttypes.BlockID{Hash: block.Hash()},
+			Block:   block,
+		},
+		Validators: &cometbftcoretypes.ResultValidators{BlockHeight: height},
+		Results: &cometbftcoretypes.ResultBlockResults{
+			Height:           height,
+			TxsResults:       results,
+			BeginBlockEvents: begin,
+			EndBlockEvents:   end,
+		},
+		Txs: txResps,
+	}
+
+	c.SetHeight(height, h)
+
+	return h
+}
+
+// SetHeight sets the scripted state of the height.
+func (c *Chain) SetHeight(height int64, h *Height) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.heights[height] = h
+	if height > c.last {
+		c.last = height
+	}
+}
+
+// NewBlock adds a height to the chain and emits a NewBlock event for the subscribers.
+func (c *Chain) NewBlock(height int64, txs []Tx, begin, end []abci.Event) {
+	h := c.AddHeight(height, txs, begin, end)
+
+	c.mu.RLock()
+	events := c.events
+	c.mu.RUnlock()
+
+	events <- cometbftcoretypes.ResultEvent{
+		Query: "tm.event = 'NewBlock'",
+		Data:  cometbfttypes.EventDataNewBlock{Block: h.Block.Block},
+	}
+}
+
+// DropSubscription closes the event channel of the current subscribers, as the lost websocket connection does.
+// The next subscribers get a new channel.
+func (c *Chain) DropSubscription() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	close(c.events)
+	c.events = make(chan cometbftcoretypes.ResultEvent)
+}
+
+// BlockResultsCalls returns the count of the block results requests.
+func (c *Chain) BlockResultsCalls() int64 { return c.resultsCalls.Load() }
+
+func (c *Chain) height(height int64) (*Height, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	h, ok := c.heights[height]
+	if !ok {
+		return nil, ErrHeightNotFound
+	}
+
+	if h.Err != nil {
+		return nil, h.Err
+	}
+
+	return h, nil
+}
@@ -0,0 +1,7 @@
+package fake
+
+import "errors"
+
+var (
+	ErrHeightNotFound = errors.New("height not found")
+)
@@ -0,0 +1,11 @@
+// Package fake provides in-process implementations of the crawler dependencies
+// to drive the worker over a synthetic chain in tests.
+package fake
+
+import "github.com/bro-n-bro/spacebox-crawler/v2/internal/rep"
+
+var (
+	_ rep.Broker     = &Broker{}
+	_ rep.RPCClient  = &RPCClient{}
+	_ rep.GrpcClient = &GrpcClient{}
+)
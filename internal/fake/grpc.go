@@ -0,0 +1,47 @@
+package fake
+
+import (
+	"context"
+
+	abci "github.com/cometbft/cometbft/abci/types"
+	cometbftcoretypes "github.com/cometbft/cometbft/rpc/core/types"
+	"github.com/cosmos/cosmos-sdk/types/tx"
+)
+
+// GrpcClient serves gRPC requests from the scripted chain.
+type GrpcClient struct {
+	chain *Chain
+}
+
+func NewGrpcClient(c *Chain) *GrpcClient {
+	return &GrpcClient{chain: c}
+}
+
+func (c *GrpcClient) Block(_ context.Context, height int64) (*cometbftcoretypes.ResultBlock, error) {
+	h, err := c.chain.height(height)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.Block, nil
+}
+
+func (c *GrpcClient) Validators(_ context.Context, height int64) (*cometbftcoretypes.ResultValidators, error) {
+	h, err := c.chain.height(height)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.Validators, nil
+}
+
+func (c *GrpcClient) Txs(_ context.Context, block *cometbftcoretypes.ResultBlock,
+	_ []*abci.ResponseDeliverTx) ([]*tx.GetTxResponse, error) {
+
+	h, err := c.chain.height(block.Block.Height)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.Txs, nil
+}
@@ -0,0 +1,46 @@
+package fake
+
+import (
+	"context"
+
+	cometbftcoretypes "github.com/cometbft/cometbft/rpc/core/types"
+	cometbfttypes "github.com/cometbft/cometbft/types"
+)
+
+// RPCClient serves RPC requests from the scripted chain.
+type RPCClient struct {
+	chain *Chain
+}
+
+func NewRPCClient(c *Chain) *RPCClient {
+	return &RPCClient{chain: c}
+}
+
+func (c *RPCClient) SubscribeNewBlocks(_ context.Context) (<-chan cometbftcoretypes.ResultEvent, error) {
+	c.chain.mu.RLock()
+	defer c.chain.mu.RUnlock()
+
+	return c.chain.events, nil
+}
+
+func (c *RPCClient) Genesis(_ context.Context) (*cometbfttypes.GenesisDoc, error) {
+	return c.chain.genesis, nil
+}
+
+func (c *RPCClient) GetLastBlockHeight(_ context.Context) (int64, error) {
+	c.chain.mu.RLock()
+	defer c.chain.mu.RUnlock()
+
+	return c.chain.last, nil
+}
+
+func (c *RPCClient) GetBlockResults(_ context.Context, height int64) (*cometbftcoretypes.ResultBlockResults, error) {
+	c.chain.resultsCalls.Add(1)
+
+	h, err := c.chain.height(height)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.Results, nil
+}
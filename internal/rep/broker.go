@@ -10,4 +10,14 @@ type Broker interface {
 	PublishRawTransaction(ctx context.Context, tx interface{}) error
 	PublishRawBlockResults(ctx context.Context, br interface{}) error
 	PublishRawGenesis(ctx context.Context, g interface{}) error
+
+	PublishReorg(ctx context.Context, r interface{}) error
+	PublishFinalizedHeight(ctx context.Context, fh interface{}) error
+	// PublishDeadLetter publishes the failure record immediately, even within the transaction.
+	PublishDeadLetter(ctx context.Context, dl interface{}) error
+
+	// BeginTransaction starts the transaction of the messages published with the returned context.
+	BeginTransaction(ctx context.Context) (context.Context, error)
+	CommitTransaction(ctx context.Context) error
+	AbortTransaction(ctx context.Context) error
 }
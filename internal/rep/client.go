@@ -3,11 +3,10 @@ package rep
 import (
 	"context"
 
+	abci "github.com/cometbft/cometbft/abci/types"
 	cometbftcoretypes "github.com/cometbft/cometbft/rpc/core/types"
 	cometbfttypes "github.com/cometbft/cometbft/types"
 	"github.com/cosmos/cosmos-sdk/types/tx"
-
-	"github.com/bro-n-bro/spacebox-crawler/v2/types"
 )
 
 type (
@@ -15,13 +14,14 @@ type (
 		Block(ctx context.Context, height int64) (*cometbftcoretypes.ResultBlock, error)
 		Validators(ctx context.Context, height int64) (*cometbftcoretypes.ResultValidators, error)
 
-		Txs(ctx context.Context, height int64, txs cometbfttypes.Txs) ([]*tx.GetTxResponse, error)
+		Txs(ctx context.Context, block *cometbftcoretypes.ResultBlock,
+			results []*abci.ResponseDeliverTx) ([]*tx.GetTxResponse, error)
 	}
 
 	RPCClient interface {
 		SubscribeNewBlocks(ctx context.Context) (<-chan cometbftcoretypes.ResultEvent, error)
 		Genesis(ctx context.Context) (*cometbfttypes.GenesisDoc, error)
 		GetLastBlockHeight(ctx context.Context) (int64, error)
-		GetBlockEvents(ctx context.Context, height int64) (begin, end types.BlockerEvents, err error)
+		GetBlockResults(ctx context.Context, height int64) (*cometbftcoretypes.ResultBlockResults, error)
 	}
 )
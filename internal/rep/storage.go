@@ -2,6 +2,7 @@ package rep
 
 import (
 	"context"
+	"time"
 
 	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/model"
 )
@@ -10,10 +11,43 @@ import (
 type Storage interface {
 	GetBlockByHeight(ctx context.Context, height int64) (*model.Block, error)
 	CreateBlock(ctx context.Context, block *model.Block) error
-	SetProcessedStatus(ctx context.Context, height int64) error
-	SetErrorStatus(ctx context.Context, height int64, msg string) error
+	// SetProcessedStatus sets processed status of the block processing by the owner or returns types.ErrLeaseLost.
+	SetProcessedStatus(ctx context.Context, height int64, owner string) error
+	// SetErrorStatus sets error status of the block processing by the owner or returns types.ErrLeaseLost.
+	SetErrorStatus(ctx context.Context, height int64, owner, msg string) error
+	// RollbackBlock sets error status of the block regardless of its owner, so it is processed again.
+	RollbackBlock(ctx context.Context, height int64, msg string) error
 	UpdateStatus(ctx context.Context, height int64, status model.Status) error
-	GetErrorBlockHeights(ctx context.Context) ([]int64, error)
+	GetErrorBlocks(ctx context.Context) ([]*model.Block, error)
+	SetBlockHash(ctx context.Context, height int64, hash, parentHash string) error
+
+	// ClaimBlock atomically creates the block or takes it over for processing by the owner until the lease expires.
+	// Blocks with an expired lease are always claimable, error blocks only if retryError is set.
+	// If the block can't be claimed, types.ErrBlockNotClaimed is returned along with the current block.
+	ClaimBlock(ctx context.Context, height int64, owner string, lease time.Duration, retryError bool) (*model.Block, error)
+	// RenewLease extends the lease of the block held by the owner or returns types.ErrLeaseLost.
+	RenewLease(ctx context.Context, height int64, owner string, lease time.Duration) error
+	// ReleaseExpiredBlocks sets error status for processing blocks with an expired lease.
+	ReleaseExpiredBlocks(ctx context.Context) error
+
+	// GetCompletedHeights returns up to limit processed or skipped heights greater than the given one
+	// in ascending order.
+	GetCompletedHeights(ctx context.Context, after, limit int64) ([]int64, error)
+	// GetStoredHeights returns up to limit heights of any status greater than the given one in ascending order.
+	GetStoredHeights(ctx context.Context, after, limit int64) ([]int64, error)
+	// GetFinalizedHeight returns the stored finalized height or types.ErrFinalizedHeightNotFound.
+	GetFinalizedHeight(ctx context.Context) (int64, error)
+	SetFinalizedHeight(ctx context.Context, height int64) error
+
+	CreateBackfillJob(ctx context.Context, job *model.BackfillJob) error
+	// GetBackfillJob returns the job or types.ErrBackfillJobNotFound.
+	GetBackfillJob(ctx context.Context, id string) (*model.BackfillJob, error)
+	// GetBackfillJobs returns all jobs in the creation order.
+	GetBackfillJobs(ctx context.Context) ([]*model.BackfillJob, error)
+	// SetBackfillJobProgress moves the next height of the job forward and finishes it after the last height.
+	SetBackfillJobProgress(ctx context.Context, id string, next int64) error
+	// SetBackfillJobStatus sets the status of the job or returns types.ErrBackfillJobDone if it is finished or cancelled.
+	SetBackfillJobStatus(ctx context.Context, id string, status model.BackfillJobStatus) error
 
 	InsertErrorTx(ctx context.Context, message model.Tx) error
 	InsertErrorMessage(ctx context.Context, message model.Message) error
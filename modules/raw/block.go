@@ -3,6 +3,7 @@ package raw
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -36,13 +37,14 @@ func (m *Module) HandleBlock(ctx context.Context, block *types.Block) error {
 		return fmt.Errorf("failed to publish raw block: %w", err)
 	}
 
-	return m.publishBlockResults(ctx, block.Height, block.Timestamp)
+	return m.publishBlockResults(ctx, block.RawResults(), block.Timestamp)
 }
 
-func (m *Module) publishBlockResults(ctx context.Context, height int64, timestamp time.Time) error {
-	brResp, err := m.rpcClient.GetBlockResults(ctx, height)
-	if err != nil {
-		return fmt.Errorf("failed to get block results: %w", err)
+func (m *Module) publishBlockResults(ctx context.Context, brResp *coretypes.ResultBlockResults,
+	timestamp time.Time) error {
+
+	if brResp == nil {
+		return errors.New("block results are missing")
 	}
 
 	rawBR := struct {
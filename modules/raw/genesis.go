@@ -9,15 +9,20 @@ import (
 	jsoniter "github.com/json-iterator/go"
 )
 
+// rawGenesis is the genesis message keyed by the chain id.
+type rawGenesis struct {
+	GenesisTime     string          `json:"genesis_time"`
+	ChainID         string          `json:"chain_id"`
+	AppHash         string          `json:"app_hash"`
+	ConsensusParams json.RawMessage `json:"consensus_params"`
+	AppState        json.RawMessage `json:"app_state"`
+	InitialHeight   int64           `json:"initial_height"`
+}
+
+func (g rawGenesis) RecordKey() string { return g.ChainID }
+
 func (m *Module) HandleGenesis(ctx context.Context, doc *cometbfttypes.GenesisDoc, _ map[string]json.RawMessage) error {
-	rawGenesis := struct {
-		GenesisTime     string          `json:"genesis_time"`
-		ChainID         string          `json:"chain_id"`
-		AppHash         string          `json:"app_hash"`
-		ConsensusParams json.RawMessage `json:"consensus_params"`
-		AppState        json.RawMessage `json:"app_state"`
-		InitialHeight   int64           `json:"initial_height"`
-	}{
+	genesis := rawGenesis{
 		GenesisTime:   doc.GenesisTime.String(),
 		ChainID:       doc.ChainID,
 		InitialHeight: doc.InitialHeight,
@@ -26,10 +31,10 @@ func (m *Module) HandleGenesis(ctx context.Context, doc *cometbfttypes.GenesisDo
 	}
 
 	var err error
-	rawGenesis.ConsensusParams, err = jsoniter.Marshal(doc.ConsensusParams)
+	genesis.ConsensusParams, err = jsoniter.Marshal(doc.ConsensusParams)
 	if err != nil {
 		return fmt.Errorf("failed to marshal consensus params: %w", err)
 	}
 
-	return m.broker.PublishRawGenesis(ctx, rawGenesis)
+	return m.broker.PublishRawGenesis(ctx, genesis)
 }
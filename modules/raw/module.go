@@ -3,7 +3,6 @@ package raw
 import (
 	"github.com/rs/zerolog"
 
-	rpcClient "github.com/bro-n-bro/spacebox-crawler/v2/client/rpc"
 	"github.com/bro-n-bro/spacebox-crawler/v2/modules/utils"
 	"github.com/bro-n-bro/spacebox-crawler/v2/types"
 )
@@ -18,16 +17,14 @@ var (
 )
 
 type Module struct {
-	log       *zerolog.Logger
-	rpcClient *rpcClient.Client
-	broker    broker
+	log    *zerolog.Logger
+	broker broker
 }
 
-func New(b broker, cli *rpcClient.Client) *Module {
+func New(b broker) *Module {
 	return &Module{
-		log:       utils.NewModuleLogger(ModuleName),
-		broker:    b,
-		rpcClient: cli,
+		log:    utils.NewModuleLogger(ModuleName),
+		broker: b,
 	}
 }
 
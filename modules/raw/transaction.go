@@ -24,11 +24,19 @@ var (
 	}
 )
 
+// rawTransaction is the transaction message keyed by the transaction hash.
+type rawTransaction struct {
+	hash string
+
+	Signer     string          `json:"signer"`
+	TxResponse json.RawMessage `json:"tx_response"`
+}
+
+func (t rawTransaction) RecordKey() string { return t.hash }
+
 func (m *Module) HandleTx(ctx context.Context, tx *types.Tx) error {
-	rawTx := struct {
-		Signer     string          `json:"signer"`
-		TxResponse json.RawMessage `json:"tx_response"`
-	}{
+	rawTx := rawTransaction{
+		hash:   tx.TxHash,
 		Signer: tx.Signer,
 	}
 
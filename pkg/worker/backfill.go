@@ -0,0 +1,167 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/model"
+)
+
+// runBackfillJobs enqueues the heights of the active backfill jobs in batches until ctx is done.
+// The job with the highest priority is taken for every batch, so a new urgent job preempts the running one.
+// The stored progress moves only over the completed heights, so the enqueued but not processed heights
+// are enqueued again after restart and the job is finished once all its heights are completed.
+func (w *Worker) runBackfillJobs(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(w.cfg.BackfillInterval)
+	defer ticker.Stop()
+
+	// enqueued is the next height to enqueue by the job id
+	enqueued := make(map[string]int64)
+
+	for {
+		job, err := w.nextBackfillJob(ctx, enqueued)
+		if err != nil && ctx.Err() == nil {
+			w.log.Error().Err(err).Msg("can't get backfill jobs")
+		}
+
+		// enqueue the next batch right away while there are jobs with heights to enqueue
+		if job != nil && w.enqueueBackfillBatch(ctx, job, enqueued) {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			w.log.Info().Msg("stop backfill jobs")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// nextBackfillJob updates the progress of the active jobs and returns the job with heights to enqueue
+// with the highest priority, the oldest one among equal priorities.
+func (w *Worker) nextBackfillJob(ctx context.Context, enqueued map[string]int64) (*model.BackfillJob, error) {
+	jobs, err := w.storage.GetBackfillJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		next    *model.BackfillJob
+		pending int64
+		active  = make(map[string]struct{}, len(jobs))
+	)
+
+	for _, job := range jobs {
+		if job.Status.IsDone() {
+			continue
+		}
+
+		// paused jobs keep the enqueued height until they are resumed
+		active[job.ID] = struct{}{}
+
+		if !job.Status.IsActive() {
+			continue
+		}
+
+		if err = w.advanceBackfillJob(ctx, job); err != nil {
+			return nil, err
+		}
+
+		if job.Next > job.To {
+			continue
+		}
+
+		pending += job.To - job.Next + 1
+
+		if enqueued[job.ID] < job.Next {
+			enqueued[job.ID] = job.Next
+		}
+
+		if enqueued[job.ID] <= job.To && (next == nil || job.Priority > next.Priority) {
+			next = job
+		}
+	}
+
+	for id := range enqueued {
+		if _, ok := active[id]; !ok {
+			delete(enqueued, id)
+		}
+	}
+
+	if w.metrics != nil {
+		w.metrics.backfillPending.Set(float64(pending))
+	}
+
+	return next, nil
+}
+
+// advanceBackfillJob moves the stored progress of the job over its contiguous completed heights.
+// The job is finished by storage when its last height is completed.
+func (w *Worker) advanceBackfillJob(ctx context.Context, job *model.BackfillJob) error {
+	next := job.Next
+
+	for next <= job.To {
+		heights, err := w.storage.GetCompletedHeights(ctx, next-1, w.cfg.BackfillBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to get completed heights: %w", err)
+		}
+
+		for _, h := range heights {
+			if h != next {
+				break
+			}
+			next++
+		}
+
+		if int64(len(heights)) < w.cfg.BackfillBatchSize || next-1 != heights[len(heights)-1] {
+			break
+		}
+	}
+
+	if next > job.To {
+		next = job.To + 1
+	}
+
+	if next == job.Next {
+		return nil
+	}
+
+	if err := w.storage.SetBackfillJobProgress(ctx, job.ID, next); err != nil {
+		return fmt.Errorf("failed to set backfill job progress: %w", err)
+	}
+
+	job.Next = next
+
+	if next > job.To {
+		w.log.Info().Str("job_id", job.ID).Int64("from", job.From).Int64("to", job.To).Msg("backfill job finished")
+	}
+
+	return nil
+}
+
+// enqueueBackfillBatch enqueues the next batch of the job heights.
+// It returns false if the batch is not fully enqueued.
+func (w *Worker) enqueueBackfillBatch(ctx context.Context, job *model.BackfillJob, enqueued map[string]int64) bool {
+	from := enqueued[job.ID]
+
+	to := from + w.cfg.BackfillBatchSize - 1
+	if to > job.To {
+		to = job.To
+	}
+
+	for height := from; height <= to; height++ {
+		if !w.scheduler.push(ctx, classBackfill, height) {
+			enqueued[job.ID] = height
+			return false
+		}
+	}
+
+	enqueued[job.ID] = to + 1
+
+	return true
+}
@@ -4,12 +4,32 @@ import "time"
 
 type Config struct {
 	ProcessErrorBlocksInterval time.Duration `env:"PROCESS_ERROR_BLOCKS_INTERVAL" envDefault:"1m"`
+	LeaseDuration              time.Duration `env:"HEIGHT_LEASE_DURATION" envDefault:"1m"`
+	FinalizedHeightInterval    time.Duration `env:"FINALIZED_HEIGHT_INTERVAL" envDefault:"10s"`
+	RetryBackoff               time.Duration `env:"PROCESS_ERROR_BLOCKS_BACKOFF" envDefault:"1m"`
+	RetryMaxBackoff            time.Duration `env:"PROCESS_ERROR_BLOCKS_MAX_BACKOFF" envDefault:"6h"`
+	BackfillInterval           time.Duration `env:"BACKFILL_INTERVAL" envDefault:"10s"`
+	GapScanInterval            time.Duration `env:"GAP_SCAN_INTERVAL" envDefault:"5m"`
+	SubscribeBackoff           time.Duration `env:"SUBSCRIBE_NEW_BLOCKS_BACKOFF" envDefault:"1s"`
+	SubscribeMaxBackoff        time.Duration `env:"SUBSCRIBE_NEW_BLOCKS_MAX_BACKOFF" envDefault:"1m"`
+	PollNewBlocksInterval      time.Duration `env:"POLL_NEW_BLOCKS_INTERVAL" envDefault:"5s"`
+	OwnerID                    string        `env:"CRAWLER_ID"`           // unique replica id, generated if empty
 	ProcessNewBlocks           bool          `env:"SUBSCRIBE_NEW_BLOCKS"` // FIXME: or use ws enabled???
+	PollNewBlocks              bool          `env:"POLL_NEW_BLOCKS"`      // poll the last block height instead of websocket
 	ProcessErrorBlocks         bool          `env:"PROCESS_ERROR_BLOCKS" envDefault:"true"`
 	MetricsEnabled             bool          `env:"METRICS_ENABLED" envDefault:"false"`
 	RecoveryMode               bool          `env:"RECOVERY_MODE" envDefault:"false"`
 	ProcessGenesis             bool          `env:"PROCESS_GENESIS" envDefault:"true"`
+	DetectReorgs               bool          `env:"DETECT_REORGS" envDefault:"false"`
+	TrackFinalizedHeight       bool          `env:"TRACK_FINALIZED_HEIGHT" envDefault:"false"`
+	OrderedDelivery            bool          `env:"ORDERED_DELIVERY" envDefault:"false"`
+	BackfillEnabled            bool          `env:"BACKFILL_ENABLED" envDefault:"false"`
+	GapScanEnabled             bool          `env:"GAP_SCAN_ENABLED" envDefault:"false"`
 	WorkersCount               int           `env:"WORKERS_COUNT" envDefault:"1"`
+	OrderedBufferSize          int64         `env:"ORDERED_BUFFER_SIZE" envDefault:"1000"`
+	RetryWorkersShare          float64       `env:"RETRY_WORKERS_SHARE" envDefault:"0.1"` // share of workers reserved for retries
+	BackfillBatchSize          int64         `env:"BACKFILL_BATCH_SIZE" envDefault:"100"`
+	MaxAttempts                int           `env:"PROCESS_ERROR_BLOCKS_MAX_ATTEMPTS" envDefault:"10"` // 0 is unlimited
 	StartHeight                int64         `env:"START_HEIGHT" envDefault:"-1"`
 	StopHeight                 int64         `env:"STOP_HEIGHT"`
 }
@@ -0,0 +1,195 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/types"
+)
+
+// finalizedBatchSize is the max count of completed heights read from storage at once.
+const finalizedBatchSize = 1000
+
+// finalizedTracker holds the highest height H such that all heights from the start height up to H are processed
+// or skipped.
+type finalizedTracker struct {
+	metric prometheus.Gauge
+
+	mu     sync.Mutex
+	height int64
+	// failed is the last reported failed height which blocks the finalized height
+	failed int64
+	loaded bool
+}
+
+// trackFinalizedHeight periodically advances the finalized height and publishes it.
+func (w *Worker) trackFinalizedHeight(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.FinalizedHeightInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.log.Info().Msg("stop trackFinalizedHeight")
+			return
+		case <-ticker.C:
+			height, err := w.advanceFinalizedHeight(ctx)
+			if err != nil {
+				w.log.Error().Err(err).Msg("can't advance finalized height")
+				continue
+			}
+
+			if height < 0 {
+				continue
+			}
+
+			if err = w.broker.PublishFinalizedHeight(ctx, types.FinalizedHeight{
+				Height:    height,
+				Timestamp: time.Now(),
+			}); err != nil {
+				w.log.Error().Err(err).Int64(keyHeight, height).Msg("can't publish finalized height")
+			}
+		}
+	}
+}
+
+// advanceFinalizedHeight moves the finalized height over the contiguous completed heights and stores it.
+// Returns -1 if nothing is processed yet.
+// A failed height stops the finalized height until it is reprocessed or skipped, so it is reported once.
+func (w *Worker) advanceFinalizedHeight(ctx context.Context) (int64, error) {
+	t := &w.finalized
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.loaded {
+		height, err := w.initialFinalizedHeight(ctx)
+		if err != nil {
+			return 0, err
+		}
+
+		t.height, t.loaded = height, true
+	}
+
+	height := t.height
+
+	for {
+		heights, err := w.storage.GetCompletedHeights(ctx, height, finalizedBatchSize)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get completed heights: %w", err)
+		}
+
+		for _, h := range heights {
+			if h != height+1 {
+				break
+			}
+			height = h
+		}
+
+		if len(heights) < finalizedBatchSize || height != heights[len(heights)-1] {
+			break
+		}
+	}
+
+	if height != t.height {
+		if err := w.storage.SetFinalizedHeight(ctx, height); err != nil {
+			return 0, fmt.Errorf("failed to set finalized height: %w", err)
+		}
+
+		t.height = height
+	}
+
+	if t.metric != nil {
+		t.metric.Set(float64(t.height))
+	}
+
+	w.reportFailedHeight(ctx, t.height+1)
+
+	return t.height, nil
+}
+
+// reportFailedHeight logs the height next to the finalized one if it failed after the max attempts.
+// Must be called with the tracker lock held.
+func (w *Worker) reportFailedHeight(ctx context.Context, height int64) {
+	t := &w.finalized
+	if t.failed == height {
+		return
+	}
+
+	block, err := w.storage.GetBlockByHeight(ctx, height)
+	if err != nil || !block.Status.IsFailed() {
+		return
+	}
+
+	t.failed = height
+	w.log.Warn().Int64(keyHeight, height).Int64("finalized_height", t.height).
+		Msg("finalized height is blocked by the failed height, reprocess or skip it through the admin api")
+}
+
+// initialFinalizedHeight returns the stored finalized height or the height before the first one to process.
+func (w *Worker) initialFinalizedHeight(ctx context.Context) (int64, error) {
+	height, err := w.storage.GetFinalizedHeight(ctx)
+	if err == nil {
+		return height, nil
+	}
+
+	if !errors.Is(err, types.ErrFinalizedHeightNotFound) {
+		return 0, fmt.Errorf("failed to get finalized height: %w", err)
+	}
+
+	if w.cfg.StartHeight >= 0 {
+		return w.cfg.StartHeight - 1, nil
+	}
+
+	// start height is not set: start from the lowest completed height
+	heights, err := w.storage.GetCompletedHeights(ctx, -1, 1)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get completed heights: %w", err)
+	}
+
+	if len(heights) == 0 {
+		return -1, nil
+	}
+
+	return heights[0] - 1, nil
+}
+
+// lowerFinalizedHeight moves the finalized height below the rolled back height.
+func (w *Worker) lowerFinalizedHeight(ctx context.Context, height int64) error {
+	if !w.cfg.TrackFinalizedHeight {
+		return nil
+	}
+
+	t := &w.finalized
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.loaded && height > t.height {
+		return nil
+	}
+
+	stored, err := w.storage.GetFinalizedHeight(ctx)
+	switch {
+	case errors.Is(err, types.ErrFinalizedHeightNotFound):
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to get finalized height: %w", err)
+	case height > stored:
+		t.height, t.loaded = stored, true
+		return nil
+	}
+
+	if err = w.storage.SetFinalizedHeight(ctx, height-1); err != nil {
+		return fmt.Errorf("failed to set finalized height: %w", err)
+	}
+
+	t.height, t.loaded = height-1, true
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// gapScanBatchSize is the max count of stored heights read from storage at once.
+const gapScanBatchSize = 1000
+
+// runGapScanner periodically enqueues the heights missing from storage, e.g. the new blocks dropped by the websocket
+// subscription or produced while the crawler was down, until ctx is done.
+func (w *Worker) runGapScanner(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(w.cfg.GapScanInterval)
+	defer ticker.Stop()
+
+	// all heights from the start height up to the contiguous one are stored, so they are not scanned again
+	contiguous := int64(-1)
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.log.Info().Msg("stop gap scanner")
+			return
+		case <-ticker.C:
+			var err error
+			if contiguous, err = w.scanGaps(ctx, contiguous); err != nil && ctx.Err() == nil {
+				w.log.Error().Err(err).Msg("can't scan gaps")
+			}
+		}
+	}
+}
+
+// scanGaps enqueues the heights absent from storage between the start height and the tip.
+// The scan begins after the contiguous height and the new contiguous height is returned.
+// The heights of the configured range which are not enqueued yet are not scanned.
+func (w *Worker) scanGaps(ctx context.Context, contiguous int64) (int64, error) {
+	to, err := w.rpcClient.GetLastBlockHeight(ctx)
+	if err != nil {
+		return contiguous, fmt.Errorf("failed to get last block height: %w", err)
+	}
+
+	if w.cfg.StopHeight > 0 && w.cfg.StopHeight < to {
+		to = w.cfg.StopHeight
+	}
+
+	if !w.rangeDone.Load() && w.rangeEnqueued.Load() < to {
+		to = w.rangeEnqueued.Load()
+	}
+
+	from := w.cfg.StartHeight
+	if from < 0 {
+		// start height is not set: start from the lowest stored height
+		heights, err := w.storage.GetStoredHeights(ctx, -1, 1)
+		if err != nil {
+			return contiguous, fmt.Errorf("failed to get stored heights: %w", err)
+		}
+
+		if len(heights) == 0 {
+			return contiguous, nil
+		}
+
+		from = heights[0]
+	}
+
+	if contiguous < from-1 {
+		contiguous = from - 1
+	}
+
+	var (
+		missing  int64
+		gapFound bool
+		next     = contiguous + 1 // the lowest height not known to be stored or missing
+	)
+
+	enqueue := func(from, to int64) error {
+		if from > to {
+			return nil
+		}
+
+		for height := from; height <= to; height++ {
+			if !w.scheduler.push(ctx, classBackfill, height) {
+				return ctx.Err()
+			}
+		}
+
+		missing += to - from + 1
+		gapFound = true
+
+		return nil
+	}
+
+	for next <= to {
+		heights, err := w.storage.GetStoredHeights(ctx, next-1, gapScanBatchSize)
+		if err != nil {
+			return contiguous, fmt.Errorf("failed to get stored heights: %w", err)
+		}
+
+		for _, height := range heights {
+			if height > to {
+				break
+			}
+
+			if err = enqueue(next, height-1); err != nil {
+				return contiguous, err
+			}
+
+			if !gapFound {
+				contiguous = height
+			}
+
+			next = height + 1
+		}
+
+		if len(heights) < gapScanBatchSize || heights[len(heights)-1] >= to {
+			break
+		}
+	}
+
+	if err = enqueue(next, to); err != nil {
+		return contiguous, err
+	}
+
+	if missing > 0 {
+		w.log.Info().Int64("count", missing).Msg("enqueued missing heights")
+	}
+
+	if w.metrics != nil {
+		w.metrics.missingHeights.Set(float64(missing))
+	}
+
+	return contiguous, nil
+}
@@ -2,7 +2,7 @@ package worker
 
 import "github.com/bro-n-bro/spacebox-crawler/v2/types"
 
-var (
+type handlers struct {
 	transactionHandlers       []types.TransactionHandler
 	blockHandlers             []types.BlockHandler
 	genesisHandlers           []types.GenesisHandler
@@ -11,34 +11,34 @@ var (
 	beginBlockerHandlers      []types.BeginBlockerHandler
 	endBlockerHandlers        []types.EndBlockerHandler
 	recursiveMessagesHandlers []types.RecursiveMessagesHandler
-)
+}
 
 // fillModules fills the module handlers.
 func (w *Worker) fillModules() {
 	for _, module := range w.modules {
 		if tI, ok := module.(types.TransactionHandler); ok {
-			transactionHandlers = append(transactionHandlers, tI)
+			w.transactionHandlers = append(w.transactionHandlers, tI)
 		}
 		if bI, ok := module.(types.BlockHandler); ok {
-			blockHandlers = append(blockHandlers, bI)
+			w.blockHandlers = append(w.blockHandlers, bI)
 		}
 		if gI, ok := module.(types.GenesisHandler); ok {
-			genesisHandlers = append(genesisHandlers, gI)
+			w.genesisHandlers = append(w.genesisHandlers, gI)
 		}
 		if mI, ok := module.(types.MessageHandler); ok {
-			messageHandlers = append(messageHandlers, mI)
+			w.messageHandlers = append(w.messageHandlers, mI)
 		}
 		if vI, ok := module.(types.ValidatorsHandler); ok {
-			validatorsHandlers = append(validatorsHandlers, vI)
+			w.validatorsHandlers = append(w.validatorsHandlers, vI)
 		}
 		if bbI, ok := module.(types.BeginBlockerHandler); ok {
-			beginBlockerHandlers = append(beginBlockerHandlers, bbI)
+			w.beginBlockerHandlers = append(w.beginBlockerHandlers, bbI)
 		}
 		if ebI, ok := module.(types.EndBlockerHandler); ok {
-			endBlockerHandlers = append(endBlockerHandlers, ebI)
+			w.endBlockerHandlers = append(w.endBlockerHandlers, ebI)
 		}
 		if rmI, ok := module.(types.RecursiveMessagesHandler); ok {
-			recursiveMessagesHandlers = append(recursiveMessagesHandlers, rmI)
+			w.recursiveMessagesHandlers = append(w.recursiveMessagesHandlers, rmI)
 		}
 	}
 }
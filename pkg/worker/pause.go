@@ -0,0 +1,83 @@
+package worker
+
+import (
+	"context"
+	"sync"
+)
+
+// pauser holds the workers before processing of the next height while paused.
+type pauser struct {
+	// resumed is closed on resume, nil if not paused
+	resumed chan struct{}
+	mu      sync.Mutex
+}
+
+func (p *pauser) pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.resumed == nil {
+		p.resumed = make(chan struct{})
+	}
+}
+
+func (p *pauser) resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.resumed != nil {
+		close(p.resumed)
+		p.resumed = nil
+	}
+}
+
+func (p *pauser) paused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.resumed != nil
+}
+
+// wait blocks until resume if paused or ctx is done.
+func (p *pauser) wait(ctx context.Context) {
+	p.mu.Lock()
+	resumed := p.resumed
+	p.mu.Unlock()
+
+	if resumed == nil {
+		return
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-resumed:
+	}
+}
+
+// Pause stops processing of the new heights. Heights in progress are finished.
+func (w *Worker) Pause() {
+	w.pauser.pause()
+	w.log.Info().Msg("worker paused")
+}
+
+// Resume continues processing of the heights after Pause.
+func (w *Worker) Resume() {
+	w.pauser.resume()
+	w.log.Info().Msg("worker resumed")
+}
+
+// Paused checks whether processing is paused.
+func (w *Worker) Paused() bool { return w.pauser.paused() }
+
+// Reprocess enqueues the height range for processing in background.
+// The stored blocks of the range must be deleted before, so the heights can be claimed again.
+func (w *Worker) Reprocess(from, to int64) {
+	if w.enqueueCtx == nil || w.enqueueCtx.Err() != nil {
+		return
+	}
+
+	w.log.Info().Int64("from", from).Int64("to", to).Msg("reprocess heights")
+
+	w.enqueueWg.Add(1)
+	go w.enqueueRange(w.enqueueCtx, w.enqueueWg, from, to)
+}
@@ -27,14 +27,19 @@ var (
 	errRecurringHandling = errors.New("cant handle recurring messages")
 )
 
-func (w *Worker) process(ctx context.Context, workerIndex int, recoverMode bool) {
+func (w *Worker) process(ctx context.Context, workerIndex int, order []heightClass, recoverMode bool) {
 	var parsedCount int
 	defer w.wg.Done()
 	defer func() {
 		w.log.Debug().Msgf("worker: %d. parsed %d blocks", workerIndex, parsedCount)
 	}()
 
-	for height := range w.heightCh {
+	for {
+		height, ok := w.scheduler.next(order)
+		if !ok {
+			return
+		}
+
 		select {
 		case <-ctx.Done():
 			w.log.Info().Int("worker_index", workerIndex).Msg("done worker")
@@ -42,6 +47,9 @@ func (w *Worker) process(ctx context.Context, workerIndex int, recoverMode bool)
 		default:
 		}
 
+		// hold the height while processing is paused
+		w.pauser.wait(ctx)
+
 		// for debug
 		parsedCount++
 
@@ -49,129 +57,179 @@ func (w *Worker) process(ctx context.Context, workerIndex int, recoverMode bool)
 	}
 }
 
-func (w *Worker) processHeight(ctx context.Context, workerIndex int, height int64, recoveryMode bool) { // nolint:gocognit
+func (w *Worker) processHeight(ctx context.Context, workerIndex int, height int64, recoveryMode bool) {
+	var attempt int
+
 	if recoveryMode {
 		defer func() {
 			if r := recover(); r != nil {
-				w.setErrorStatusWithLogging(ctx, height, fmt.Sprint(r))
+				err := newStageError(types.StagePanic, "", errors.New(fmt.Sprint(r)))
+				w.setErrorStatusWithLogging(ctx, height, attempt, err)
 				w.log.Error().Int64("height", height).Msgf("panic occurred!\n%v", r)
 			}
 		}()
 	}
 
-	if err := w.checkOrCreateBlockInStorage(ctx, height); err != nil {
+	block, err := w.claimBlock(ctx, height)
+	if err != nil {
+		// ordered delivery doesn't wait for the heights which are not processed by this replica
 		switch {
 		case errors.Is(err, ErrBlockProcessed):
 			w.log.Debug().Int64(keyHeight, height).Msg("block already processed. skip height")
+			w.sequencer.skip(height)
 		case errors.Is(err, ErrBlockProcessing):
 			w.log.Debug().Int64(keyHeight, height).Msg("block is already processing now. skip height")
+			// the worker of this replica processing the height publishes it in order
+			if block.Owner != w.owner {
+				w.sequencer.skip(height)
+			}
+		case errors.Is(err, ErrBlockFailed):
+			w.log.Debug().Int64(keyHeight, height).Msg("block failed after max attempts. skip height")
+			w.sequencer.skip(height)
+		case errors.Is(err, ErrBlockSkipped):
+			w.log.Debug().Int64(keyHeight, height).Msg("block skipped by the operator. skip height")
+			w.sequencer.skip(height)
 		case errors.Is(err, ErrBlockError):
 			w.log.Debug().Int64(keyHeight, height).Msg("block processed with error. " +
 				"if you want to process this height again see PROCESS_ERROR_BLOCKS ENV")
+			w.sequencer.skip(height)
+		default:
+			w.log.Error().Err(err).Int64(keyHeight, height).Msg("can't claim block in storage")
 		}
 
 		return
 	}
 
-	if height == 0 {
-		w.log.Info().Int("worker_number", workerIndex).Msg("Parse genesis")
+	attempt = block.Attempts
 
-		_genesisDur := time.Now()
+	leaseCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-		genesis, err := w.rpcClient.Genesis(ctx)
-		if err != nil {
-			w.setErrorStatusWithLogging(ctx, height, err.Error())
-			w.log.Error().Err(err).Msg("get genesis error")
-			return
-		}
+	lease := w.keepLease(leaseCtx, cancel, height)
 
-		w.log.Debug().Int("worker_number", workerIndex).
-			Dur("duration", time.Since(_genesisDur)).
-			Msg("get genesis")
+	// the height is sequenced only by the worker which claimed it
+	seqCtx, err := w.sequencer.begin(leaseCtx, height)
+	if err == nil {
+		defer w.sequencer.end(seqCtx)
 
-		if err = w.processGenesis(ctx, genesis); err != nil {
-			w.log.Error().Err(err).Msg("processHeight genesis error")
-			w.setErrorStatusWithLogging(ctx, height, err.Error())
-			return
-		}
+		err = w.processInTransaction(seqCtx, workerIndex, height, attempt)
+	} else {
+		err = fmt.Errorf("failed to begin ordered delivery: %w", err)
+	}
+	cancel()
 
-		if err = w.storage.SetProcessedStatus(ctx, height); err != nil {
-			w.log.Error().Err(err).Int64(keyHeight, height).Msg("can't set processed status in storage")
-		}
+	if lease.Load() {
+		w.log.Warn().Int64(keyHeight, height).Msg("block lease is lost. skip status update")
+		return
+	}
 
+	if err != nil {
+		w.setErrorStatusWithLogging(ctx, height, attempt, err)
 		return
 	}
 
-	w.log.Info().Int("worker_number", workerIndex).Int64("height", height).Msg("parse block")
+	switch err = w.storage.SetProcessedStatus(ctx, height, w.owner); {
+	case errors.Is(err, types.ErrLeaseLost):
+		w.log.Warn().Int64(keyHeight, height).Msg("block lease is lost. skip processed status")
+	case err != nil:
+		w.log.Error().Err(err).Int64(keyHeight, height).Msg("can't set processed status in storage")
+	}
+}
 
-	g, ctx2 := errgroup.WithContext(ctx)
+// processInTransaction processes the height and publishes its messages within the broker transaction,
+// so they are published all at once or not at all. The transaction is open only while the buffered messages
+// are published, so the workers waiting for the lower heights in ordered delivery mode don't hold it.
+func (w *Worker) processInTransaction(ctx context.Context, workerIndex int, height int64, attempt int) error {
+	if err := w.processClaimedHeight(ctx, workerIndex, height, attempt); err != nil {
+		return err
+	}
 
-	var (
-		block                            *cometbftcoreypes.ResultBlock
-		vals                             *cometbftcoreypes.ResultValidators
-		beginBlockEvents, endBlockEvents types.BlockerEvents
-	)
+	// publish the buffered messages, after all lower heights in ordered delivery mode
+	return w.sequencer.commit(ctx, func(ctx context.Context, messages []publishFunc) error {
+		return w.publishInTransaction(ctx, height, messages)
+	})
+}
 
-	g.Go(func() error {
-		var err error
+// publishInTransaction publishes the messages of the height within the broker transaction.
+func (w *Worker) publishInTransaction(ctx context.Context, height int64, messages []publishFunc) error {
+	txCtx, err := w.broker.BeginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin broker transaction: %w", err)
+	}
 
-		_blockDur := time.Now()
-		if block, err = w.grpcClient.Block(ctx2, height); err != nil {
-			return fmt.Errorf("failed to get block: %w", err)
+	for _, fn := range messages {
+		if err = fn(txCtx); err != nil {
+			break
 		}
-		w.log.Debug().
-			Int("worker_number", workerIndex).
-			Int64("block_height", height).
-			Dur("get_block_dur", time.Since(_blockDur)).
-			Msg("get block info")
-		return nil
-	})
+	}
 
-	g.Go(func() error {
-		var err error
-		_validatorsDur := time.Now()
-		if vals, err = w.grpcClient.Validators(ctx2, height); err != nil {
-			return fmt.Errorf("failed to get validators: %w", err)
+	if err == nil {
+		if err = w.broker.CommitTransaction(txCtx); err != nil {
+			return fmt.Errorf("failed to commit broker transaction: %w", err)
 		}
-		w.log.Debug().
-			Int("worker_number", workerIndex).
-			Int64("block_height", height).
-			Dur("get_validators_dur", time.Since(_validatorsDur)).
-			Msg("Get validators info")
 
 		return nil
-	})
+	}
 
-	g.Go(func() error {
-		var err error
-		_blockEventsDur := time.Now()
-		beginBlockEvents, endBlockEvents, err = w.rpcClient.GetBlockEvents(ctx2, height)
+	// the context may be canceled due to the lost lease
+	if abortErr := w.broker.AbortTransaction(context.WithoutCancel(txCtx)); abortErr != nil {
+		w.log.Error().Err(abortErr).Int64(keyHeight, height).Msg("can't abort broker transaction")
+	}
+
+	return err
+}
+
+// processClaimedHeight fetches and handles all data of the height.
+func (w *Worker) processClaimedHeight(ctx context.Context, workerIndex int, height int64, attempt int) error {
+	if height == 0 {
+		w.log.Info().Int("worker_number", workerIndex).Msg("Parse genesis")
+
+		_genesisDur := time.Now()
+
+		genesis, err := w.rpcClient.Genesis(ctx)
 		if err != nil {
-			return fmt.Errorf("failed to get block events: %w", err)
+			w.log.Error().Err(err).Msg("get genesis error")
+			return newStageError(types.StageGenesis, "", err)
 		}
 
-		w.log.Debug().
-			Int("worker_number", workerIndex).
-			Int64("block_height", height).
-			Dur("get_block_events_dur", time.Since(_blockEventsDur)).
-			Msg("get validators info")
+		w.log.Debug().Int("worker_number", workerIndex).
+			Dur("duration", time.Since(_genesisDur)).
+			Msg("get genesis")
+
+		ctx = types.WithOrigin(ctx, types.Origin{ChainID: genesis.ChainID, Height: height, Attempt: attempt})
+
+		if err = w.processGenesis(ctx, genesis); err != nil {
+			w.log.Error().Err(err).Msg("processHeight genesis error")
+			return err
+		}
 
 		return nil
-	})
+	}
 
-	if err := g.Wait(); err != nil {
+	w.log.Info().Int("worker_number", workerIndex).Int64("height", height).Msg("parse block")
+
+	data, err := w.fetchHeight(ctx, workerIndex, height)
+	if err != nil {
 		w.log.Error().Int64(keyHeight, height).Err(err).Msg("processHeight error")
-		w.setErrorStatusWithLogging(ctx, height, err.Error())
-		return
+		return err
+	}
+
+	block := data.Block
+	beginBlockEvents, endBlockEvents := data.BlockerEvents()
+
+	ctx = types.WithOrigin(ctx, types.Origin{ChainID: block.Block.ChainID, Height: height, Attempt: attempt})
+
+	if err = w.checkContinuity(ctx, block); err != nil {
+		w.log.Error().Int64(keyHeight, height).Err(err).Msg("check continuity error")
+		return newStageError(types.StageContinuity, "", err)
 	}
 
 	_txsDur := time.Now()
 
-	txsRes, err := w.grpcClient.Txs(ctx, height, block.Block.Data.Txs)
+	txsRes, err := w.grpcClient.Txs(ctx, block, data.Results.TxsResults)
 	if err != nil {
 		w.log.Error().Err(err).Msg("get txs error")
-		w.setErrorStatusWithLogging(ctx, height, err.Error())
-		return
+		return newStageError(types.StageTxs, "", err)
 	}
 
 	w.log.Debug().
@@ -181,16 +239,16 @@ func (w *Worker) processHeight(ctx context.Context, workerIndex int, height int6
 		Msg("Get txs info")
 
 	txs := types.NewTxsFromTmTxs(txsRes, w.cdc)
-	g, ctx2 = errgroup.WithContext(ctx)
+	g, ctx2 := errgroup.WithContext(ctx)
 
 	g.Go(func() error {
 		return w.withMetrics("validators", func() error {
-			return w.processValidators(ctx2, height, vals)
+			return w.processValidators(ctx2, height, data.Validators)
 		})
 	})
 	g.Go(func() error {
 		return w.withMetrics("block", func() error {
-			return w.processBlock(ctx2, types.NewBlockFromTmBlock(block, txs.TotalGas()))
+			return w.processBlock(ctx2, types.NewBlockFromHeightData(data, txs.TotalGas()))
 		})
 	})
 	g.Go(func() error {
@@ -214,14 +272,67 @@ func (w *Worker) processHeight(ctx context.Context, workerIndex int, height int6
 		})
 	})
 
+	return g.Wait()
+}
+
+// fetchHeight fetches the block, the validators and the block results of the height concurrently.
+// They are fetched once per processing of the height and shared by all handlers.
+func (w *Worker) fetchHeight(ctx context.Context, workerIndex int, height int64) (*types.HeightData, error) {
+	g, ctx2 := errgroup.WithContext(ctx)
+
+	data := &types.HeightData{}
+
+	g.Go(func() error {
+		var err error
+
+		_blockDur := time.Now()
+		if data.Block, err = w.grpcClient.Block(ctx2, height); err != nil {
+			return newStageError(types.StageBlock, "", fmt.Errorf("failed to get block: %w", err))
+		}
+		w.log.Debug().
+			Int("worker_number", workerIndex).
+			Int64("block_height", height).
+			Dur("get_block_dur", time.Since(_blockDur)).
+			Msg("get block info")
+		return nil
+	})
+
+	g.Go(func() error {
+		var err error
+		_validatorsDur := time.Now()
+		if data.Validators, err = w.grpcClient.Validators(ctx2, height); err != nil {
+			return newStageError(types.StageValidators, "", fmt.Errorf("failed to get validators: %w", err))
+		}
+		w.log.Debug().
+			Int("worker_number", workerIndex).
+			Int64("block_height", height).
+			Dur("get_validators_dur", time.Since(_validatorsDur)).
+			Msg("Get validators info")
+
+		return nil
+	})
+
+	g.Go(func() error {
+		var err error
+		_blockResultsDur := time.Now()
+		if data.Results, err = w.rpcClient.GetBlockResults(ctx2, height); err != nil {
+			return newStageError(types.StageBlockEvents, "", fmt.Errorf("failed to get block results: %w", err))
+		}
+
+		w.log.Debug().
+			Int("worker_number", workerIndex).
+			Int64("block_height", height).
+			Dur("get_block_results_dur", time.Since(_blockResultsDur)).
+			Msg("get block results")
+
+		return nil
+	})
+
 	if err := g.Wait(); err != nil {
-		w.setErrorStatusWithLogging(ctx, height, err.Error())
-		return
+		return nil, err
 	}
 
-	if err := w.storage.SetProcessedStatus(ctx, height); err != nil {
-		w.log.Error().Err(err).Int64(keyHeight, height).Msg("can't set processed status in storage")
-	}
+	return data, nil
 }
 
 func (w *Worker) processGenesis(ctx context.Context, genesis *cometbfttypes.GenesisDoc) error {
@@ -231,7 +342,7 @@ func (w *Worker) processGenesis(ctx context.Context, genesis *cometbfttypes.Gene
 		return err
 	}
 
-	for _, m := range genesisHandlers {
+	for _, m := range w.genesisHandlers {
 		if err := m.HandleGenesis(ctx, genesis, appState); err != nil {
 			w.log.Error().Err(err).Str(keyModule, m.Name()).Msg("handle genesis error")
 		}
@@ -241,7 +352,7 @@ func (w *Worker) processGenesis(ctx context.Context, genesis *cometbfttypes.Gene
 }
 
 func (w *Worker) processBlock(ctx context.Context, block *types.Block) error {
-	for _, m := range blockHandlers {
+	for _, m := range w.blockHandlers {
 		if err := m.HandleBlock(ctx, block); err != nil {
 			w.log.Error().
 				Err(err).
@@ -249,7 +360,7 @@ func (w *Worker) processBlock(ctx context.Context, block *types.Block) error {
 				Str(keyModule, m.Name()).
 				Msg("HandleBlock error")
 
-			return err
+			return newStageError(types.StageBlock, m.Name(), err)
 		}
 	}
 
@@ -257,7 +368,7 @@ func (w *Worker) processBlock(ctx context.Context, block *types.Block) error {
 }
 
 func (w *Worker) processValidators(ctx context.Context, height int64, vals *cometbftcoreypes.ResultValidators) error {
-	for _, m := range validatorsHandlers {
+	for _, m := range w.validatorsHandlers {
 		if err := m.HandleValidators(ctx, vals); err != nil {
 			w.log.Error().
 				Err(err).
@@ -265,7 +376,7 @@ func (w *Worker) processValidators(ctx context.Context, height int64, vals *come
 				Str(keyModule, m.Name()).
 				Msg("HandleValidators error")
 
-			return err
+			return newStageError(types.StageValidators, m.Name(), err)
 		}
 	}
 
@@ -274,7 +385,7 @@ func (w *Worker) processValidators(ctx context.Context, height int64, vals *come
 
 func (w *Worker) processTxs(ctx context.Context, txs []*types.Tx) error {
 	for _, tx := range txs {
-		for _, m := range transactionHandlers {
+		for _, m := range w.transactionHandlers {
 			if err := m.HandleTx(ctx, tx); err != nil {
 				w.log.Error().
 					Err(err).
@@ -282,7 +393,7 @@ func (w *Worker) processTxs(ctx context.Context, txs []*types.Tx) error {
 					Str(keyModule, m.Name()).
 					Msg("HandleTX error")
 
-				return err
+				return newStageError(types.StageTxs, m.Name(), err)
 			}
 		}
 	}
@@ -332,7 +443,7 @@ func (w *Worker) processMessage(ctx context.Context, msg *codec.Any, tx *types.T
 		return nil
 	}
 
-	for _, m := range messageHandlers {
+	for _, m := range w.messageHandlers {
 		if err = m.HandleMessage(ctx, msgIndex, stdMsg, tx); err != nil {
 			w.log.Error().
 				Err(err).
@@ -340,11 +451,11 @@ func (w *Worker) processMessage(ctx context.Context, msg *codec.Any, tx *types.T
 				Str(keyModule, m.Name()).
 				Msg("HandleMessage error")
 
-			return err
+			return newStageError(types.StageMessages, m.Name(), err)
 		}
 	}
 
-	for _, m := range recursiveMessagesHandlers {
+	for _, m := range w.recursiveMessagesHandlers {
 		toProcess, err := m.HandleMessageRecursive(ctx, msgIndex, stdMsg, tx)
 		if err != nil {
 			w.log.Error().
@@ -353,7 +464,7 @@ func (w *Worker) processMessage(ctx context.Context, msg *codec.Any, tx *types.T
 				Str(keyModule, m.Name()).
 				Msg("HandleRecursiveMessage error")
 
-			return err
+			return newStageError(types.StageMessages, m.Name(), err)
 		}
 
 		if len(toProcess) > 0 {
@@ -375,10 +486,10 @@ func (w *Worker) processMessage(ctx context.Context, msg *codec.Any, tx *types.T
 }
 
 func (w *Worker) processBeginBlockerEvents(ctx context.Context, events types.BlockerEvents, height int64) error {
-	for _, m := range beginBlockerHandlers {
+	for _, m := range w.beginBlockerHandlers {
 		if err := m.HandleBeginBlocker(ctx, events, height); err != nil {
 			w.log.Error().Err(err).Str(keyModule, m.Name()).Msg("HandleBeginBlocker error")
-			return err
+			return newStageError(types.StageBeginBlocker, m.Name(), err)
 		}
 	}
 
@@ -386,10 +497,10 @@ func (w *Worker) processBeginBlockerEvents(ctx context.Context, events types.Blo
 }
 
 func (w *Worker) processEndBlockEvents(ctx context.Context, events types.BlockerEvents, height int64) error {
-	for _, m := range endBlockerHandlers {
+	for _, m := range w.endBlockerHandlers {
 		if err := m.HandleEndBlocker(ctx, events, height); err != nil {
 			w.log.Error().Err(err).Str(keyModule, m.Name()).Msg("HandleEndBlocker error")
-			return err
+			return newStageError(types.StageEndBlocker, m.Name(), err)
 		}
 	}
 
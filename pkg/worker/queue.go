@@ -11,39 +11,171 @@ import (
 
 func (w *Worker) enqueueHeight(ctx context.Context, wg *sync.WaitGroup, startHeight, stopHeight int64) {
 	defer wg.Done()
+	defer w.rangeDone.Store(true)
 
 	w.log.Debug().Msgf("try to parse: %d count of blocks", stopHeight-startHeight)
 
-	ctx, w.stopEnqueueHeight = context.WithCancel(ctx)
-	defer w.stopEnqueueHeight()
-
 	// send zero height to process genesis
-	if startHeight != 0 && w.cfg.ProcessGenesis {
-		w.heightCh <- 0
+	if startHeight != 0 && w.cfg.ProcessGenesis && !w.scheduler.push(ctx, classBackfill, 0) {
+		w.log.Info().Msg("stop enqueueHeight")
+		return
 	}
 
 	for height := startHeight; height >= 0 && height <= stopHeight; height++ {
-		// safe from closed channel
+		// put height to the queue for processing the block
+		if !w.scheduler.push(ctx, classBackfill, height) {
+			w.log.Info().Msg("stop enqueueHeight")
+			return
+		}
+
+		w.rangeEnqueued.Store(height)
+	}
+}
+
+// enqueueRange puts the heights of the range to the processing queue until ctx is done.
+func (w *Worker) enqueueRange(ctx context.Context, wg *sync.WaitGroup, from, to int64) {
+	defer wg.Done()
+
+	for height := from; height <= to; height++ {
+		if !w.scheduler.push(ctx, classBackfill, height) {
+			return
+		}
+	}
+}
+
+// followNewBlocks enqueues the heights of the new blocks until ctx is done.
+// The lost subscription is restored with backoff and the heights produced meanwhile are enqueued.
+func (w *Worker) followNewBlocks(ctx context.Context, wg *sync.WaitGroup, eventCh <-chan cometbftcoreypes.ResultEvent) {
+	defer wg.Done()
+
+	lastSeen := int64(-1)
+
+	for {
+		lastSeen = w.enqueueNewBlocks(ctx, eventCh, lastSeen)
+		if ctx.Err() != nil {
+			w.log.Info().Msg("stop new block listener")
+			return
+		}
+
+		w.log.Warn().Int64("last_seen", lastSeen).Msg("new blocks subscription is lost")
+
+		var ok bool
+		if eventCh, ok = w.resubscribeNewBlocks(ctx); !ok {
+			w.log.Info().Msg("stop new block listener")
+			return
+		}
+
+		// enqueue the heights produced while the subscription was lost
+		if lastSeen >= 0 {
+			tip, err := w.rpcClient.GetLastBlockHeight(ctx)
+			if err != nil {
+				// the heights are caught up with the next block
+				w.log.Error().Err(err).Msg("can't get last block height to catch up")
+				continue
+			}
+
+			if tip > lastSeen {
+				w.log.Info().Int64("from", lastSeen+1).Int64("to", tip).Msg("catching up new blocks")
+
+				if !w.enqueueLiveRange(ctx, lastSeen+1, tip) {
+					w.log.Info().Msg("stop new block listener")
+					return
+				}
+
+				lastSeen = tip
+			}
+		}
+	}
+}
+
+// pollNewBlocks enqueues the new heights found by polling the last block height until ctx is done.
+// All heights since the last seen one are enqueued, so no block is skipped between the polls.
+func (w *Worker) pollNewBlocks(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(w.cfg.PollNewBlocksInterval)
+	defer ticker.Stop()
+
+	w.log.Info().Dur("interval", w.cfg.PollNewBlocksInterval).Msg("polling for new blocks")
+
+	lastSeen := int64(-1)
+
+	for {
 		select {
 		case <-ctx.Done():
-			w.log.Info().Msg("stop enqueueHeight")
+			w.log.Info().Msg("stop new block poller")
 			return
-		case w.heightCh <- height: // put height to channel for processing the block
+		case <-ticker.C:
+			tip, err := w.rpcClient.GetLastBlockHeight(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					w.log.Error().Err(err).Msg("can't get last block height")
+				}
+				continue
+			}
+
+			if tip <= lastSeen {
+				continue
+			}
+
+			from := tip
+			if lastSeen >= 0 {
+				from = lastSeen + 1
+			}
+
+			w.log.Info().Int64("from", from).Int64("to", tip).Msg("enqueueing new blocks")
+
+			if !w.enqueueLiveRange(ctx, from, tip) {
+				w.log.Info().Msg("stop new block poller")
+				return
+			}
+
+			lastSeen = tip
+		}
+	}
+}
+
+// resubscribeNewBlocks subscribes to the new blocks again with backoff. It returns false if ctx is done.
+func (w *Worker) resubscribeNewBlocks(ctx context.Context) (<-chan cometbftcoreypes.ResultEvent, bool) {
+	delay := w.cfg.SubscribeBackoff
+
+	for {
+		eventCh, err := w.rpcClient.SubscribeNewBlocks(ctx)
+		if err == nil {
+			w.log.Info().Msg("resubscribed to new blocks")
+			return eventCh, true
+		}
+
+		w.log.Error().Err(err).Dur("backoff", delay).Msg("failed to resubscribe to new blocks")
+
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case <-time.After(delay):
+		}
+
+		if delay *= 2; delay > w.cfg.SubscribeMaxBackoff {
+			delay = w.cfg.SubscribeMaxBackoff
 		}
 	}
 }
 
-func (w *Worker) enqueueNewBlocks(ctx context.Context, eventCh <-chan cometbftcoreypes.ResultEvent) {
-	ctx, w.stopWsListener = context.WithCancel(ctx)
-	defer w.stopWsListener()
+// enqueueNewBlocks enqueues the heights of the new block events until ctx is done or the channel is closed.
+// The heights skipped since the last seen one are enqueued too. The last seen height is returned.
+func (w *Worker) enqueueNewBlocks(ctx context.Context, eventCh <-chan cometbftcoreypes.ResultEvent,
+	lastSeen int64) int64 {
+
 	w.log.Info().Msg("listening for new block events")
 
 	for {
 		select {
 		case <-ctx.Done():
-			w.log.Info().Msg("stop new block listener")
-			return
-		case e := <-eventCh:
+			return lastSeen
+		case e, ok := <-eventCh:
+			if !ok {
+				return lastSeen
+			}
+
 			newBlock, ok := e.Data.(cometbfttypes.EventDataNewBlock)
 			if !ok {
 				w.log.Warn().Msg("failed to cast ws event to EventDataNewBlock type")
@@ -51,46 +183,76 @@ func (w *Worker) enqueueNewBlocks(ctx context.Context, eventCh <-chan cometbftco
 			}
 
 			height := newBlock.Block.Header.Height
+			if height <= lastSeen { // already caught up
+				continue
+			}
+
+			from := height
+			if lastSeen >= 0 && lastSeen+1 < height {
+				from = lastSeen + 1
+				w.log.Warn().Int64("from", from).Int64("to", height-1).Msg("enqueueing skipped new blocks")
+			}
+
 			w.log.Info().Int64("height", height).Msg("enqueueing new block")
 
-			select {
-			case <-ctx.Done():
-				w.log.Info().Msg("stop new block listener")
-				return
-			case w.heightCh <- height:
+			if !w.enqueueLiveRange(ctx, from, height) {
+				return lastSeen
 			}
+
+			lastSeen = height
 		}
 	}
 }
 
+// enqueueLiveRange puts the tip heights of the range to the processing queue. It returns false if ctx is done.
+func (w *Worker) enqueueLiveRange(ctx context.Context, from, to int64) bool {
+	for height := from; height <= to; height++ {
+		if !w.scheduler.push(ctx, classLive, height) {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (w *Worker) enqueueErrorBlocks(ctx context.Context, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	ticker := time.NewTicker(w.cfg.ProcessErrorBlocksInterval)
 	defer ticker.Stop()
 
-	ctx, w.stopEnqueueErrorBlocks = context.WithCancel(ctx)
-	defer w.stopEnqueueErrorBlocks()
-
 	for {
 		select {
 		case <-ctx.Done():
-			w.log.Info().Msg("stop GetErrorBlockHeights")
+			w.log.Info().Msg("stop GetErrorBlocks")
 			return
 		case <-ticker.C:
-			heights, err := w.storage.GetErrorBlockHeights(ctx)
+			// release heights held by dead replicas to process them again
+			if err := w.storage.ReleaseExpiredBlocks(ctx); err != nil {
+				w.log.Error().Err(err).Str("func", "ReleaseExpiredBlocks").Msg("can't release expired blocks")
+			}
+
+			blocks, err := w.storage.GetErrorBlocks(ctx)
 			if err != nil {
-				w.log.Error().Err(err).Str("func", "GetErrorBlockHeights").Msg("can't enqueueErrorBlocks")
+				w.log.Error().Err(err).Str("func", "GetErrorBlocks").Msg("can't enqueueErrorBlocks")
 				return
 			}
 
-			for _, height := range heights {
-				// safe from closed channel
-				select {
-				case <-ctx.Done():
-					w.log.Info().Msg("stop GetErrorBlockHeights")
+			now := time.Now()
+			for _, block := range blocks {
+				if w.attemptsExhausted(block.Attempts) {
+					w.setFailedStatusWithLogging(ctx, block.Height)
+					continue
+				}
+
+				// wait for the backoff of the height
+				if now.Before(block.NextAttempt(w.cfg.RetryBackoff, w.cfg.RetryMaxBackoff)) {
+					continue
+				}
+
+				if !w.scheduler.push(ctx, classRetry, block.Height) {
+					w.log.Info().Msg("stop GetErrorBlocks")
 					return
-				case w.heightCh <- height:
 				}
 			}
 		}
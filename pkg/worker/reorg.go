@@ -0,0 +1,117 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	cometbftcoreypes "github.com/cometbft/cometbft/rpc/core/types"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/types"
+)
+
+// checkContinuity stores the hashes of the block and compares them with the stored neighbour heights.
+// The hashes are stored before the check, so at least one of two neighbours processed at the same time
+// sees the hashes of the other one. Nothing is stored if reorg detection is disabled.
+// The fetched block is treated as canonical: a neighbour with a mismatched hash is rolled back.
+func (w *Worker) checkContinuity(ctx context.Context, block *cometbftcoreypes.ResultBlock) error {
+	if !w.cfg.DetectReorgs {
+		return nil
+	}
+
+	var (
+		height     = block.Block.Height
+		hash       = block.Block.Hash().String()
+		parentHash = block.Block.LastBlockID.Hash.String()
+	)
+
+	if err := w.storage.SetBlockHash(ctx, height, hash, parentHash); err != nil {
+		return fmt.Errorf("failed to set block hash: %w", err)
+	}
+
+	if err := w.rollbackForkedAncestors(ctx, height, parentHash); err != nil {
+		return err
+	}
+
+	next, err := w.storage.GetBlockByHeight(ctx, height+1)
+	switch {
+	case errors.Is(err, types.ErrBlockNotFound):
+	case err != nil:
+		return fmt.Errorf("failed to get child block: %w", err)
+	case next.ParentHash != "" && next.ParentHash != hash:
+		return w.rollback(ctx, types.Reorg{
+			Height:         next.Height,
+			StoredHash:     next.Hash,
+			DetectedHeight: height,
+		})
+	}
+
+	return nil
+}
+
+// rollbackForkedAncestors walks back from the parent of the height and rolls back the stored heights
+// with mismatched hashes until the stored hash matches the canonical one or the height is not stored yet.
+// The canonical hash of each lower height is the parent hash of the fetched block above it.
+func (w *Worker) rollbackForkedAncestors(ctx context.Context, height int64, parentHash string) error {
+	for canonicalHash := parentHash; canonicalHash != "" && height > 1; height-- {
+		prev, err := w.storage.GetBlockByHeight(ctx, height-1)
+		switch {
+		case errors.Is(err, types.ErrBlockNotFound):
+			return nil
+		case err != nil:
+			return fmt.Errorf("failed to get parent block: %w", err)
+		case prev.Hash == "" || prev.Hash == canonicalHash:
+			return nil
+		}
+
+		if err = w.rollback(ctx, types.Reorg{
+			Height:         prev.Height,
+			StoredHash:     prev.Hash,
+			CanonicalHash:  canonicalHash,
+			DetectedHeight: height,
+		}); err != nil {
+			return err
+		}
+
+		canonical, err := w.grpcClient.Block(ctx, prev.Height)
+		if err != nil {
+			return fmt.Errorf("failed to get canonical block: %w", err)
+		}
+
+		canonicalHash = canonical.Block.LastBlockID.Hash.String()
+	}
+
+	return nil
+}
+
+// rollback marks the height for reprocessing and notifies consumers to invalidate its data.
+func (w *Worker) rollback(ctx context.Context, reorg types.Reorg) error {
+	reorg.DetectedAt = time.Now()
+
+	w.log.Warn().
+		Int64(keyHeight, reorg.Height).
+		Int64("detected_height", reorg.DetectedHeight).
+		Str("stored_hash", reorg.StoredHash).
+		Str("canonical_hash", reorg.CanonicalHash).
+		Msg("hash mismatch with the neighbour height. rollback height")
+
+	if w.metrics != nil {
+		w.metrics.reorgMetric.Inc()
+	}
+
+	msg := fmt.Sprintf("reorg detected by height %d", reorg.DetectedHeight)
+	if err := w.storage.RollbackBlock(ctx, reorg.Height, msg); err != nil {
+		return fmt.Errorf("failed to set error status for rolled back height: %w", err)
+	}
+
+	if err := w.lowerFinalizedHeight(ctx, reorg.Height); err != nil {
+		return err
+	}
+
+	if err := w.broker.PublishReorg(ctx, reorg); err != nil {
+		return fmt.Errorf("failed to publish reorg: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package worker
+
+import (
+	"context"
+	"math"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Classes of the enqueued heights.
+const (
+	// classLive is the new heights of the chain tip
+	classLive heightClass = iota
+	// classRetry is the error heights processed again
+	classRetry
+	// classBackfill is the historical heights of the configured range, backfill jobs and reprocessing
+	classBackfill
+
+	classesCount
+)
+
+type (
+	heightClass int
+
+	// scheduler is the priority queue of the heights to process.
+	// Live heights are taken first, then backfill heights and retries at last.
+	// Workers reserved for retries take retries first, so error heights don't wait for a long backfill.
+	scheduler struct {
+		queues [classesCount]chan int64
+	}
+)
+
+var (
+	// classOrder is the order of the classes taken by a regular worker
+	classOrder = []heightClass{classLive, classBackfill, classRetry}
+	// retryClassOrder is the order of the classes taken by a worker reserved for retries
+	retryClassOrder = []heightClass{classRetry, classLive, classBackfill}
+)
+
+func (c heightClass) String() string {
+	switch c {
+	case classLive:
+		return "live"
+	case classRetry:
+		return "retry"
+	default:
+		return "backfill"
+	}
+}
+
+// newScheduler creates the scheduler with the queue of the given size for each class.
+func newScheduler(size int) *scheduler {
+	s := &scheduler{}
+	for i := range s.queues {
+		s.queues[i] = make(chan int64, size)
+	}
+
+	return s
+}
+
+// registerMetrics registers the queue depth gauge of each class.
+func (s *scheduler) registerMetrics() {
+	for i := range s.queues {
+		queue := s.queues[i]
+
+		promauto.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace:   "spacebox_crawler",
+			Name:        "queue_depth",
+			Help:        "Count of enqueued heights waiting for a worker",
+			ConstLabels: prometheus.Labels{"class": heightClass(i).String()},
+		}, func() float64 { return float64(len(queue)) })
+	}
+}
+
+// push enqueues the height of the class. It blocks while the queue is full and returns false if ctx is done.
+// It must not be called after close.
+func (s *scheduler) push(ctx context.Context, class heightClass, height int64) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case s.queues[class] <- height:
+		return true
+	}
+}
+
+// next returns the height of the first non-empty class in the order or waits for any height.
+// It returns false after close.
+func (s *scheduler) next(order []heightClass) (int64, bool) {
+	for _, class := range order {
+		select {
+		case height, ok := <-s.queues[class]:
+			if ok {
+				return height, true
+			}
+		default:
+		}
+	}
+
+	var (
+		height int64
+		ok     bool
+	)
+
+	select {
+	case height, ok = <-s.queues[classLive]:
+	case height, ok = <-s.queues[classRetry]:
+	case height, ok = <-s.queues[classBackfill]:
+	}
+
+	return height, ok
+}
+
+// close stops the workers waiting for heights. The heights left in the queues are dropped.
+func (s *scheduler) close() {
+	for _, queue := range s.queues {
+		close(queue)
+	}
+}
+
+// retryWorkers returns the count of the workers reserved for retries.
+// At least one worker is always left for the other heights.
+func retryWorkers(workersCount int, share float64) int {
+	if share <= 0 {
+		return 0
+	}
+
+	reserved := int(math.Ceil(share * float64(workersCount)))
+	if reserved >= workersCount {
+		reserved = workersCount - 1
+	}
+
+	return reserved
+}
@@ -0,0 +1,410 @@
+package worker
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/internal/rep"
+)
+
+var _ rep.Broker = &Sequencer{}
+
+type (
+	// Sequencer is a broker which publishes messages of the heights strictly in the height order.
+	// Heights are processed in parallel, their messages are buffered until all lower heights are published.
+	// Heights lower than the last published one (e.g. reprocessed error blocks) are published immediately.
+	// Messages of the heights are also buffered if the broker is transactional, so the transaction is open only
+	// while the messages of the processed height are published.
+	Sequencer struct {
+		log     *zerolog.Logger
+		broker  rep.Broker
+		metrics *sequencerMetrics
+
+		// changed is closed and replaced on every state change to wake up the waiting workers
+		changed chan struct{}
+		heights map[int64]*sequencedHeight
+		// blocked is the count of workers waiting for the buffer space by the height
+		blocked map[int64]int
+
+		mu       sync.Mutex
+		next     int64 // next height to publish
+		size     int64
+		messages int
+		workers  int
+		waiting  int
+		started  bool
+		enabled  bool
+		buffered bool
+	}
+
+	sequencedHeight struct {
+		messages []publishFunc
+		height   int64
+		ordered  bool // the height is registered in the ordered buffer
+		skipped  bool
+	}
+
+	// transactional is the broker publishing messages within transactions.
+	transactional interface {
+		Transactional() bool
+	}
+
+	sequencerMetrics struct {
+		heights  prometheus.Gauge
+		messages prometheus.Gauge
+	}
+
+	publishFunc func(ctx context.Context) error
+
+	// flushFunc publishes the buffered messages of the height.
+	flushFunc func(ctx context.Context, messages []publishFunc) error
+
+	sequencedHeightKey struct{}
+)
+
+// NewSequencer creates the broker wrapper. It publishes messages directly if ordered delivery is disabled.
+func NewSequencer(cfg Config, b rep.Broker, l zerolog.Logger) *Sequencer {
+	l = l.With().Str("cmp", "sequencer").Logger()
+
+	s := &Sequencer{
+		log:     &l,
+		broker:  b,
+		changed: make(chan struct{}),
+		heights: make(map[int64]*sequencedHeight),
+		blocked: make(map[int64]int),
+		size:    cfg.OrderedBufferSize,
+		workers: cfg.WorkersCount,
+		enabled: cfg.OrderedDelivery,
+	}
+
+	if tb, ok := b.(transactional); ok {
+		s.buffered = tb.Transactional()
+	}
+
+	if s.size <= 0 {
+		s.size = 1
+	}
+
+	if s.workers <= 0 {
+		s.workers = 1
+	}
+
+	// the first height to publish is known only for the configured height range
+	if cfg.StartHeight >= 0 {
+		s.next, s.started = cfg.StartHeight, true
+	}
+
+	if cfg.MetricsEnabled && s.enabled {
+		s.metrics = &sequencerMetrics{
+			heights: promauto.NewGauge(prometheus.GaugeOpts{
+				Namespace: "spacebox_crawler",
+				Name:      "ordered_buffer_heights",
+				Help:      "Count of heights in the ordered delivery buffer",
+			}),
+			messages: promauto.NewGauge(prometheus.GaugeOpts{
+				Namespace: "spacebox_crawler",
+				Name:      "ordered_buffer_messages",
+				Help:      "Count of messages waiting for publishing in the ordered delivery buffer",
+			}),
+		}
+	}
+
+	return s
+}
+
+func (s *Sequencer) PublishRawBlock(ctx context.Context, b interface{}) error {
+	return s.publish(ctx, func(ctx context.Context) error { return s.broker.PublishRawBlock(ctx, b) })
+}
+
+func (s *Sequencer) PublishRawTransaction(ctx context.Context, tx interface{}) error {
+	return s.publish(ctx, func(ctx context.Context) error { return s.broker.PublishRawTransaction(ctx, tx) })
+}
+
+func (s *Sequencer) PublishRawBlockResults(ctx context.Context, br interface{}) error {
+	return s.publish(ctx, func(ctx context.Context) error { return s.broker.PublishRawBlockResults(ctx, br) })
+}
+
+func (s *Sequencer) PublishRawGenesis(ctx context.Context, g interface{}) error {
+	return s.publish(ctx, func(ctx context.Context) error { return s.broker.PublishRawGenesis(ctx, g) })
+}
+
+func (s *Sequencer) PublishReorg(ctx context.Context, r interface{}) error {
+	return s.publish(ctx, func(ctx context.Context) error { return s.broker.PublishReorg(ctx, r) })
+}
+
+func (s *Sequencer) PublishFinalizedHeight(ctx context.Context, fh interface{}) error {
+	return s.broker.PublishFinalizedHeight(ctx, fh)
+}
+
+// PublishDeadLetter publishes the failure record without waiting for the lower heights.
+func (s *Sequencer) PublishDeadLetter(ctx context.Context, dl interface{}) error {
+	return s.broker.PublishDeadLetter(ctx, dl)
+}
+
+func (s *Sequencer) BeginTransaction(ctx context.Context) (context.Context, error) {
+	return s.broker.BeginTransaction(ctx)
+}
+
+func (s *Sequencer) CommitTransaction(ctx context.Context) error {
+	return s.broker.CommitTransaction(ctx)
+}
+
+func (s *Sequencer) AbortTransaction(ctx context.Context) error {
+	return s.broker.AbortTransaction(ctx)
+}
+
+// publish buffers the message if the context belongs to a sequenced height, otherwise publishes it directly.
+func (s *Sequencer) publish(ctx context.Context, fn publishFunc) error {
+	h, ok := ctx.Value(sequencedHeightKey{}).(*sequencedHeight)
+	if !ok {
+		return fn(ctx)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if h.skipped {
+		return fn(ctx)
+	}
+
+	h.messages = append(h.messages, fn)
+	if h.ordered {
+		s.messages++
+		s.updateMetrics()
+	}
+
+	return nil
+}
+
+// begin registers the claimed height in the buffer and returns the context to publish its messages with.
+// It waits while the height is too far from the next height to publish.
+func (s *Sequencer) begin(ctx context.Context, height int64) (context.Context, error) {
+	if s == nil || (!s.enabled && !s.buffered) {
+		return ctx, nil
+	}
+
+	// the messages are published all at once by commit, but not in the height order
+	unordered := ctx
+	if s.buffered {
+		unordered = context.WithValue(ctx, sequencedHeightKey{}, &sequencedHeight{height: height})
+	}
+
+	if !s.enabled {
+		return unordered, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.started {
+		s.next, s.started = height, true
+	}
+
+	for height >= s.next+s.size {
+		s.blocked[height]++
+		err := s.wait(ctx)
+		if s.blocked[height]--; s.blocked[height] == 0 {
+			delete(s.blocked, height)
+		}
+
+		if err != nil {
+			return ctx, err
+		}
+	}
+
+	// the height is already published or sequenced by the worker which lost its lease,
+	// a skipped height which is not passed yet is sequenced again
+	if h, ok := s.heights[height]; (ok && !h.skipped) || height < s.next {
+		return unordered, nil
+	}
+
+	h := &sequencedHeight{height: height, ordered: true}
+	s.heights[height] = h
+	s.updateMetrics()
+
+	return context.WithValue(ctx, sequencedHeightKey{}, h), nil
+}
+
+// commit publishes the buffered messages of the height by the flush function,
+// in ordered delivery mode after all lower heights.
+// The lock is not held while flushing, the next height stays the same until the messages are published.
+func (s *Sequencer) commit(ctx context.Context, flush flushFunc) error {
+	h, ok := ctx.Value(sequencedHeightKey{}).(*sequencedHeight)
+	if s == nil || !ok {
+		return nil
+	}
+
+	s.mu.Lock()
+
+	if !h.ordered {
+		messages := h.messages
+		h.messages, h.skipped = nil, true
+		s.mu.Unlock()
+
+		return flush(ctx, messages)
+	}
+
+	for h.height != s.next {
+		if err := s.wait(ctx); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+	}
+
+	messages := h.messages
+	s.mu.Unlock()
+
+	err := flush(ctx, messages)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages -= len(messages)
+	h.messages, h.skipped = nil, true
+	delete(s.heights, h.height)
+	s.next++
+	s.advance()
+
+	return err
+}
+
+// skip moves the next height over the height which is not claimed by the worker,
+// e.g. it is already processed or claimed by another replica, without waiting for all workers to get stuck.
+func (s *Sequencer) skip(height int64) {
+	if s == nil || !s.enabled {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.started {
+		s.next, s.started = height, true
+	}
+
+	if _, ok := s.heights[height]; ok || height < s.next {
+		return
+	}
+
+	s.heights[height] = &sequencedHeight{height: height, ordered: true, skipped: true}
+	s.advance()
+}
+
+// end skips the height if it is not published by commit, e.g. the height is failed.
+func (s *Sequencer) end(ctx context.Context) {
+	h, ok := ctx.Value(sequencedHeightKey{}).(*sequencedHeight)
+	if s == nil || !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if h.skipped {
+		return
+	}
+
+	if h.ordered {
+		s.messages -= len(h.messages)
+	}
+
+	h.messages, h.skipped = nil, true
+
+	if h.ordered {
+		s.advance()
+	}
+}
+
+// advance moves the next height over the skipped heights and wakes up the waiting workers.
+func (s *Sequencer) advance() {
+	for {
+		h, ok := s.heights[s.next]
+		if !ok || !h.skipped {
+			break
+		}
+
+		delete(s.heights, s.next)
+		s.next++
+	}
+
+	s.updateMetrics()
+
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// wait releases the lock until the state is changed. Must be called with the lock held.
+func (s *Sequencer) wait(ctx context.Context) error {
+	s.waiting++
+	defer func() { s.waiting-- }()
+
+	if s.unstick() {
+		return nil
+	}
+
+	changed := s.changed
+	s.mu.Unlock()
+
+	var err error
+	select {
+	case <-changed:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	s.mu.Lock()
+
+	return err
+}
+
+// unstick skips the missing next height if all workers are waiting, so nobody can process it.
+// Returns true if the next height is moved.
+func (s *Sequencer) unstick() bool {
+	if s.waiting < s.workers {
+		return false
+	}
+
+	if _, ok := s.heights[s.next]; ok {
+		return false
+	}
+
+	lowest := int64(-1)
+	for height := range s.heights {
+		if lowest < 0 || height < lowest {
+			lowest = height
+		}
+	}
+
+	for height := range s.blocked {
+		if lowest < 0 || height < lowest {
+			lowest = height
+		}
+	}
+
+	if lowest <= s.next {
+		return false
+	}
+
+	s.log.Warn().
+		Int64("from_height", s.next).
+		Int64("to_height", lowest-1).
+		Msg("heights are not enqueued for processing. skip them in ordered delivery")
+
+	s.next = lowest
+	s.advance()
+
+	return true
+}
+
+func (s *Sequencer) updateMetrics() {
+	if s.metrics == nil {
+		return
+	}
+
+	s.metrics.heights.Set(float64(len(s.heights)))
+	s.metrics.messages.Set(float64(s.messages))
+}
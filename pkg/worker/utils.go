@@ -3,6 +3,8 @@ package worker
 import (
 	"context"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	codec "github.com/cosmos/cosmos-sdk/codec/types"
 	sdk "github.com/cosmos/cosmos-sdk/types"
@@ -16,45 +18,133 @@ var (
 	ErrBlockProcessed  = errors.New("block already processed")
 	ErrBlockProcessing = errors.New("block is processing right now")
 	ErrBlockError      = errors.New("block processed with error")
+	ErrBlockFailed     = errors.New("block failed after max attempts")
+	ErrBlockSkipped    = errors.New("block skipped by the operator")
 )
 
-func (w *Worker) setErrorStatusWithLogging(ctx context.Context, height int64, msg string) {
-	if err := w.storage.SetErrorStatus(ctx, height, msg); err != nil {
+// stageError is the error of the height processing stage reported in the dead letter.
+type stageError struct {
+	err    error
+	stage  string
+	module string
+}
+
+func newStageError(stage, module string, err error) error {
+	return &stageError{err: err, stage: stage, module: module}
+}
+
+func (e *stageError) Error() string { return e.err.Error() }
+
+func (e *stageError) Unwrap() error { return e.err }
+
+func (w *Worker) setErrorStatusWithLogging(ctx context.Context, height int64, attempt int, err error) {
+	switch err := w.storage.SetErrorStatus(ctx, height, w.owner, err.Error()); {
+	case errors.Is(err, types.ErrLeaseLost):
+		w.log.Warn().Int64(keyHeight, height).Msg("block lease is lost. skip error status")
+	case err != nil:
 		w.log.Error().Err(err).Int64("height", height).Msg("can't set error status in storage")
+	case w.attemptsExhausted(attempt):
+		w.setFailedStatusWithLogging(ctx, height)
 	}
+
+	// errors outside the stages come from publishing of the height messages
+	dl := types.DeadLetter{Stage: types.StagePublish}
+
+	var se *stageError
+	if errors.As(err, &se) {
+		dl.Stage, dl.Module = se.stage, se.module
+	}
+
+	if errors.Is(err, types.ErrHeightPruned) {
+		dl.Class = types.ClassHeightPruned
+	}
+
+	dl.Height, dl.Attempt, dl.Error = height, attempt, err.Error()
+	w.publishDeadLetter(ctx, dl)
 }
 
-func (w *Worker) checkOrCreateBlockInStorage(ctx context.Context, height int64) error {
-	block, err := w.storage.GetBlockByHeight(ctx, height)
-	if err != nil && errors.Is(err, types.ErrBlockNotFound) {
-		// create new block
-		if err = w.storage.CreateBlock(ctx, w.tsM.NewBlock(height)); err != nil {
-			w.log.Error().Err(err).Int64("height", height).Msg("can't create new block in storage")
-			return err
-		}
+// publishDeadLetter publishes the failure record to the dead letter topic.
+func (w *Worker) publishDeadLetter(ctx context.Context, dl types.DeadLetter) {
+	dl.Timestamp = time.Now()
+
+	if err := w.broker.PublishDeadLetter(ctx, dl); err != nil {
+		w.log.Error().Err(err).Int64(keyHeight, dl.Height).Msg("can't publish dead letter")
+	}
+}
+
+// attemptsExhausted checks whether the height can't be processed again after the given attempts.
+func (w *Worker) attemptsExhausted(attempts int) bool {
+	return w.cfg.MaxAttempts > 0 && attempts >= w.cfg.MaxAttempts
+}
+
+// setFailedStatusWithLogging moves the height to the terminal failed status, so it is not retried anymore.
+func (w *Worker) setFailedStatusWithLogging(ctx context.Context, height int64) {
+	w.log.Warn().Int64(keyHeight, height).Int("max_attempts", w.cfg.MaxAttempts).Msg("height failed")
 
-		return nil
-	} else if err != nil {
+	if err := w.storage.UpdateStatus(ctx, height, model.StatusFailed); err != nil {
+		w.log.Error().Err(err).Int64(keyHeight, height).Msg("can't set failed status in storage")
+	}
+}
+
+// claimBlock claims the block for processing by the worker and returns it with the counted attempt.
+// If the block is not claimed, the current block is returned along with the reason.
+func (w *Worker) claimBlock(ctx context.Context, height int64) (*model.Block, error) {
+	block, err := w.storage.ClaimBlock(ctx, height, w.owner, w.cfg.LeaseDuration, w.cfg.ProcessErrorBlocks)
+	if err == nil {
+		return block, nil
+	} else if !errors.Is(err, types.ErrBlockNotClaimed) {
 		// got some error from storage
-		return err
+		return nil, err
 	}
 
 	// block exists check status
 	switch {
 	// block info already in kafka
 	case block.Status.IsProcessed():
-		return ErrBlockProcessed
-	// block now is processing
+		return block, ErrBlockProcessed
+	// block now is processing by another worker or replica
 	case block.Status.IsProcessing():
-		return ErrBlockProcessing
+		return block, ErrBlockProcessing
+	// block failed after the max attempts
+	case block.Status.IsFailed():
+		return block, ErrBlockFailed
+	// block skipped through the admin api
+	case block.Status.IsSkipped():
+		return block, ErrBlockSkipped
 	// block processed with error, skip if needed
-	case block.Status.IsError():
-		if !w.cfg.ProcessErrorBlocks {
-			return ErrBlockError
-		}
-		return w.storage.UpdateStatus(ctx, height, model.StatusProcessing)
+	default:
+		return block, ErrBlockError
 	}
-	return nil
+}
+
+// keepLease renews the lease of the claimed block until ctx is done.
+// If the lease is taken over by another replica, processing of the block is cancelled and true is stored.
+func (w *Worker) keepLease(ctx context.Context, cancel context.CancelFunc, height int64) *atomic.Bool {
+	lost := &atomic.Bool{}
+
+	go func() {
+		ticker := time.NewTicker(w.cfg.LeaseDuration / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := w.storage.RenewLease(ctx, height, w.owner, w.cfg.LeaseDuration)
+				switch {
+				case errors.Is(err, types.ErrLeaseLost):
+					lost.Store(true)
+					cancel()
+					return
+				case err != nil && ctx.Err() == nil:
+					w.log.Error().Err(err).Int64(keyHeight, height).Msg("can't renew block lease")
+				}
+			}
+		}
+	}()
+
+	return lost
 }
 
 func (w *Worker) unpackMessage(ctx context.Context, height int64, msg *codec.Any) (stdMsg sdk.Msg, err error) {
@@ -65,6 +155,14 @@ func (w *Worker) unpackMessage(ctx context.Context, height int64, msg *codec.Any
 	if strings.HasPrefix(err.Error(), "no concrete type registered for type URL") {
 		w.log.Warn().Err(err).Int64(keyHeight, height).Msg("error while unpacking message")
 
+		origin, _ := types.OriginFromContext(ctx)
+		w.publishDeadLetter(ctx, types.DeadLetter{
+			Stage:   types.StageMessages,
+			Error:   err.Error(),
+			Height:  height,
+			Attempt: origin.Attempt,
+		})
+
 		if err = w.storage.InsertErrorMessage(ctx, w.tsM.NewErrorMessage(height, err.Error())); err != nil {
 			w.log.Error().
 				Err(err).
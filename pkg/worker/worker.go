@@ -2,9 +2,14 @@ package worker
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"os"
 	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/cosmos/cosmos-sdk/codec"
 	"github.com/prometheus/client_golang/prometheus"
@@ -16,6 +21,15 @@ import (
 	"github.com/bro-n-bro/spacebox-crawler/v2/types"
 )
 
+const (
+	defaultLeaseDuration     = time.Minute
+	defaultBackfillInterval  = 10 * time.Second
+	defaultBackfillBatchSize = 100
+	defaultGapScanInterval   = 5 * time.Minute
+	defaultSubscribeBackoff  = time.Second
+	defaultPollInterval      = 5 * time.Second
+)
+
 type (
 	Worker struct {
 		log *zerolog.Logger
@@ -30,19 +44,47 @@ type (
 
 		metrics *metrics
 
-		stopProcessing         func()
-		stopWsListener         func()
-		stopEnqueueHeight      func()
-		stopEnqueueErrorBlocks func()
+		// sequencer orders the published messages of the heights if the broker is wrapped by it
+		sequencer *Sequencer
+
+		// owner identifies the replica in the claimed blocks
+		owner string
+
+		stopProcessing           func()
+		stopWsListener           func()
+		stopEnqueueHeight        func()
+		stopEnqueueErrorBlocks   func()
+		stopTrackFinalizedHeight func()
+		stopEnqueue              func()
+
+		// enqueueCtx is the context of the heights enqueued at runtime by Reprocess and backfill jobs
+		enqueueCtx context.Context
+		enqueueWg  *sync.WaitGroup
+		// rangeWg waits for the enqueuers of the configured range and the error blocks,
+		// the application exits when they are done unless new blocks are processed
+		rangeWg *sync.WaitGroup
 
-		heightCh chan int64
+		// scheduler is the priority queue of the heights to process
+		scheduler *scheduler
+
+		// rangeEnqueued is the last enqueued height of the configured range, gaps above it are not scanned
+		// until rangeDone is set
+		rangeEnqueued atomic.Int64
+		rangeDone     atomic.Bool
+
+		finalized finalizedTracker
+		pauser    pauser
 
 		modules []types.Module
-		cfg     Config
+		handlers
+		cfg Config
 	}
 
 	metrics struct {
-		durMetric *prometheus.HistogramVec
+		durMetric       *prometheus.HistogramVec
+		reorgMetric     prometheus.Counter
+		backfillPending prometheus.Gauge
+		missingHeights  prometheus.Gauge
 	}
 )
 
@@ -62,8 +104,62 @@ func New(cfg Config, l zerolog.Logger, b rep.Broker, rpcCli rep.RPCClient, grpcC
 		cdc:        marshaler,
 		tsM:        tsM,
 		wg:         &sync.WaitGroup{},
+
+		enqueueWg:   &sync.WaitGroup{},
+		rangeWg:     &sync.WaitGroup{},
+		stopEnqueue: func() {},
+	}
+
+	w.sequencer, _ = b.(*Sequencer)
+
+	// workers count must be greater than 0
+	if w.cfg.WorkersCount <= 0 {
+		w.cfg.WorkersCount = 1
+	}
+
+	w.scheduler = newScheduler(w.cfg.WorkersCount)
+
+	if w.cfg.LeaseDuration <= 0 {
+		w.cfg.LeaseDuration = defaultLeaseDuration
 	}
 
+	if w.cfg.BackfillInterval <= 0 {
+		w.cfg.BackfillInterval = defaultBackfillInterval
+	}
+
+	if w.cfg.BackfillBatchSize <= 0 {
+		w.cfg.BackfillBatchSize = defaultBackfillBatchSize
+	}
+
+	if w.cfg.GapScanInterval <= 0 {
+		w.cfg.GapScanInterval = defaultGapScanInterval
+	}
+
+	if w.cfg.SubscribeBackoff <= 0 {
+		w.cfg.SubscribeBackoff = defaultSubscribeBackoff
+	}
+
+	if w.cfg.SubscribeMaxBackoff < w.cfg.SubscribeBackoff {
+		w.cfg.SubscribeMaxBackoff = w.cfg.SubscribeBackoff
+	}
+
+	if w.cfg.PollNewBlocksInterval <= 0 {
+		w.cfg.PollNewBlocksInterval = defaultPollInterval
+	}
+
+	// new blocks are followed either by polling or by websocket
+	if w.cfg.PollNewBlocks && w.cfg.ProcessNewBlocks {
+		w.log.Warn().Msg("new blocks are polled, websocket subscription is disabled")
+		w.cfg.ProcessNewBlocks = false
+	}
+
+	w.owner = w.cfg.OwnerID
+	if w.owner == "" {
+		w.owner = newOwnerID()
+	}
+
+	w.log.Info().Str("owner", w.owner).Msg("worker owner id")
+
 	// fill modules based on enabled modules from config
 	w.fillModules()
 	return w
@@ -77,6 +173,21 @@ func (w *Worker) Start(_ context.Context) error {
 				Name:      "process_duration",
 				Help:      "Duration of parsed blockchain objects",
 			}, []string{"type"}),
+			reorgMetric: promauto.NewCounter(prometheus.CounterOpts{
+				Namespace: "spacebox_crawler",
+				Name:      "reorgs_total",
+				Help:      "Total heights rolled back due to hash mismatch with a neighbour height",
+			}),
+			backfillPending: promauto.NewGauge(prometheus.GaugeOpts{
+				Namespace: "spacebox_crawler",
+				Name:      "backfill_pending_heights",
+				Help:      "Heights of the active backfill jobs which are not completed yet",
+			}),
+			missingHeights: promauto.NewGauge(prometheus.GaugeOpts{
+				Namespace: "spacebox_crawler",
+				Name:      "missing_heights",
+				Help:      "Heights absent from storage between the start height and the tip found by the last gap scan",
+			}),
 		}
 
 		var val float64
@@ -94,13 +205,8 @@ func (w *Worker) Start(_ context.Context) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	w.stopProcessing = cancel
 
-	// workers count must be greater than 0
 	workersCount := w.cfg.WorkersCount
-	if workersCount <= 0 {
-		workersCount = 1
-	}
-
-	w.heightCh = make(chan int64, workersCount)
+	retryWorkersCount := retryWorkers(workersCount, w.cfg.RetryWorkersShare)
 
 	stopHeight := w.cfg.StopHeight
 	// check if stop height is empty, and we want to process height range from config
@@ -131,60 +237,139 @@ func (w *Worker) Start(_ context.Context) error {
 			Name:      "total_workers",
 			Help:      "Count of workers",
 		}).Set(float64(workersCount))
+
+		promauto.NewGauge(prometheus.GaugeOpts{
+			Namespace: "spacebox_crawler",
+			Name:      "retry_workers",
+			Help:      "Count of workers reserved for retries of error heights",
+		}).Set(float64(retryWorkersCount))
+
+		w.scheduler.registerMetrics()
 	}
 
-	// spawn workers
+	// spawn workers, the first ones are reserved for retries
 	for i := 0; i < workersCount; i++ {
+		order := classOrder
+		if i < retryWorkersCount {
+			order = retryClassOrder
+		}
+
 		w.wg.Add(1)
-		go w.process(ctx, i, w.cfg.RecoveryMode) // run processing block function
+		go w.process(ctx, i, order, w.cfg.RecoveryMode) // run processing block function
 	}
 
 	// subscribe to process new blocks by websocket
 	if w.cfg.ProcessNewBlocks {
-		eventCh, err := w.rpcClient.SubscribeNewBlocks(ctx)
+		var wsCtx context.Context
+		wsCtx, w.stopWsListener = context.WithCancel(ctx)
+
+		eventCh, err := w.rpcClient.SubscribeNewBlocks(wsCtx)
 		if err != nil {
 			return fmt.Errorf("failed to subscribe to new blocks: %w", err)
 		}
-		go w.enqueueNewBlocks(ctx, eventCh)
-	}
 
-	wg := &sync.WaitGroup{}
+		w.enqueueWg.Add(1)
+		go w.followNewBlocks(wsCtx, w.enqueueWg, eventCh)
+	}
 
 	// enqueue error blocks height
 	if w.cfg.ProcessErrorBlocks {
-		wg.Add(1)
-		go w.enqueueErrorBlocks(ctx, wg)
+		var errorBlocksCtx context.Context
+		errorBlocksCtx, w.stopEnqueueErrorBlocks = context.WithCancel(ctx)
+
+		w.rangeWg.Add(1)
+		go w.enqueueErrorBlocks(errorBlocksCtx, w.rangeWg)
+	}
+
+	// track the contiguous processed height
+	if w.cfg.TrackFinalizedHeight {
+		if w.cfg.MetricsEnabled {
+			w.finalized.metric = promauto.NewGauge(prometheus.GaugeOpts{
+				Namespace: "spacebox_crawler",
+				Name:      "finalized_height",
+				Help:      "Height up to which all heights are processed",
+			})
+		}
+
+		var finalizedCtx context.Context
+		finalizedCtx, w.stopTrackFinalizedHeight = context.WithCancel(ctx)
+		go w.trackFinalizedHeight(finalizedCtx)
+	}
+
+	w.enqueueCtx, w.stopEnqueue = context.WithCancel(ctx)
+
+	// process backfill jobs alongside the live blocks
+	if w.cfg.BackfillEnabled {
+		w.enqueueWg.Add(1)
+		go w.runBackfillJobs(w.enqueueCtx, w.enqueueWg)
+	}
+
+	// poll the last block height to process new blocks
+	if w.cfg.PollNewBlocks {
+		w.enqueueWg.Add(1)
+		go w.pollNewBlocks(w.enqueueCtx, w.enqueueWg)
+	}
+
+	// enqueue the heights missing from storage
+	if w.cfg.GapScanEnabled {
+		w.enqueueWg.Add(1)
+		go w.runGapScanner(w.enqueueCtx, w.enqueueWg)
 	}
 
 	// enqueue block height based on config start/stop heights
-	wg.Add(1)
-	go w.enqueueHeight(ctx, wg, w.cfg.StartHeight, stopHeight)
+	var heightCtx context.Context
+	heightCtx, w.stopEnqueueHeight = context.WithCancel(ctx)
+
+	w.rangeWg.Add(1)
+	go w.enqueueHeight(heightCtx, w.rangeWg, w.cfg.StartHeight, stopHeight)
 
 	// graceful shutdown the application if processing is done
 	go func(wg *sync.WaitGroup) {
-		if w.cfg.ProcessNewBlocks { // we want to process new blocks
+		if w.cfg.ProcessNewBlocks || w.cfg.PollNewBlocks { // we want to process new blocks
 			w.log.Info().Msg("exit not needed")
 			return
 		}
 		wg.Wait()
+
+		// the enqueuers are stopped by Stop
+		if w.enqueueCtx.Err() != nil {
+			return
+		}
+
 		w.log.Info().Msg("process block height done! stop program")
 		if err := syscall.Kill(syscall.Getpid(), syscall.SIGINT); err != nil {
 			panic(err)
 		}
-	}(wg)
+	}(w.rangeWg)
 
 	return nil
 }
 
 func (w *Worker) Stop(_ context.Context) error {
 	w.stopEnqueueHeight()
-	w.stopEnqueueErrorBlocks()
+
+	if w.cfg.ProcessErrorBlocks {
+		w.stopEnqueueErrorBlocks()
+	}
 
 	if w.cfg.ProcessNewBlocks {
 		w.stopWsListener()
 	}
 
-	close(w.heightCh)
+	if w.cfg.TrackFinalizedHeight {
+		w.stopTrackFinalizedHeight()
+	}
+
+	w.stopEnqueue()
+	w.enqueueWg.Wait()
+
+	// all enqueuers are stopped before the queues are closed
+	w.rangeWg.Wait()
+
+	// release the workers waiting for resume
+	w.pauser.resume()
+
+	w.scheduler.close()
 	w.wg.Wait()
 	w.stopProcessing()
 
@@ -192,3 +377,16 @@ func (w *Worker) Stop(_ context.Context) error {
 
 	return nil
 }
+
+// newOwnerID generates a unique id of the replica based on the host name.
+func newOwnerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "crawler"
+	}
+
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
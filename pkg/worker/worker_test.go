@@ -0,0 +1,1269 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	abci "github.com/cometbft/cometbft/abci/types"
+	"github.com/cosmos/cosmos-sdk/codec"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	"github.com/cosmos/cosmos-sdk/std"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	"github.com/rs/zerolog"
+
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/memory"
+	"github.com/bro-n-bro/spacebox-crawler/v2/adapter/storage/model"
+	"github.com/bro-n-bro/spacebox-crawler/v2/delivery/broker"
+	"github.com/bro-n-bro/spacebox-crawler/v2/internal/fake"
+	"github.com/bro-n-bro/spacebox-crawler/v2/internal/rep"
+	rawModule "github.com/bro-n-bro/spacebox-crawler/v2/modules/raw"
+	ts "github.com/bro-n-bro/spacebox-crawler/v2/pkg/mapper/to_storage"
+	"github.com/bro-n-bro/spacebox-crawler/v2/types"
+)
+
+type (
+	// blockerRecorder records heights of handled begin and end blocker events.
+	blockerRecorder struct {
+		mu    sync.Mutex
+		begin map[int64]types.BlockerEvents
+		end   map[int64]types.BlockerEvents
+	}
+
+	// failer fails message handling while fail is set.
+	failer struct {
+		fail atomic.Bool
+	}
+
+	// delayer delays handling of the lower heights, so they are handled after the upper ones.
+	delayer struct {
+		from int64
+	}
+
+	harness struct {
+		worker  *Worker
+		chain   *fake.Chain
+		storage *memory.Storage
+		broker  *fake.Broker
+		blocker *blockerRecorder
+	}
+)
+
+func (r *blockerRecorder) Name() string { return "blocker_recorder" }
+
+func (r *blockerRecorder) HandleBeginBlocker(_ context.Context, events types.BlockerEvents, height int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.begin[height] = events
+	return nil
+}
+
+func (r *blockerRecorder) HandleEndBlocker(_ context.Context, events types.BlockerEvents, height int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.end[height] = events
+	return nil
+}
+
+func (f *failer) Name() string { return "failer" }
+
+func (f *failer) HandleMessage(_ context.Context, _ int, _ sdk.Msg, _ *types.Tx) error {
+	if f.fail.Load() {
+		return errors.New("handle message error")
+	}
+
+	return nil
+}
+
+func (d delayer) Name() string { return "delayer" }
+
+func (d delayer) HandleBlock(_ context.Context, block *types.Block) error {
+	time.Sleep(time.Duration(d.from-block.Height) * 20 * time.Millisecond)
+	return nil
+}
+
+func newHarness(t *testing.T, cfg Config) *harness {
+	t.Helper()
+
+	h := &harness{
+		chain:   fake.NewChain("test-1"),
+		storage: memory.New(),
+		broker:  fake.NewBroker(),
+		blocker: &blockerRecorder{
+			begin: make(map[int64]types.BlockerEvents),
+			end:   make(map[int64]types.BlockerEvents),
+		},
+	}
+
+	h.worker = h.newWorker(cfg, h.broker, h.blocker)
+
+	return h
+}
+
+// newWorker creates a worker over the chain and storage of the harness.
+func (h *harness) newWorker(cfg Config, brk rep.Broker, mods ...types.Module) *Worker {
+	registry := codectypes.NewInterfaceRegistry()
+	std.RegisterInterfaces(registry)
+	banktypes.RegisterInterfaces(registry)
+
+	// messages are published within transactions through the sequencer as the app does
+	if _, ok := brk.(*Sequencer); !ok {
+		brk = NewSequencer(cfg, brk, zerolog.Nop())
+	}
+
+	mods = append(mods, rawModule.New(brk))
+
+	return New(cfg, zerolog.Nop(), brk, fake.NewRPCClient(h.chain), fake.NewGrpcClient(h.chain), mods, h.storage,
+		codec.NewProtoCodec(registry), *ts.NewToStorage())
+}
+
+func (h *harness) status(t *testing.T, height int64) model.Status {
+	t.Helper()
+
+	block, err := h.storage.GetBlockByHeight(context.Background(), height)
+	if err != nil {
+		t.Fatalf("get block %d: %v", height, err)
+	}
+
+	return block.Status
+}
+
+// expectHeights reads the heights from the queue in the given order.
+func expectHeights(t *testing.T, queue <-chan int64, want ...int64) {
+	t.Helper()
+
+	for _, height := range want {
+		select {
+		case got := <-queue:
+			if got != height {
+				t.Fatalf("want height %d, got %d", height, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("height %d: timeout", height)
+		}
+	}
+}
+
+func msgSend(t *testing.T) *codectypes.Any {
+	t.Helper()
+
+	msg, err := codectypes.NewAnyWithValue(&banktypes.MsgSend{FromAddress: "from", ToAddress: "to"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return msg
+}
+
+func TestProcessHeight(t *testing.T) {
+	var (
+		ctx = context.Background()
+		h   = newHarness(t, Config{ProcessErrorBlocks: true})
+		ev  = []abci.Event{{Type: "transfer"}, {Type: "transfer"}, {Type: "mint"}}
+	)
+
+	h.chain.AddHeight(1, nil, ev, nil)
+	h.chain.AddHeight(2, []fake.Tx{
+		{Messages: []*codectypes.Any{msgSend(t)}, GasUsed: 10},
+		{Messages: []*codectypes.Any{{TypeUrl: "/unknown.v1.MsgUnknown"}}, GasUsed: 5},
+		{Messages: []*codectypes.Any{{TypeUrl: "/unknown.v1.MsgUnknown"}}, Code: 1},
+	}, nil, ev)
+	h.chain.SetHeight(3, &fake.Height{Err: errors.New("node is not available")})
+
+	for height := int64(1); height <= 3; height++ {
+		h.worker.processHeight(ctx, 0, height, false)
+	}
+
+	if got := h.status(t, 1); !got.IsProcessed() {
+		t.Errorf("height 1: want processed status, got %s", got.ToString())
+	}
+	if got := h.status(t, 2); !got.IsProcessed() {
+		t.Errorf("height 2: want processed status, got %s", got.ToString())
+	}
+	if got := h.status(t, 3); !got.IsError() {
+		t.Errorf("height 3: want error status, got %s", got.ToString())
+	}
+
+	if got := len(h.broker.Published(*broker.RawBlock)); got != 2 {
+		t.Errorf("want 2 raw blocks, got %d", got)
+	}
+	if got := len(h.broker.Published(*broker.RawBlockResults)); got != 2 {
+		t.Errorf("want 2 raw block results, got %d", got)
+	}
+	// the block results are fetched once per height and shared by the blocker events, txs and raw handlers
+	if got := h.chain.BlockResultsCalls(); got != 3 {
+		t.Errorf("want 3 block results requests, got %d", got)
+	}
+	if got := len(h.broker.Published(*broker.RawTransaction)); got != 3 {
+		t.Errorf("want 3 raw transactions, got %d", got)
+	}
+
+	// only the unknown message of the successful transaction is unpacked
+	if count, _ := h.storage.CountErrorMessages(ctx); count != 1 {
+		t.Errorf("want 1 error message, got %d", count)
+	}
+
+	if got := len(h.blocker.begin[1]["transfer"]); got != 2 {
+		t.Errorf("want 2 transfer begin blocker events at height 1, got %d", got)
+	}
+	if got := len(h.blocker.end[2]["mint"]); got != 1 {
+		t.Errorf("want 1 mint end blocker event at height 2, got %d", got)
+	}
+}
+
+func TestProcessHeightSkipsProcessed(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, Config{ProcessErrorBlocks: true})
+	h.chain.AddHeight(1, nil, nil, nil)
+
+	h.worker.processHeight(ctx, 0, 1, false)
+	h.worker.processHeight(ctx, 0, 1, false)
+
+	if got := len(h.broker.Published(*broker.RawBlock)); got != 1 {
+		t.Errorf("want 1 raw block, got %d", got)
+	}
+}
+
+func TestProcessHeightErrorBlocks(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, Config{ProcessErrorBlocks: false})
+	h.chain.SetHeight(1, &fake.Height{Err: errors.New("pruned")})
+
+	h.worker.processHeight(ctx, 0, 1, false)
+
+	// the block is not reprocessed without PROCESS_ERROR_BLOCKS
+	h.chain.AddHeight(1, nil, nil, nil)
+	h.worker.processHeight(ctx, 0, 1, false)
+
+	if got := h.status(t, 1); !got.IsError() {
+		t.Errorf("want error status, got %s", got.ToString())
+	}
+
+	h.worker.cfg.ProcessErrorBlocks = true
+	h.worker.processHeight(ctx, 0, 1, false)
+
+	if got := h.status(t, 1); !got.IsProcessed() {
+		t.Errorf("want processed status, got %s", got.ToString())
+	}
+}
+
+func TestProcessHeightSkipped(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, Config{ProcessErrorBlocks: true})
+	h.chain.AddHeight(1, nil, nil, nil)
+
+	if err := h.storage.SkipBlock(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := h.worker.claimBlock(ctx, 1); !errors.Is(err, ErrBlockSkipped) {
+		t.Fatalf("want ErrBlockSkipped, got %v", err)
+	}
+
+	h.worker.processHeight(ctx, 0, 1, false)
+
+	if got := h.status(t, 1); !got.IsSkipped() {
+		t.Errorf("want skipped status, got %s", got.ToString())
+	}
+	if got := len(h.broker.Published(*broker.RawBlock)); got != 0 {
+		t.Errorf("want no raw blocks, got %d", got)
+	}
+}
+
+func TestStart(t *testing.T) {
+	h := newHarness(t, Config{
+		ProcessErrorBlocksInterval: time.Hour,
+		ProcessNewBlocks:           true,
+		ProcessGenesis:             true,
+		WorkersCount:               3,
+		StartHeight:                1,
+	})
+
+	for height := int64(1); height <= 5; height++ {
+		h.chain.AddHeight(height, []fake.Tx{{Messages: []*codectypes.Any{msgSend(t)}}}, nil, nil)
+	}
+
+	if err := h.worker.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	h.chain.NewBlock(6, nil, nil, nil)
+
+	waitProcessed(t, h.storage, 0, 6)
+
+	if err := h.worker.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(h.broker.Published(*broker.RawGenesis)); got != 1 {
+		t.Errorf("want 1 raw genesis, got %d", got)
+	}
+	if got := len(h.broker.Published(*broker.RawBlock)); got != 6 {
+		t.Errorf("want 6 raw blocks, got %d", got)
+	}
+	if got := len(h.broker.Published(*broker.RawTransaction)); got != 5 {
+		t.Errorf("want 5 raw transactions, got %d", got)
+	}
+}
+
+func TestStopWhileEnqueuing(t *testing.T) {
+	// the enqueuers of the range and the error blocks push heights until they are stopped
+	cfg := Config{
+		ProcessErrorBlocks:         true,
+		ProcessErrorBlocksInterval: time.Millisecond,
+		WorkersCount:               2,
+		StartHeight:                1,
+		StopHeight:                 100_000,
+	}
+
+	for i := 0; i < 20; i++ {
+		h := newHarness(t, cfg)
+
+		if err := h.worker.Start(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		time.Sleep(time.Millisecond)
+
+		if err := h.worker.Stop(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestProcessHeightReplicas(t *testing.T) {
+	var (
+		ctx      = context.Background()
+		h        = newHarness(t, Config{OwnerID: "replica-1"})
+		brk2     = fake.NewBroker()
+		replica2 = h.newWorker(Config{OwnerID: "replica-2"}, brk2)
+		wg       sync.WaitGroup
+	)
+
+	const heights = 50
+
+	for height := int64(1); height <= heights; height++ {
+		h.chain.AddHeight(height, nil, nil, nil)
+	}
+
+	for _, w := range []*Worker{h.worker, replica2} {
+		wg.Add(1)
+		go func(w *Worker) {
+			defer wg.Done()
+			for height := int64(1); height <= heights; height++ {
+				w.processHeight(ctx, 0, height, false)
+			}
+		}(w)
+	}
+
+	wg.Wait()
+
+	// every height is published exactly once by one of the replicas
+	if got := len(h.broker.Published(*broker.RawBlock)) + len(brk2.Published(*broker.RawBlock)); got != heights {
+		t.Errorf("want %d raw blocks, got %d", heights, got)
+	}
+}
+
+func TestProcessHeightExpiredLease(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, Config{OwnerID: "alive"})
+	h.chain.AddHeight(1, nil, nil, nil)
+	h.chain.AddHeight(2, nil, nil, nil)
+
+	// both heights are held by a replica which is gone
+	if _, err := h.storage.ClaimBlock(ctx, 1, "dead", time.Hour, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.storage.ClaimBlock(ctx, 2, "dead", time.Millisecond, false); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	h.worker.processHeight(ctx, 0, 1, false)
+	h.worker.processHeight(ctx, 0, 2, false)
+
+	if got := h.status(t, 1); !got.IsProcessing() {
+		t.Errorf("height 1: want processing status, got %s", got.ToString())
+	}
+	if got := h.status(t, 2); !got.IsProcessed() {
+		t.Errorf("height 2: want processed status, got %s", got.ToString())
+	}
+}
+
+func TestLeaseLost(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, Config{OwnerID: "alive", LeaseDuration: 30 * time.Millisecond})
+
+	if _, err := h.storage.ClaimBlock(ctx, 1, h.worker.owner, time.Minute, false); err != nil {
+		t.Fatal(err)
+	}
+
+	leaseCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	lost := h.worker.keepLease(leaseCtx, cancel, 1)
+
+	// another replica takes the height over
+	if err := h.storage.SetErrorStatus(ctx, 1, h.worker.owner, "released"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.storage.ClaimBlock(ctx, 1, "other", time.Minute, true); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-leaseCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("processing is not cancelled")
+	}
+
+	if !lost.Load() {
+		t.Error("want lost lease")
+	}
+}
+
+func TestReorg(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, Config{ProcessErrorBlocks: true, DetectReorgs: true})
+
+	for height := int64(1); height <= 3; height++ {
+		h.chain.AddHeight(height, nil, nil, nil)
+	}
+
+	h.worker.processHeight(ctx, 0, 1, false)
+	h.worker.processHeight(ctx, 0, 2, false)
+
+	// heights 2 and 3 are replaced by a fork
+	h.chain.AddHeight(2, []fake.Tx{{Messages: []*codectypes.Any{msgSend(t)}}}, nil, nil)
+	h.chain.AddHeight(3, nil, nil, nil)
+
+	// the parent hash of height 3 doesn't match the stored height 2
+	h.worker.processHeight(ctx, 0, 3, false)
+
+	if got := h.status(t, 2); !got.IsError() {
+		t.Fatalf("height 2: want error status, got %s", got.ToString())
+	}
+
+	reorgs := h.broker.Published(*broker.Reorg)
+	if len(reorgs) != 1 {
+		t.Fatalf("want 1 reorg, got %d", len(reorgs))
+	}
+	if got := reorgs[0].(types.Reorg); got.Height != 2 || got.DetectedHeight != 3 { //nolint:forcetypeassert
+		t.Errorf("want reorg of height 2 detected by 3, got %+v", got)
+	}
+
+	h.worker.processHeight(ctx, 0, 2, false)
+
+	if got := h.status(t, 2); !got.IsProcessed() {
+		t.Errorf("height 2: want processed status, got %s", got.ToString())
+	}
+
+	// height 1 is replaced, so the stored child height 2 is stale
+	h.chain.AddHeight(1, []fake.Tx{{Messages: []*codectypes.Any{msgSend(t)}}}, nil, nil)
+	if err := h.storage.RollbackBlock(ctx, 1, "manual rollback"); err != nil {
+		t.Fatal(err)
+	}
+
+	h.worker.processHeight(ctx, 0, 1, false)
+
+	if got := h.status(t, 2); !got.IsError() {
+		t.Errorf("height 2: want error status, got %s", got.ToString())
+	}
+	if got := len(h.broker.Published(*broker.Reorg)); got != 2 {
+		t.Errorf("want 2 reorgs, got %d", got)
+	}
+}
+
+func TestDeepReorg(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, Config{ProcessErrorBlocks: true, DetectReorgs: true})
+
+	for height := int64(1); height <= 4; height++ {
+		h.chain.AddHeight(height, nil, nil, nil)
+		h.worker.processHeight(ctx, 0, height, false)
+	}
+
+	// heights 2-4 are replaced by a fork
+	h.chain.AddHeight(2, []fake.Tx{{Messages: []*codectypes.Any{msgSend(t)}}}, nil, nil)
+	for height := int64(3); height <= 5; height++ {
+		h.chain.AddHeight(height, nil, nil, nil)
+	}
+
+	// all forked ancestors are rolled back at once
+	h.worker.processHeight(ctx, 0, 5, false)
+
+	for height := int64(2); height <= 4; height++ {
+		if got := h.status(t, height); !got.IsError() {
+			t.Errorf("height %d: want error status, got %s", height, got.ToString())
+		}
+	}
+	if got := h.status(t, 1); !got.IsProcessed() {
+		t.Errorf("height 1: want processed status, got %s", got.ToString())
+	}
+	if got := len(h.broker.Published(*broker.Reorg)); got != 3 {
+		t.Errorf("want 3 reorgs, got %d", got)
+	}
+}
+
+func TestReorgDetectionDisabled(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, Config{ProcessErrorBlocks: true})
+
+	h.chain.AddHeight(1, nil, nil, nil)
+	h.worker.processHeight(ctx, 0, 1, false)
+
+	block, err := h.storage.GetBlockByHeight(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block.Hash != "" || block.ParentHash != "" {
+		t.Errorf("want no stored hashes, got %q and %q", block.Hash, block.ParentHash)
+	}
+}
+
+func TestFinalizedHeight(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, Config{StartHeight: 1, ProcessErrorBlocks: true, TrackFinalizedHeight: true, DetectReorgs: true})
+
+	for height := int64(1); height <= 4; height++ {
+		h.chain.AddHeight(height, nil, nil, nil)
+	}
+
+	if got, err := h.worker.advanceFinalizedHeight(ctx); err != nil || got != 0 {
+		t.Fatalf("want finalized height 0, got %d: %v", got, err)
+	}
+
+	// height 3 is missing, so 4 is not finalized
+	for _, height := range []int64{1, 2, 4} {
+		h.worker.processHeight(ctx, 0, height, false)
+	}
+
+	if got, err := h.worker.advanceFinalizedHeight(ctx); err != nil || got != 2 {
+		t.Fatalf("want finalized height 2, got %d: %v", got, err)
+	}
+
+	h.worker.processHeight(ctx, 0, 3, false)
+
+	if got, err := h.worker.advanceFinalizedHeight(ctx); err != nil || got != 4 {
+		t.Fatalf("want finalized height 4, got %d: %v", got, err)
+	}
+	if got, err := h.storage.GetFinalizedHeight(ctx); err != nil || got != 4 {
+		t.Fatalf("want stored finalized height 4, got %d: %v", got, err)
+	}
+
+	// a rollback of height 2 moves the finalized height below it
+	h.chain.AddHeight(2, []fake.Tx{{Messages: []*codectypes.Any{msgSend(t)}}}, nil, nil)
+	h.chain.AddHeight(3, nil, nil, nil)
+	if err := h.storage.RollbackBlock(ctx, 3, "manual rollback"); err != nil {
+		t.Fatal(err)
+	}
+
+	h.worker.processHeight(ctx, 0, 3, false)
+
+	if got, err := h.storage.GetFinalizedHeight(ctx); err != nil || got != 1 {
+		t.Fatalf("want stored finalized height 1, got %d: %v", got, err)
+	}
+}
+
+func TestFinalizedHeightSkippedAndFailed(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, Config{StartHeight: 1, TrackFinalizedHeight: true})
+
+	for height := int64(1); height <= 5; height++ {
+		h.chain.AddHeight(height, nil, nil, nil)
+	}
+
+	for _, height := range []int64{1, 3, 5} {
+		h.worker.processHeight(ctx, 0, height, false)
+	}
+
+	// the operator skipped height 2, height 4 is out of attempts
+	if err := h.storage.SkipBlock(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.storage.ClaimBlock(ctx, 4, "other-worker", time.Minute, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.storage.UpdateStatus(ctx, 4, model.StatusFailed); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := h.worker.advanceFinalizedHeight(ctx); err != nil || got != 3 {
+		t.Fatalf("want finalized height 3, got %d: %v", got, err)
+	}
+
+	if h.worker.finalized.failed != 4 {
+		t.Fatalf("want failed height 4 reported, got %d", h.worker.finalized.failed)
+	}
+}
+
+func TestProcessHeightTransaction(t *testing.T) {
+	var (
+		ctx = context.Background()
+		h   = newHarness(t, Config{ProcessErrorBlocks: true})
+		f   = &failer{}
+	)
+
+	h.worker = h.newWorker(Config{ProcessErrorBlocks: true}, h.broker, f)
+	h.chain.AddHeight(1, []fake.Tx{{Messages: []*codectypes.Any{msgSend(t)}}}, nil, nil)
+
+	// the raw block is handled, but the message handling fails
+	f.fail.Store(true)
+	h.worker.processHeight(ctx, 0, 1, false)
+
+	if got := h.status(t, 1); !got.IsError() {
+		t.Fatalf("height 1: want error status, got %s", got.ToString())
+	}
+	if got := len(h.broker.Published(*broker.RawBlock)); got != 0 {
+		t.Errorf("want no raw blocks of the aborted transaction, got %d", got)
+	}
+	// the transaction is open only to publish the messages of the processed height
+	if committed, aborted := h.broker.Transactions(); committed != 0 || aborted != 0 {
+		t.Errorf("want no transactions of the failed height, got %d committed and %d aborted", committed, aborted)
+	}
+
+	f.fail.Store(false)
+	h.worker.processHeight(ctx, 0, 1, false)
+
+	if got := h.status(t, 1); !got.IsProcessed() {
+		t.Fatalf("height 1: want processed status, got %s", got.ToString())
+	}
+	if got := len(h.broker.Published(*broker.RawBlock)); got != 1 {
+		t.Errorf("want 1 raw block, got %d", got)
+	}
+	if got := len(h.broker.Published(*broker.RawTransaction)); got != 1 {
+		t.Errorf("want 1 raw transaction, got %d", got)
+	}
+	if committed, aborted := h.broker.Transactions(); committed != 1 || aborted != 0 {
+		t.Errorf("want 1 committed transaction, got %d committed and %d aborted", committed, aborted)
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	var (
+		ctx = context.Background()
+		h   = newHarness(t, Config{ProcessErrorBlocks: true})
+		f   = &failer{}
+	)
+
+	h.worker = h.newWorker(Config{ProcessErrorBlocks: true}, h.broker, f)
+	h.chain.AddHeight(1, []fake.Tx{{Messages: []*codectypes.Any{msgSend(t)}}}, nil, nil)
+
+	f.fail.Store(true)
+	h.worker.processHeight(ctx, 0, 1, false)
+	h.worker.processHeight(ctx, 0, 1, false)
+
+	f.fail.Store(false)
+	h.worker.processHeight(ctx, 0, 1, false)
+
+	published := h.broker.Published(*broker.DeadLetter)
+	if len(published) != 2 {
+		t.Fatalf("want 2 dead letters, got %d", len(published))
+	}
+
+	for i, msg := range published {
+		dl, ok := msg.(types.DeadLetter)
+		if !ok {
+			t.Fatalf("dead letter %d: unexpected type %T", i, msg)
+		}
+
+		if dl.Height != 1 || dl.Stage != types.StageMessages || dl.Module != f.Name() || dl.Attempt != i+1 {
+			t.Errorf("dead letter %d: unexpected %+v", i, dl)
+		}
+		if dl.Error == "" {
+			t.Errorf("dead letter %d: want error text", i)
+		}
+	}
+
+	// the height pruned on all endpoints is classified
+	h.chain.SetHeight(2, &fake.Height{Err: fmt.Errorf("%w: height 2", types.ErrHeightPruned)})
+	h.worker.processHeight(ctx, 0, 2, false)
+
+	published = h.broker.Published(*broker.DeadLetter)
+	// the block data is fetched concurrently, so any fetch stage may fail first
+	if dl, _ := published[len(published)-1].(types.DeadLetter); dl.Class != types.ClassHeightPruned ||
+		dl.Module != "" || dl.Height != 2 {
+		t.Errorf("want height pruned class at the fetch stage, got %+v", dl)
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	var (
+		ctx = context.Background()
+		cfg = Config{ProcessErrorBlocks: true, MaxAttempts: 2}
+		h   = newHarness(t, cfg)
+	)
+
+	h.chain.SetHeight(1, &fake.Height{Err: errors.New("pruned")})
+
+	h.worker.processHeight(ctx, 0, 1, false)
+
+	if got := h.status(t, 1); !got.IsError() {
+		t.Fatalf("attempt 1: want error status, got %s", got.ToString())
+	}
+
+	h.worker.processHeight(ctx, 0, 1, false)
+
+	if got := h.status(t, 1); !got.IsFailed() {
+		t.Fatalf("attempt 2: want failed status, got %s", got.ToString())
+	}
+
+	// the failed height is not processed anymore
+	h.chain.AddHeight(1, nil, nil, nil)
+	h.worker.processHeight(ctx, 0, 1, false)
+
+	block, err := h.storage.GetBlockByHeight(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !block.Status.IsFailed() || block.Attempts != 2 || block.LastAttempt == nil {
+		t.Errorf("want failed block after 2 attempts, got %+v", block)
+	}
+}
+
+func TestOrderedDelivery(t *testing.T) {
+	const count = 5
+
+	var (
+		ctx = context.Background()
+		cfg = Config{StartHeight: 1, WorkersCount: count, OrderedDelivery: true, OrderedBufferSize: count}
+		h   = newHarness(t, cfg)
+	)
+
+	h.worker = h.newWorker(cfg, NewSequencer(cfg, h.broker, zerolog.Nop()), delayer{from: count})
+
+	for height := int64(1); height <= count; height++ {
+		h.chain.AddHeight(height, []fake.Tx{{Messages: []*codectypes.Any{msgSend(t)}}}, nil, nil)
+	}
+
+	// height 3 fails and must not block the upper heights
+	h.chain.SetHeight(3, &fake.Height{Err: errors.New("node is not available")})
+
+	wg := &sync.WaitGroup{}
+	for height := int64(1); height <= count; height++ {
+		wg.Add(1)
+		go func(height int64) {
+			defer wg.Done()
+			h.worker.processHeight(ctx, 0, height, false)
+		}(height)
+	}
+	wg.Wait()
+
+	blocks := h.broker.Published(*broker.RawBlock)
+	txs := h.broker.Published(*broker.RawTransaction)
+	if len(blocks) != count-1 || len(txs) != count-1 {
+		t.Fatalf("want %d raw blocks and transactions, got %d and %d", count-1, len(blocks), len(txs))
+	}
+
+	want := []int64{1, 2, 4, 5}
+	for i := range want {
+		var block struct {
+			Block struct {
+				Header struct {
+					Height int64 `json:"height"`
+				} `json:"header"`
+			} `json:"block"`
+		}
+		var tx struct {
+			TxResponse struct {
+				Height int64 `json:"height,string"`
+			} `json:"tx_response"`
+		}
+
+		unmarshalPublished(t, blocks[i], &block)
+		unmarshalPublished(t, txs[i], &tx)
+
+		if got := block.Block.Header.Height; got != want[i] {
+			t.Errorf("raw block %d: want height %d, got %d", i, want[i], got)
+		}
+		if got := tx.TxResponse.Height; got != want[i] {
+			t.Errorf("raw transaction %d: want height %d, got %d", i, want[i], got)
+		}
+	}
+}
+
+func TestOrderedDeliveryTransactionSlot(t *testing.T) {
+	const count = 5
+
+	var (
+		ctx = context.Background()
+		cfg = Config{StartHeight: 1, WorkersCount: count, OrderedDelivery: true, OrderedBufferSize: count}
+		h   = newHarness(t, cfg)
+		brk = fake.NewBroker().WithTransactionSlots(1)
+	)
+
+	// the upper heights are processed first and wait for the lower ones without holding the only transaction
+	h.worker = h.newWorker(cfg, NewSequencer(cfg, brk, zerolog.Nop()), delayer{from: count})
+
+	for height := int64(1); height <= count; height++ {
+		h.chain.AddHeight(height, nil, nil, nil)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		wg := &sync.WaitGroup{}
+		for height := int64(1); height <= count; height++ {
+			wg.Add(1)
+			go func(height int64) {
+				defer wg.Done()
+				h.worker.processHeight(ctx, 0, height, false)
+			}(height)
+		}
+		wg.Wait()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("workers are deadlocked on the transaction slot")
+	}
+
+	if committed, _ := brk.Transactions(); committed != count {
+		t.Errorf("want %d committed transactions, got %d", count, committed)
+	}
+
+	waitProcessed(t, h.storage, 1, count)
+}
+
+func TestOrderedDeliveryClaimLost(t *testing.T) {
+	var (
+		ctx = context.Background()
+		cfg = Config{StartHeight: 1, WorkersCount: 2, OrderedDelivery: true, OrderedBufferSize: 10}
+		h   = newHarness(t, cfg)
+		seq = NewSequencer(cfg, h.broker, zerolog.Nop())
+	)
+
+	h.worker = h.newWorker(cfg, seq)
+
+	for height := int64(1); height <= 3; height++ {
+		h.chain.AddHeight(height, nil, nil, nil)
+	}
+
+	// height 2 is enqueued twice and the duplicate loses the claim to the worker of this replica processing it
+	if _, err := h.storage.ClaimBlock(ctx, 2, h.worker.owner, time.Minute, false); err != nil {
+		t.Fatal(err)
+	}
+
+	h.worker.processHeight(ctx, 0, 2, false)
+	h.worker.processHeight(ctx, 0, 1, false)
+
+	// the height owned by the other worker is not skipped in the ordered delivery
+	seq.mu.Lock()
+	next := seq.next
+	seq.mu.Unlock()
+
+	if next != 2 {
+		t.Errorf("want next height 2 to publish, got %d", next)
+	}
+}
+
+func TestOrderedDeliveryRestart(t *testing.T) {
+	var (
+		ctx = context.Background()
+		cfg = Config{StartHeight: 1, WorkersCount: 2, OrderedDelivery: true, OrderedBufferSize: 2}
+		h   = newHarness(t, cfg)
+		seq = NewSequencer(cfg, h.broker, zerolog.Nop())
+	)
+
+	h.worker = h.newWorker(cfg, seq)
+
+	// heights 1-3 are processed before restart, height 4 is processed by another replica
+	for height := int64(1); height <= 6; height++ {
+		h.chain.AddHeight(height, nil, nil, nil)
+	}
+
+	for height := int64(1); height <= 3; height++ {
+		if err := h.storage.CreateBlock(ctx, &model.Block{Height: height, Status: model.StatusProcessed}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := h.storage.ClaimBlock(ctx, 4, "other-replica", time.Minute, false); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		// a single worker of two is busy, so the skipped heights must not wait for the stuck workers
+		for height := int64(1); height <= 6; height++ {
+			h.worker.processHeight(ctx, 0, height, false)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ordered delivery is stuck on the heights skipped by the claim")
+	}
+
+	published := h.broker.Published(*broker.RawBlock)
+	if len(published) != 2 {
+		t.Fatalf("want 2 raw blocks, got %d", len(published))
+	}
+
+	seq.mu.Lock()
+	next := seq.next
+	seq.mu.Unlock()
+
+	if next != 7 {
+		t.Errorf("want next height 7 to publish, got %d", next)
+	}
+}
+
+// unmarshalPublished converts the published message to the given type through JSON.
+func unmarshalPublished(t *testing.T, msg, v interface{}) {
+	t.Helper()
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = json.Unmarshal(data, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitProcessed waits until all heights of the range are processed.
+func waitProcessed(t *testing.T, s *memory.Storage, from, to int64) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for height := from; height <= to; height++ {
+		for {
+			block, err := s.GetBlockByHeight(context.Background(), height)
+			if err == nil && block.Status.IsProcessed() {
+				break
+			}
+
+			if time.Now().After(deadline) {
+				t.Fatalf("height %d is not processed in time", height)
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+func TestPause(t *testing.T) {
+	h := newHarness(t, Config{})
+
+	h.worker.Pause()
+	if !h.worker.Paused() {
+		t.Fatal("want paused worker")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		h.worker.pauser.wait(context.Background())
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("want the worker held while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	h.worker.Resume()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("want the worker released on resume")
+	}
+}
+
+func TestBackfillJobs(t *testing.T) {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		h           = newHarness(t, Config{BackfillBatchSize: 2, BackfillInterval: 10 * time.Millisecond})
+	)
+
+	defer cancel()
+
+	for _, job := range []*model.BackfillJob{
+		{ID: "low", From: 1, To: 3, Next: 1, Priority: 1, Status: model.BackfillJobActive, Created: time.Now()},
+		{ID: "high", From: 10, To: 12, Next: 10, Priority: 5, Status: model.BackfillJobActive, Created: time.Now()},
+		{ID: "paused", From: 20, To: 22, Next: 20, Status: model.BackfillJobPaused, Created: time.Now()},
+	} {
+		if err := h.storage.CreateBackfillJob(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h.worker.scheduler = newScheduler(100)
+
+	run := func(ctx context.Context) *sync.WaitGroup {
+		wg := &sync.WaitGroup{}
+		wg.Add(1)
+		go h.worker.runBackfillJobs(ctx, wg)
+
+		return wg
+	}
+
+	job := func(id string) *model.BackfillJob {
+		t.Helper()
+
+		job, err := h.storage.GetBackfillJob(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return job
+	}
+
+	wg := run(ctx)
+	expectHeights(t, h.worker.scheduler.queues[classBackfill], 10, 11, 12, 1, 2, 3)
+
+	// enqueued heights are not the progress of the jobs
+	for _, id := range []string{"low", "high"} {
+		if got := job(id); !got.Status.IsActive() || got.Next != got.From {
+			t.Errorf("job %s: want active without progress, got %+v", id, got)
+		}
+	}
+
+	for _, height := range []int64{10, 11, 12, 1} {
+		if err := h.storage.CreateBlock(ctx, &model.Block{Height: height, Status: model.StatusProcessed}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := h.storage.SkipBlock(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for job("high").Status != model.BackfillJobFinished || job("low").Next != 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("want high job finished and low job at 3, got %+v %+v", job("high"), job("low"))
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	wg.Wait()
+
+	if got := job("high"); got.Next != got.To+1 || got.Finished == nil {
+		t.Errorf("job high: want finished, got %+v", got)
+	}
+
+	if got := job("low"); !got.Status.IsActive() {
+		t.Errorf("job low: want active with the not processed height 3, got %+v", got)
+	}
+
+	if got := len(h.worker.scheduler.queues[classBackfill]); got != 0 {
+		t.Errorf("want no heights of the paused job, got %d", got)
+	}
+
+	// the height lost on restart is enqueued again
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	wg = run(ctx)
+	expectHeights(t, h.worker.scheduler.queues[classBackfill], 3)
+
+	cancel()
+	wg.Wait()
+}
+
+func TestScheduler(t *testing.T) {
+	ctx := context.Background()
+	s := newScheduler(10)
+
+	for _, item := range []struct {
+		class  heightClass
+		height int64
+	}{
+		{classBackfill, 1},
+		{classRetry, 2},
+		{classLive, 3},
+		{classBackfill, 4},
+	} {
+		if !s.push(ctx, item.class, item.height) {
+			t.Fatalf("push %d: want true", item.height)
+		}
+	}
+
+	if got, _ := s.next(retryClassOrder); got != 2 {
+		t.Errorf("retry worker: want 2, got %d", got)
+	}
+
+	for _, want := range []int64{3, 1, 4} {
+		if got, _ := s.next(classOrder); got != want {
+			t.Errorf("want %d, got %d", want, got)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		if _, ok := s.next(classOrder); ok {
+			t.Error("want no height after close")
+		}
+	}()
+
+	s.close()
+	<-done
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	if newScheduler(0).push(canceled, classLive, 1) {
+		t.Error("push with canceled context: want false")
+	}
+
+	for _, tc := range []struct {
+		count int
+		share float64
+		want  int
+	}{
+		{8, 0.1, 1},
+		{8, 0.5, 4},
+		{8, 0, 0},
+		{1, 0.1, 0},
+		{4, 1, 3},
+	} {
+		if got := retryWorkers(tc.count, tc.share); got != tc.want {
+			t.Errorf("retryWorkers(%d, %v): want %d, got %d", tc.count, tc.share, tc.want, got)
+		}
+	}
+}
+
+func TestGapScanner(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, Config{StartHeight: 1})
+
+	for height := int64(1); height <= 10; height++ {
+		h.chain.AddHeight(height, nil, nil, nil)
+	}
+
+	for _, height := range []int64{1, 2, 4, 7} {
+		if err := h.storage.CreateBlock(ctx, &model.Block{Height: height, Status: model.StatusProcessed}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h.worker.scheduler = newScheduler(100)
+
+	// the configured range is enqueued up to the height 8
+	h.worker.rangeEnqueued.Store(8)
+
+	scan := func(contiguous int64, want []int64) int64 {
+		t.Helper()
+
+		contiguous, err := h.worker.scanGaps(ctx, contiguous)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		queue := h.worker.scheduler.queues[classBackfill]
+		if got := len(queue); got != len(want) {
+			t.Fatalf("want %d missing heights, got %d", len(want), got)
+		}
+
+		for _, height := range want {
+			if got := <-queue; got != height {
+				t.Errorf("want missing height %d, got %d", height, got)
+			}
+		}
+
+		return contiguous
+	}
+
+	contiguous := scan(-1, []int64{3, 5, 6, 8})
+	if contiguous != 2 {
+		t.Errorf("want contiguous height 2, got %d", contiguous)
+	}
+
+	h.worker.rangeDone.Store(true)
+
+	for _, height := range []int64{3, 5} {
+		if err := h.storage.CreateBlock(ctx, &model.Block{Height: height, Status: model.StatusProcessed}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if contiguous = scan(contiguous, []int64{6, 8, 9, 10}); contiguous != 5 {
+		t.Errorf("want contiguous height 5, got %d", contiguous)
+	}
+}
+
+func TestResubscribeNewBlocks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	h := newHarness(t, Config{ProcessNewBlocks: true})
+	h.worker.scheduler = newScheduler(100)
+
+	eventCh, err := h.worker.rpcClient.SubscribeNewBlocks(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go h.worker.followNewBlocks(ctx, wg, eventCh)
+
+	expect := func(want ...int64) {
+		t.Helper()
+		expectHeights(t, h.worker.scheduler.queues[classLive], want...)
+	}
+
+	h.chain.NewBlock(1, nil, nil, nil)
+	expect(1)
+
+	// the heights produced while the subscription is lost are caught up after resubscription
+	h.chain.AddHeight(2, nil, nil, nil)
+	h.chain.AddHeight(3, nil, nil, nil)
+	h.chain.DropSubscription()
+	expect(2, 3)
+
+	// the height skipped by the subscription is enqueued with the next one
+	h.chain.AddHeight(4, nil, nil, nil)
+	h.chain.NewBlock(5, nil, nil, nil)
+	expect(4, 5)
+
+	cancel()
+	wg.Wait()
+}
+
+func TestPollNewBlocks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	h := newHarness(t, Config{PollNewBlocks: true, PollNewBlocksInterval: 10 * time.Millisecond})
+	h.worker.scheduler = newScheduler(100)
+
+	h.chain.AddHeight(1, nil, nil, nil)
+	h.chain.AddHeight(2, nil, nil, nil)
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go h.worker.pollNewBlocks(ctx, wg)
+
+	// the first poll enqueues the tip only
+	expectHeights(t, h.worker.scheduler.queues[classLive], 2)
+
+	h.chain.AddHeight(3, nil, nil, nil)
+	h.chain.AddHeight(4, nil, nil, nil)
+	expectHeights(t, h.worker.scheduler.queues[classLive], 3, 4)
+
+	cancel()
+	wg.Wait()
+
+	if got := len(h.worker.scheduler.queues[classLive]); got != 0 {
+		t.Errorf("want no duplicate heights, got %d", got)
+	}
+}
@@ -35,7 +35,8 @@ type (
 	}
 
 	Block struct {
-		rb *cometbftcoretypes.ResultBlock
+		rb  *cometbftcoretypes.ResultBlock
+		rbr *cometbftcoretypes.ResultBlockResults
 
 		Timestamp           time.Time
 		Hash                string
@@ -100,6 +101,14 @@ func NewBlockFromTmBlock(blk *cometbftcoretypes.ResultBlock, totalGas uint64) *B
 	return res
 }
 
+// NewBlockFromHeightData builds a new Block instance from the block and the block results of the height
+func NewBlockFromHeightData(data *HeightData, totalGas uint64) *Block {
+	res := NewBlockFromTmBlock(data.Block, totalGas)
+	res.rbr = data.Results
+
+	return res
+}
+
 func NewValidatorPreCommitsFromTmSignatures(sigs []cometbfttypes.CommitSig) []ValidatorPreCommit {
 	res := make([]ValidatorPreCommit, 0, len(sigs))
 	for _, sig := range sigs {
@@ -220,3 +229,6 @@ func (tx Tx) Successful() bool {
 }
 
 func (b Block) Raw() *cometbftcoretypes.ResultBlock { return b.rb }
+
+// RawResults returns the block results of the height, nil if the block is not built from the height data.
+func (b Block) RawResults() *cometbftcoretypes.ResultBlockResults { return b.rbr }
@@ -0,0 +1,39 @@
+package types
+
+import (
+	"strconv"
+	"time"
+)
+
+// Processing stages of a height reported in the dead letters.
+const (
+	StageGenesis      = "genesis"
+	StageBlock        = "block"
+	StageValidators   = "validators"
+	StageBlockEvents  = "block_events"
+	StageContinuity   = "continuity"
+	StageTxs          = "txs"
+	StageMessages     = "messages"
+	StageBeginBlocker = "beginblocker"
+	StageEndBlocker   = "endblocker"
+	StagePublish      = "publish"
+	StagePanic        = "panic"
+)
+
+// ClassHeightPruned is the error class of the height which is not available on any endpoint.
+const ClassHeightPruned = "height_pruned"
+
+// DeadLetter is the failure record of the height processing.
+type DeadLetter struct {
+	Timestamp time.Time `json:"timestamp"`
+	Stage     string    `json:"stage"`
+	Class     string    `json:"class,omitempty"`
+	Module    string    `json:"module,omitempty"`
+	TxHash    string    `json:"tx_hash,omitempty"`
+	Error     string    `json:"error"`
+	Height    int64     `json:"height"`
+	Attempt   int       `json:"attempt"`
+}
+
+// RecordKey returns the height as the key of the record, so failures of the height land in one partition.
+func (dl DeadLetter) RecordKey() string { return strconv.FormatInt(dl.Height, 10) }
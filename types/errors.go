@@ -3,5 +3,12 @@ package types
 import "github.com/pkg/errors"
 
 var (
-	ErrBlockNotFound = errors.New("block not found")
+	ErrBlockNotFound   = errors.New("block not found")
+	ErrBlockNotClaimed = errors.New("block not claimed")
+	ErrLeaseLost       = errors.New("block lease lost")
+	ErrHeightPruned    = errors.New("height is pruned on all endpoints")
+
+	ErrFinalizedHeightNotFound = errors.New("finalized height not found")
+	ErrBackfillJobNotFound     = errors.New("backfill job not found")
+	ErrBackfillJobDone         = errors.New("backfill job is finished or cancelled")
 )
@@ -0,0 +1,9 @@
+package types
+
+import "time"
+
+// FinalizedHeight is the highest height such that every height below and including it is processed.
+type FinalizedHeight struct {
+	Timestamp time.Time `json:"timestamp"`
+	Height    int64     `json:"height"`
+}
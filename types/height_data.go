@@ -0,0 +1,16 @@
+package types
+
+import cometbftcoretypes "github.com/cometbft/cometbft/rpc/core/types"
+
+// HeightData is the node data of the height. It is fetched once per processing of the height and shared by all
+// handlers, so the modules don't query the nodes themselves.
+type HeightData struct {
+	Block      *cometbftcoretypes.ResultBlock
+	Validators *cometbftcoretypes.ResultValidators
+	Results    *cometbftcoretypes.ResultBlockResults
+}
+
+// BlockerEvents returns the begin block and end block events of the height.
+func (d *HeightData) BlockerEvents() (begin, end BlockerEvents) {
+	return NewBlockerEventsAttributes(d.Results.BeginBlockEvents), NewBlockerEventsAttributes(d.Results.EndBlockEvents)
+}
@@ -0,0 +1,26 @@
+package types
+
+import "context"
+
+type (
+	// Origin is the chain and the height which the published messages belong to.
+	Origin struct {
+		ChainID string
+		Height  int64
+		// Attempt is the number of the processing attempt of the height
+		Attempt int
+	}
+
+	originKey struct{}
+)
+
+// WithOrigin returns the context for publishing messages of the origin.
+func WithOrigin(ctx context.Context, o Origin) context.Context {
+	return context.WithValue(ctx, originKey{}, o)
+}
+
+// OriginFromContext returns the origin of the messages published with the context.
+func OriginFromContext(ctx context.Context) (Origin, bool) {
+	o, ok := ctx.Value(originKey{}).(Origin)
+	return o, ok
+}
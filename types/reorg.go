@@ -0,0 +1,15 @@
+package types
+
+import "time"
+
+// Reorg describes a processed height which doesn't belong to the canonical chain anymore.
+type Reorg struct {
+	DetectedAt time.Time `json:"detected_at"`
+	// StoredHash is the hash of the height known by the crawler.
+	StoredHash string `json:"stored_hash"`
+	// CanonicalHash is the hash of the height reported by the node. It is empty if unknown.
+	CanonicalHash string `json:"canonical_hash,omitempty"`
+	Height        int64  `json:"height"`
+	// DetectedHeight is the neighbour height which revealed the mismatch.
+	DetectedHeight int64 `json:"detected_height"`
+}